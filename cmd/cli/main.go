@@ -5,35 +5,101 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/3scale/3scale-istio-adapter/pkg/kubernetes"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	system "github.com/3scale/3scale-porta-go-client/client"
+	"istio.io/api/policy/v1beta1"
 )
 
+// stringSliceFlag collects the values of a flag which can be provided multiple times
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 var (
 	accessToken   string
 	svcID         string
 	threescaleURL string
 	backendURL    string
+	environment   string
 	name          string
 	outputTo      string
 	authType      int
 	namespace     string
+	discover      bool
+	serviceFilter string
+
+	userKeySources  string
+	appIDSources    string
+	appKeySources   string
+	clientIDSources string
+
+	excludePaths          string
+	destinationServices   string
+	destinationNamespaces string
+	methods               string
+	matchExpressions      stringSliceFlag
+	extraActions          stringSliceFlag
+	usageSources          stringSliceFlag
 
 	version string
 )
 
 const (
-	nameDescription       = "Unique name for this (url,token) pair (required)"
-	tokenDescription      = "3scale access token (required)"
-	threescaleDescription = "The 3scale admin portal URL (required)"
-	backendDescription    = "The 3scale backend url"
+	validateCommand = "validate"
+	simulateCommand = "simulate"
+
+	validateFileDescription       = "File containing the handler, instance and rule manifests to validate (required)"
+	validateAttributesDescription = "File containing attribute manifests which describe the attribute vocabulary. Defaults to the Istio proxy and Kubernetes vocabulary"
+
+	simulateConfigDescription      = "File containing a proxy config in the JSON format exported by 3scale. If not set, the config is fetched from 3scale"
+	simulateEnvironmentDescription = "The 3scale environment to fetch the proxy config for"
+	simulateMethodDescription      = "HTTP method of the request to evaluate"
+	simulatePathDescription        = "Path of the request to evaluate (required)"
+
+	nameDescription        = "Unique name for this (url,token) pair (required)"
+	tokenDescription       = "3scale access token (required)"
+	threescaleDescription  = "The 3scale admin portal URL (required)"
+	backendDescription     = "The 3scale backend url"
+	environmentDescription = "The 3scale environment which the handler fetches the proxy config from, one of production or staging. Defaults to production"
 
 	svcIDDescription     = "The ID of the 3scale service. If set the generated configuration will apply to this service only."
 	outputDescription    = "File to output templates. Prints to stdout if none provided"
 	authTypeDescription  = "3scale authentication pattern to use. 1=ApiKey, 2=AppID, 3=OpenID Connect. Default template supports a hybrid if none provided"
 	namespaceDescription = "The namespace which the manifests should be generated for. Default 'istio-system'"
+	discoverDescription  = "Fetch the list of services from 3scale and generate configuration for each service, using the authentication pattern configured in 3scale"
+	filterDescription    = "Regular expression which a service name or system name must match for configuration to be generated. Requires --discover"
+
+	credentialSourcesFormat = "Comma separated list of sources in order of precedence, formatted as type:name where type is one of query, header, claim or cookie"
+	userKeyDescription      = "Sources to read the user key from. " + credentialSourcesFormat
+	appIDDescription        = "Sources to read the application ID from. " + credentialSourcesFormat
+	appKeyDescription       = "Sources to read the application key from. " + credentialSourcesFormat
+	clientIDDescription     = "Sources to read the OpenID Connect client ID from. " + credentialSourcesFormat
+
+	excludePathsDescription          = "Comma separated list of path prefixes which should not be matched by the rule, for example /health"
+	destinationServicesDescription   = "Comma separated list of destination service names which the rule should be restricted to"
+	destinationNamespacesDescription = "Comma separated list of destination namespaces which the rule should be restricted to"
+	methodsDescription               = "Comma separated list of HTTP methods which the rule should be restricted to"
+	matchDescription                 = "Additional match expression for the rule. Can be provided multiple times"
+	actionDescription                = "Additional action for the rule, formatted as handler=instance[,instance]. Can be provided multiple times"
+	usageDescription                 = "Attribute expression whose value is reported as usage of a metric, formatted as metric=expression. Can be provided multiple times"
 
 	outputDefault, tokenDefault, svcDefault, urlDefault = "", "", "", ""
 
@@ -53,6 +119,8 @@ func init() {
 
 	flag.StringVar(&backendURL, "backend-url", urlDefault, backendDescription)
 
+	flag.StringVar(&environment, "environment", "", environmentDescription)
+
 	flag.StringVar(&outputTo, "output", outputDefault, outputDescription)
 	flag.StringVar(&outputTo, "o", outputDefault, outputDescription+" (short)")
 
@@ -61,6 +129,22 @@ func init() {
 	flag.StringVar(&namespace, "namespace", istioNamespaceDefault, namespaceDescription)
 	flag.StringVar(&namespace, "n", istioNamespaceDefault, namespaceDescription+" (short)")
 
+	flag.BoolVar(&discover, "discover", false, discoverDescription)
+	flag.StringVar(&serviceFilter, "filter", "", filterDescription)
+
+	flag.StringVar(&userKeySources, "user-key", "", userKeyDescription)
+	flag.StringVar(&appIDSources, "app-id", "", appIDDescription)
+	flag.StringVar(&appKeySources, "app-key", "", appKeyDescription)
+	flag.StringVar(&clientIDSources, "client-id", "", clientIDDescription)
+
+	flag.StringVar(&excludePaths, "exclude-paths", "", excludePathsDescription)
+	flag.StringVar(&destinationServices, "destination-services", "", destinationServicesDescription)
+	flag.StringVar(&destinationNamespaces, "destination-namespaces", "", destinationNamespacesDescription)
+	flag.StringVar(&methods, "methods", "", methodsDescription)
+	flag.Var(&matchExpressions, "match", matchDescription)
+	flag.Var(&extraActions, "action", actionDescription)
+	flag.Var(&usageSources, "usage", usageDescription)
+
 	v := flag.Bool("version", false, "Prints CLI version")
 
 	flag.Parse()
@@ -99,38 +183,82 @@ func validate() []error {
 		errs = append(errs, errors.New("error missing parameter. --url is required"))
 	}
 
+	if discover {
+		if svcID != "" {
+			errs = append(errs, errors.New("error invalid parameters. --service cannot be used with --discover"))
+		}
+
+		if authType != 0 {
+			errs = append(errs, errors.New("error invalid parameters. --auth cannot be used with --discover"))
+		}
+	}
+
+	switch environment {
+	case "", threescale.EnvironmentProduction, threescale.EnvironmentStaging:
+	default:
+		errs = append(errs, fmt.Errorf("error invalid parameter. --environment must be one of %s or %s", threescale.EnvironmentProduction, threescale.EnvironmentStaging))
+	}
+
+	if serviceFilter != "" {
+		if !discover {
+			errs = append(errs, errors.New("error invalid parameters. --filter requires --discover"))
+		}
+
+		if _, err := regexp.Compile(serviceFilter); err != nil {
+			errs = append(errs, fmt.Errorf("error invalid parameter. --filter must be a valid regular expression - %v", err))
+		}
+	}
+
 	return errs
 }
 
 func execute() error {
-	var writeTo io.Writer
+	if discover {
+		return executeForDiscoveredServices()
+	}
 
 	handler, err := kubernetes.NewThreescaleHandlerSpec(accessToken, threescaleURL, svcID)
 	if err != nil {
 		return fmt.Errorf("error creating required handler " + err.Error())
 	}
 
-	// set the optional backend url override
+	// set the optional backend url and environment overrides
 	handler.Params.BackendUrl = backendURL
+	handler.Params.Environment = environment
+
+	attrs, cookies, err := getCredentialAttributes()
+	if err != nil {
+		return err
+	}
+
+	usages, err := getUsageSources()
+	if err != nil {
+		return err
+	}
 
 	var instance *kubernetes.BaseInstance
 	switch authType {
 	case 0:
-		instance = kubernetes.NewDefaultHybridInstance()
+		instance = kubernetes.NewHybridInstance(attrs)
 	case 1:
-		instance = kubernetes.NewApiKeyInstance(kubernetes.DefaultApiKeyAttribute)
+		instance = kubernetes.NewApiKeyInstance(attrs.UserKey)
 	case 2:
-		instance = kubernetes.NewAppIDAppKeyInstance(kubernetes.DefaultAppIDAttribute, kubernetes.DefaultAppKeyAttribute)
+		instance = kubernetes.NewAppIDAppKeyInstance(attrs.AppID, attrs.AppKey)
 	case 3:
-		instance = kubernetes.NewOIDCInstance(kubernetes.DefaultOIDCAttribute, kubernetes.DefaultAppKeyAttribute)
+		instance = kubernetes.NewOIDCInstance(attrs.ClientID, attrs.AppKey)
 	default:
 		return fmt.Errorf("unsupported authentication type provided")
 
 	}
+	setCookieSources(instance, cookies)
+	setUsageSources(instance, usages)
 
 	handlerName := fmt.Sprintf("%s.handler.%s", name, namespace)
 	instanceName := fmt.Sprintf("%s.instance.%s", name, namespace)
-	rule := kubernetes.NewRule(kubernetes.GetDefaultMatchConditions(name), handlerName, instanceName)
+	rule, err := newRule(kubernetes.GetDefaultMatchConditions(name), handlerName, instanceName)
+	if err != nil {
+		return err
+	}
 
 	cg, err := kubernetes.NewConfigGenerator(name, *handler, *instance, rule)
 	if err != nil {
@@ -139,20 +267,336 @@ func execute() error {
 
 	cg.SetNamespace(namespace)
 
+	return cg.OutputAll(getWriter())
+}
+
+// executeForDiscoveredServices generates a handler, instance and rule for each service fetched from 3scale
+func executeForDiscoveredServices() error {
+	var filter *regexp.Regexp
+	if serviceFilter != "" {
+		filter = regexp.MustCompile(serviceFilter)
+	}
+
+	services, err := kubernetes.GetThreescaleServices(accessToken, threescaleURL, filter, &http.Client{Timeout: time.Second * 10})
+	if err != nil {
+		return fmt.Errorf("error fetching services " + err.Error())
+	}
+
+	if len(services) == 0 {
+		return fmt.Errorf("no services found matching the provided parameters")
+	}
+
+	attrs, cookies, err := getCredentialAttributes()
+	if err != nil {
+		return err
+	}
+
+	usages, err := getUsageSources()
+	if err != nil {
+		return err
+	}
+
+	var generators []*kubernetes.ConfigGenerator
+	for _, svc := range services {
+		handler, err := kubernetes.NewThreescaleHandlerSpec(accessToken, threescaleURL, svc.ID)
+		if err != nil {
+			return fmt.Errorf("error creating required handler " + err.Error())
+		}
+		handler.Params.BackendUrl = backendURL
+		handler.Params.Environment = environment
+
+		instance, err := kubernetes.NewInstanceForBackendVersion(svc.BackendVersion, attrs)
+		if err != nil {
+			return fmt.Errorf("error creating instance for service %s - %s", svc.ID, err.Error())
+		}
+		setCookieSources(instance, cookies)
+		setUsageSources(instance, usages)
+
+		svcName := fmt.Sprintf("%s-%s", name, svc.ID)
+		handlerName := fmt.Sprintf("%s.handler.%s", svcName, namespace)
+		instanceName := fmt.Sprintf("%s.instance.%s", svcName, namespace)
+		rule, err := newRule(kubernetes.GetServiceMatchConditions(name, svc.ID), handlerName, instanceName)
+		if err != nil {
+			return err
+		}
+
+		cg, err := kubernetes.NewConfigGenerator(svcName, *handler, *instance, rule)
+		if err != nil {
+			return fmt.Errorf("error creating config generator " + err.Error())
+		}
+		generators = append(generators, cg.SetNamespace(namespace))
+	}
+
+	writeTo := getWriter()
+	for _, cg := range generators {
+		if err := cg.OutputAll(writeTo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getCredentialAttributes builds the attribute expressions for each credential from the provided sources, falling back
+// to the defaults where no sources are provided. Cookie sources are returned separately, keyed by credential.
+func getCredentialAttributes() (kubernetes.CredentialAttributes, map[string]string, error) {
+	attrs := kubernetes.DefaultCredentialAttributes()
+	cookies := make(map[string]string)
+
+	for _, credential := range []struct {
+		key     string
+		flag    string
+		sources string
+		attr    *string
+	}{
+		{key: threescale.UserKeyAttributeKey, flag: "--user-key", sources: userKeySources, attr: &attrs.UserKey},
+		{key: threescale.AppIDAttributeKey, flag: "--app-id", sources: appIDSources, attr: &attrs.AppID},
+		{key: threescale.AppKeyAttributeKey, flag: "--app-key", sources: appKeySources, attr: &attrs.AppKey},
+		{key: threescale.OIDCAttributeKey, flag: "--client-id", sources: clientIDSources, attr: &attrs.ClientID},
+	} {
+		if credential.sources == "" {
+			continue
+		}
+
+		sources, err := kubernetes.ParseCredentialSources(credential.sources)
+		if err != nil {
+			return attrs, nil, fmt.Errorf("error invalid parameter %s - %v", credential.flag, err)
+		}
+
+		expression, err := sources.AttributeExpression()
+		if err != nil {
+			return attrs, nil, fmt.Errorf("error invalid parameter %s - %v", credential.flag, err)
+		}
+		*credential.attr = expression
+
+		if cookie := sources.Cookie(); cookie != "" {
+			cookies[credential.key] = cookie
+		}
+	}
+	return attrs, cookies, nil
+}
+
+func setCookieSources(instance *kubernetes.BaseInstance, cookies map[string]string) {
+	for credentialKey, cookie := range cookies {
+		instance.SetCookieSource(credentialKey, cookie)
+	}
+}
+
+// getUsageSources parses the sources provided via --usage
+func getUsageSources() ([]kubernetes.UsageSource, error) {
+	var usages []kubernetes.UsageSource
+	for _, source := range usageSources {
+		usage, err := kubernetes.ParseUsageSource(source)
+		if err != nil {
+			return nil, fmt.Errorf("error invalid parameter --usage - %v", err)
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+func setUsageSources(instance *kubernetes.BaseInstance, usages []kubernetes.UsageSource) {
+	for _, usage := range usages {
+		instance.SetUsageSource(usage)
+	}
+}
+
+// newRule builds a rule dispatching the instance to the handler, along with any additional match conditions and actions
+func newRule(conditions kubernetes.MatchConditions, handler string, instance string) (kubernetes.Rule, error) {
+	conditions = conditions.
+		ExcludePathPrefixes(splitList(excludePaths)...).
+		ForDestinationServices(splitList(destinationServices)...).
+		ForDestinationNamespaces(splitList(destinationNamespaces)...).
+		ForRequestMethods(splitList(methods)...).
+		WithExpressions(matchExpressions...)
+
+	if err := conditions.Validate(); err != nil {
+		return kubernetes.Rule{}, fmt.Errorf("error invalid match conditions - %v", err)
+	}
+
+	actions := []*v1beta1.Action{kubernetes.NewAction(handler, instance)}
+	for _, a := range extraActions {
+		action, err := kubernetes.ParseAction(a)
+		if err != nil {
+			return kubernetes.Rule{}, fmt.Errorf("error invalid parameter --action - %v", err)
+		}
+		actions = append(actions, action)
+	}
+
+	return kubernetes.NewMultiActionRule(conditions, actions...), nil
+}
+
+// splitList splits a comma separated list, ignoring empty values
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getWriter() io.Writer {
 	if outputTo == "" {
-		writeTo = os.Stdout
+		return os.Stdout
+	}
+
+	f, err := os.Create(outputTo)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// runValidate checks the manifests in the provided file against the attribute vocabulary
+func runValidate(args []string) error {
+	var file, attributesFile string
+	fs := flag.NewFlagSet(validateCommand, flag.ExitOnError)
+	fs.StringVar(&file, "f", "", validateFileDescription)
+	fs.StringVar(&file, "file", "", validateFileDescription)
+	fs.StringVar(&attributesFile, "attributes", "", validateAttributesDescription)
+	_ = fs.Parse(args)
+
+	if file == "" {
+		return errors.New("error missing parameter. -f is required")
+	}
+
+	manifests, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s - %v", file, err)
+	}
+
+	var attributes []byte
+	if attributesFile != "" {
+		attributes, err = ioutil.ReadFile(attributesFile)
+		if err != nil {
+			return fmt.Errorf("error reading %s - %v", attributesFile, err)
+		}
+	}
+
+	validator, err := kubernetes.NewValidator(attributes)
+	if err != nil {
+		return fmt.Errorf("error creating validator " + err.Error())
+	}
+
+	if errs := validator.Validate(manifests); len(errs) > 0 {
+		log.Println("Error validating configuration:")
+		for _, i := range errs {
+			fmt.Println(i.Error())
+		}
+		return fmt.Errorf("%s contains %d invalid field(s)", file, len(errs))
+	}
+
+	fmt.Printf("%s is valid\n", file)
+	return nil
+}
+
+// runSimulate evaluates a request against the mapping rules of a service, printing the outcome of each rule
+func runSimulate(args []string) error {
+	var configFile, environment, method, path string
+	fs := flag.NewFlagSet(simulateCommand, flag.ExitOnError)
+	fs.StringVar(&configFile, "config", "", simulateConfigDescription)
+	fs.StringVar(&svcID, "service", svcID, svcIDDescription)
+	fs.StringVar(&accessToken, "token", accessToken, tokenDescription)
+	fs.StringVar(&accessToken, "t", accessToken, tokenDescription+" (short)")
+	fs.StringVar(&threescaleURL, "url", threescaleURL, threescaleDescription)
+	fs.StringVar(&threescaleURL, "u", threescaleURL, threescaleDescription+" (short)")
+	fs.StringVar(&environment, "environment", "production", simulateEnvironmentDescription)
+	fs.StringVar(&method, "method", http.MethodGet, simulateMethodDescription)
+	fs.StringVar(&path, "path", "", simulatePathDescription)
+	_ = fs.Parse(args)
+
+	if path == "" {
+		return errors.New("error missing parameter. --path is required")
+	}
+
+	var proxyConf system.ProxyConfig
+	if configFile != "" {
+		b, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return fmt.Errorf("error reading %s - %v", configFile, err)
+		}
+
+		proxyConf, err = threescale.ParseProxyConfig(b)
+		if err != nil {
+			return err
+		}
 	} else {
-		f, err := os.Create(outputTo)
+		if svcID == "" || accessToken == "" || threescaleURL == "" {
+			return errors.New("error missing parameter. --service, --token and --url are required when --config is not set")
+		}
+
+		var err error
+		proxyConf, err = kubernetes.GetProxyConfig(accessToken, threescaleURL, svcID, environment, &http.Client{Timeout: time.Second * 10})
 		if err != nil {
-			panic(err)
+			return err
 		}
-		writeTo = f
 	}
 
-	return cg.OutputAll(writeTo)
+	printSimulation(os.Stdout, method, path, threescale.SimulateMappingRules(path, method, proxyConf))
+	return nil
+}
+
+func printSimulation(w io.Writer, method, path string, sim threescale.Simulation) {
+	fmt.Fprintf(w, "Evaluating %s %s against mapping rules in position order\n\n", strings.ToUpper(method), path)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "POSITION\tMETHOD\tPATTERN\tMETRIC\tDELTA\tLAST\tRESULT")
+	for _, eval := range sim.Evaluated {
+		result := "no match"
+		if eval.Err != nil {
+			result = fmt.Sprintf("invalid pattern - %v", eval.Err)
+		} else if eval.Matched {
+			result = "match"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%t\t%s\n", eval.Rule.Position, eval.Rule.HTTPMethod,
+			eval.Rule.Pattern, eval.Rule.MetricSystemName, eval.Rule.Delta, eval.Rule.Last, result)
+	}
+	for _, rule := range sim.Skipped {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%t\t%s\n", rule.Position, rule.HTTPMethod,
+			rule.Pattern, rule.MetricSystemName, rule.Delta, rule.Last, "not evaluated")
+	}
+	_ = tw.Flush()
+
+	if sim.StoppedBy != nil {
+		fmt.Fprintf(w, "\nEvaluation stopped at position %d (%s) as the rule is marked as last\n",
+			sim.StoppedBy.Position, sim.StoppedBy.Pattern)
+	}
+
+	if len(sim.Metrics) == 0 {
+		fmt.Fprintln(w, "\nNo mapping rule matches the request. The adapter would deny it")
+		return
+	}
+
+	var metrics []string
+	for metric := range sim.Metrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	fmt.Fprintln(w, "\nMetrics reported:")
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "  %s: %d\n", metric, sim.Metrics[metric])
+	}
 }
 
 func main() {
+	if flag.Arg(0) == simulateCommand {
+		if err := runSimulate(flag.Args()[1:]); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == validateCommand {
+		if err := runValidate(flag.Args()[1:]); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	errs := validate()
 	if errs != nil {
 		log.Println("Error validating input:")
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale/fake"
+)
+
+const (
+	configDescription  = "File describing the 3scale account to serve in JSON format (required)"
+	listenDescription  = "Address to serve the 3scale system and backend APIs on"
+	certDescription    = "TLS certificate file. If set along with --tls-key the APIs are served over TLS"
+	keyDescription     = "TLS private key file"
+	latencyDescription = "Delay added to every response, for example 250ms"
+)
+
+func main() {
+	var configFile, listenAddr, certFile, keyFile string
+	var latency time.Duration
+
+	flag.StringVar(&configFile, "config", "", configDescription)
+	flag.StringVar(&listenAddr, "listen", ":8090", listenDescription)
+	flag.StringVar(&certFile, "tls-cert", "", certDescription)
+	flag.StringVar(&keyFile, "tls-key", "", keyDescription)
+	flag.DurationVar(&latency, "latency", 0, latencyDescription)
+	flag.Parse()
+
+	if configFile == "" {
+		fmt.Println("error missing parameter. --config is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Open(configFile)
+	if err != nil {
+		log.Fatalf("error opening %s - %v", configFile, err)
+	}
+
+	conf, err := fake.LoadConfig(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("error loading %s - %v", configFile, err)
+	}
+
+	ts := fake.NewFromConfig(conf)
+	ts.SetLatency(latency)
+
+	server := &http.Server{Addr: listenAddr, Handler: ts}
+
+	go func() {
+		log.Printf("serving fake 3scale with %d services on %s", len(conf.Services), listenAddr)
+		var err error
+		if certFile != "" && keyFile != "" {
+			err = server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("error serving fake 3scale - %v", err)
+		}
+	}()
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	<-shutdown
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Fatalf("error shutting down - %v", err)
+	}
+}
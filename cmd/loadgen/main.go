@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale/fake"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+const (
+	addrDescription        = "Address of the adapter gRPC endpoint. If not set, an adapter is started in this process"
+	rpsDescription         = "Target number of requests per second"
+	durationDescription    = "Duration of the test"
+	concurrencyDescription = "Maximum number of requests in flight"
+	systemURLDescription   = "3scale system URL passed to the adapter. If not set, a fake 3scale is started in this process"
+	tokenDescription       = "3scale access token passed to the adapter"
+	serviceDescription     = "3scale service ID passed to the adapter"
+	userKeyDescription     = "User key sent with each request"
+	pathDescription        = "Request path sent with each request"
+	methodDescription      = "Request method sent with each request"
+	rulesDescription       = "Number of mapping rules configured for the fake 3scale service"
+	latencyDescription     = "Delay added to every response of the fake 3scale"
+
+	fakeAccessToken  = "secret-token"
+	fakeServiceID    = "1"
+	fakeServiceToken = "service-token"
+	fakeUserKey      = "VALID"
+)
+
+type options struct {
+	addr        string
+	rps         int
+	duration    time.Duration
+	concurrency int
+	systemURL   string
+	token       string
+	service     string
+	userKey     string
+	path        string
+	method      string
+	rules       int
+	latency     time.Duration
+}
+
+// result of a single authorization request
+type result struct {
+	latency time.Duration
+	code    rpc.Code
+	err     error
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.addr, "addr", "", addrDescription)
+	flag.IntVar(&opts.rps, "rps", 100, rpsDescription)
+	flag.DurationVar(&opts.duration, "duration", time.Second*10, durationDescription)
+	flag.IntVar(&opts.concurrency, "concurrency", 50, concurrencyDescription)
+	flag.StringVar(&opts.systemURL, "system-url", "", systemURLDescription)
+	flag.StringVar(&opts.token, "token", fakeAccessToken, tokenDescription)
+	flag.StringVar(&opts.service, "service", fakeServiceID, serviceDescription)
+	flag.StringVar(&opts.userKey, "user-key", fakeUserKey, userKeyDescription)
+	flag.StringVar(&opts.path, "path", "/", pathDescription)
+	flag.StringVar(&opts.method, "method", http.MethodGet, methodDescription)
+	flag.IntVar(&opts.rules, "rules", 10, rulesDescription)
+	flag.DurationVar(&opts.latency, "latency", 0, latencyDescription)
+	flag.Parse()
+
+	if opts.rps <= 0 || opts.concurrency <= 0 {
+		fmt.Println("error invalid parameter. --rps and --concurrency must be greater than zero")
+		os.Exit(1)
+	}
+
+	if opts.systemURL == "" {
+		ts := fake.New(fakeAccessToken, fakeService(opts.rules))
+		ts.SetLatency(opts.latency)
+		server := ts.NewServer()
+		defer server.Close()
+
+		opts.systemURL = server.URL
+		log.Printf("started fake 3scale on %s", server.URL)
+	}
+
+	if opts.addr == "" {
+		adapter, stop, err := startAdapter()
+		if err != nil {
+			log.Fatalf("error starting adapter - %v", err)
+		}
+		defer stop()
+
+		opts.addr = adapter
+		log.Printf("started adapter on %s", adapter)
+	}
+
+	conn, err := grpc.Dial(opts.addr, grpc.WithInsecure())
+	if err != nil {
+		log.Fatalf("error connecting to %s - %v", opts.addr, err)
+	}
+	defer conn.Close()
+
+	request, err := newRequest(opts)
+	if err != nil {
+		log.Fatalf("error building request - %v", err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	results, dropped, elapsed := run(authorization.NewHandleAuthorizationServiceClient(conn), request, opts)
+	runtime.ReadMemStats(&after)
+
+	report(os.Stdout, results, dropped, elapsed, before, after)
+}
+
+// run sends requests at the target rate until the duration has elapsed. Requests which cannot be sent
+// because the concurrency limit has been reached are dropped rather than queued, so that latency is not hidden.
+func run(client authorization.HandleAuthorizationServiceClient, request *authorization.HandleAuthorizationRequest, opts options) ([]result, int, time.Duration) {
+	var (
+		results []result
+		dropped int
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+	)
+
+	inFlight := make(chan struct{}, opts.concurrency)
+	ticker := time.NewTicker(time.Second / time.Duration(opts.rps))
+	defer ticker.Stop()
+
+	start := time.Now()
+	deadline := time.After(opts.duration)
+
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+			select {
+			case inFlight <- struct{}{}:
+			default:
+				dropped++
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-inFlight
+					wg.Done()
+				}()
+
+				sent := time.Now()
+				resp, err := client.HandleAuthorization(context.Background(), request)
+				r := result{latency: time.Since(sent), err: err}
+				if err == nil {
+					r.code = rpc.Code(resp.Status.Code)
+				}
+
+				mutex.Lock()
+				results = append(results, r)
+				mutex.Unlock()
+			}()
+		}
+	}
+
+	wg.Wait()
+	return results, dropped, time.Since(start)
+}
+
+func report(w io.Writer, results []result, dropped int, elapsed time.Duration, before, after runtime.MemStats) {
+	latencies := make([]time.Duration, 0, len(results))
+	codes := make(map[string]int)
+	for _, r := range results {
+		latencies = append(latencies, r.latency)
+		if r.err != nil {
+			codes["error"]++
+			continue
+		}
+		codes[r.code.String()]++
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Requests:\t%d\n", len(results))
+	fmt.Fprintf(tw, "Dropped:\t%d\n", dropped)
+	fmt.Fprintf(tw, "Duration:\t%s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "Throughput:\t%.1f req/s\n", float64(len(results))/elapsed.Seconds())
+
+	if len(latencies) > 0 {
+		fmt.Fprintf(tw, "Latency p50:\t%s\n", percentile(latencies, 50))
+		fmt.Fprintf(tw, "Latency p90:\t%s\n", percentile(latencies, 90))
+		fmt.Fprintf(tw, "Latency p99:\t%s\n", percentile(latencies, 99))
+		fmt.Fprintf(tw, "Latency max:\t%s\n", latencies[len(latencies)-1])
+
+		// allocations include the adapter and fake 3scale when they are started in this process
+		fmt.Fprintf(tw, "Allocs/request:\t%d\n", (after.Mallocs-before.Mallocs)/uint64(len(results)))
+		fmt.Fprintf(tw, "Bytes/request:\t%d\n", (after.TotalAlloc-before.TotalAlloc)/uint64(len(results)))
+	}
+
+	var names []string
+	for name := range codes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(tw, "Status %s:\t%d\n", name, codes[name])
+	}
+	_ = tw.Flush()
+}
+
+// percentile returns the pth percentile of the sorted latencies using the nearest rank method
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func newRequest(opts options) (*authorization.HandleAuthorizationRequest, error) {
+	params := config.Params{
+		ServiceId:   opts.service,
+		SystemUrl:   opts.systemURL,
+		AccessToken: opts.token,
+	}
+	b, err := params.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Name: "threescale.authorization",
+			Subject: &authorization.SubjectMsg{
+				User: opts.userKey,
+			},
+			Action: &authorization.ActionMsg{
+				Method: opts.method,
+				Path:   opts.path,
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	}, nil
+}
+
+// startAdapter with the default configuration of the adapter binary, returning its address and a function to stop it
+func startAdapter() (string, func(), error) {
+	mgr := authorizer.NewManager(
+		&http.Client{Timeout: time.Second * 10},
+		authorizer.NewSystemCache(authorizer.SystemCacheConfig{
+			MaxSize:               1000,
+			NumRetryFailedRefresh: 1,
+			RefreshInterval:       time.Second * 180,
+			TTL:                   time.Second * 300,
+		}, make(chan struct{})),
+		authorizer.BackendConfig{},
+		nil,
+	)
+
+	s, err := threescale.NewThreescale("0", &threescale.AdapterConfig{
+		Authorizer:      mgr,
+		KeepAliveMaxAge: time.Minute,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	shutdown := make(chan error, 1)
+	go s.Run(shutdown)
+
+	_, port, _ := net.SplitHostPort(s.Addr())
+	return net.JoinHostPort("127.0.0.1", port), func() {
+		_ = s.Close()
+		mgr.Shutdown()
+	}, nil
+}
+
+// fakeService with the provided number of mapping rules, where only the last rule matches any request.
+// The application has no limits so that requests are never denied during a test.
+func fakeService(rules int) fake.Service {
+	svc := fake.Service{
+		ID:    fakeServiceID,
+		Token: fakeServiceToken,
+		Applications: []fake.Application{
+			{UserKey: fakeUserKey},
+		},
+	}
+
+	for i := 1; i < rules; i++ {
+		svc.MappingRules = append(svc.MappingRules, fake.MappingRule{
+			Method:  http.MethodGet,
+			Pattern: fmt.Sprintf("^/unmatched/%d", i),
+			Metric:  "hits",
+			Delta:   1,
+		})
+	}
+	svc.MappingRules = append(svc.MappingRules, fake.MappingRule{
+		Method:  http.MethodGet,
+		Pattern: "/",
+		Metric:  "hits",
+		Delta:   1,
+	})
+	return svc
+}
@@ -0,0 +1,480 @@
+// +build e2e
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale/fake"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+const (
+	e2eAccessToken  = "secret-token"
+	e2eServiceToken = "service-token"
+	e2eServiceID    = "1"
+	e2eUserKey      = "VALID"
+
+	// anyDenial matches any status other than OK, for failures whose exact code is determined by the authorizer
+	anyDenial rpc.Code = -1
+)
+
+// tlsMode determines how the fake 3scale is served to the adapter
+type tlsMode int
+
+const (
+	plainText tlsMode = iota
+	serverTLS
+	mutualTLS
+)
+
+// e2eScenario describes the environment the adapter is started with and the requests made to it over gRPC
+type e2eScenario struct {
+	name string
+	// env variables read by the server binary configuration
+	env map[string]string
+	tls tlsMode
+	// withRootCA trusts the certificate of the fake 3scale via root_ca
+	withRootCA bool
+	// withClientCert presents a client certificate trusted by the fake 3scale via client_cert and client_key
+	withClientCert bool
+	// handler params sent with each request, in addition to those which identify the fake 3scale
+	handler config.Params
+	// setup is called before any request is made
+	setup   func(ts *fake.ThreeScale)
+	userKey string
+	// expectCodes contains the expected status of each request, made in order
+	expectCodes []rpc.Code
+	// expectCalls to endpoints of the fake 3scale once all requests have been made
+	expectCalls map[string]int
+	// expectUsage of hits by the application eventually reported to the fake 3scale
+	expectUsage int64
+}
+
+func TestE2EAuthorization(t *testing.T) {
+	inputs := []e2eScenario{
+		{
+			name:        "Test authorized request",
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{rpc.OK},
+			expectCalls: map[string]int{fake.ProxyConfigEndpoint: 1, fake.AuthRepEndpoint: 1},
+			expectUsage: 1,
+		},
+		{
+			name:        "Test invalid credentials are denied",
+			userKey:     "INVALID",
+			expectCodes: []rpc.Code{rpc.UNAUTHENTICATED},
+			expectCalls: map[string]int{fake.AuthRepEndpoint: 1},
+		},
+		{
+			name:        "Test system configuration is cached",
+			env:         map[string]string{"CACHE_TTL_SECONDS": "300", "CACHE_REFRESH_SECONDS": "180"},
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{rpc.OK, rpc.OK, rpc.OK},
+			expectCalls: map[string]int{fake.ProxyConfigEndpoint: 1, fake.AuthRepEndpoint: 3},
+			expectUsage: 3,
+		},
+		{
+			name:        "Test limits are enforced",
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{rpc.OK, rpc.OK, rpc.OK, anyDenial},
+			expectUsage: 3,
+		},
+		{
+			name: "Test backend cache authorizes locally and reports usage",
+			env: map[string]string{
+				"USE_CACHED_BACKEND":                   "true",
+				"BACKEND_CACHE_FLUSH_INTERVAL_SECONDS": "1",
+			},
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{rpc.OK, rpc.OK},
+			expectCalls: map[string]int{fake.AuthRepEndpoint: 0},
+			expectUsage: 2,
+		},
+		{
+			name: "Test fail open policy authorizes when backend is unavailable",
+			env: map[string]string{
+				"USE_CACHED_BACKEND":               "true",
+				"BACKEND_CACHE_POLICY_FAIL_CLOSED": "false",
+			},
+			setup:       func(ts *fake.ThreeScale) { ts.SetBackendDown(true) },
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{rpc.OK},
+		},
+		{
+			name: "Test fail closed policy denies when backend is unavailable",
+			env: map[string]string{
+				"USE_CACHED_BACKEND":               "true",
+				"BACKEND_CACHE_POLICY_FAIL_CLOSED": "true",
+			},
+			setup:       func(ts *fake.ThreeScale) { ts.SetBackendDown(true) },
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{anyDenial},
+		},
+		{
+			name: "Test handler overrides enable backend cache with fail open policy",
+			env: map[string]string{
+				"USE_CACHED_BACKEND":               "false",
+				"BACKEND_CACHE_POLICY_FAIL_CLOSED": "true",
+			},
+			handler:     config.Params{BackendCache: threescale.BackendCacheEnabled, FailurePolicy: threescale.FailurePolicyOpen},
+			setup:       func(ts *fake.ThreeScale) { ts.SetBackendDown(true) },
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{rpc.OK},
+		},
+		{
+			name:        "Test handler overrides disable backend cache",
+			env:         map[string]string{"USE_CACHED_BACKEND": "true"},
+			handler:     config.Params{BackendCache: threescale.BackendCacheDisabled},
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{rpc.OK, rpc.OK},
+			expectCalls: map[string]int{fake.AuthRepEndpoint: 2},
+			expectUsage: 2,
+		},
+		{
+			name:        "Test request fails when system is unavailable",
+			setup:       func(ts *fake.ThreeScale) { ts.SetSystemDown(true) },
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{anyDenial},
+			expectCalls: map[string]int{fake.AuthRepEndpoint: 0},
+		},
+		{
+			name:        "Test fail with untrusted server certificate",
+			tls:         serverTLS,
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{anyDenial},
+		},
+		{
+			name:        "Test server certificate trusted via root_ca",
+			tls:         serverTLS,
+			withRootCA:  true,
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{rpc.OK},
+			expectUsage: 1,
+		},
+		{
+			name:        "Test fail without client certificate when required",
+			tls:         mutualTLS,
+			withRootCA:  true,
+			userKey:     e2eUserKey,
+			expectCodes: []rpc.Code{anyDenial},
+		},
+		{
+			name:           "Test client certificate presented via client_cert",
+			tls:            mutualTLS,
+			withRootCA:     true,
+			withClientCert: true,
+			userKey:        e2eUserKey,
+			expectCodes:    []rpc.Code{rpc.OK},
+			expectUsage:    1,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			h := startE2EHarness(t, input)
+			defer h.close()
+
+			for i, expect := range input.expectCodes {
+				result, err := h.client.HandleAuthorization(context.Background(), h.request(input.userKey))
+				if err != nil {
+					t.Fatalf("unexpected error for request %d - %v", i, err)
+				}
+
+				code := rpc.Code(result.Status.Code)
+				if (expect == anyDenial && code == rpc.OK) || (expect != anyDenial && code != expect) {
+					t.Errorf("unexpected status for request %d, wanted %v but got %v - %s", i, expect, code, result.Status.Message)
+				}
+			}
+
+			for endpoint, calls := range input.expectCalls {
+				if got := h.threescale.Calls(endpoint); got != calls {
+					t.Errorf("expected %d calls to %s but got %d", calls, endpoint, got)
+				}
+			}
+
+			if input.expectUsage > 0 {
+				h.eventuallyUsage(t, input.expectUsage)
+			}
+		})
+	}
+}
+
+func TestE2EGracefulShutdown(t *testing.T) {
+	h := startE2EHarness(t, e2eScenario{})
+	defer h.close()
+
+	// hold requests in flight long enough for shutdown to begin
+	h.threescale.SetLatency(time.Millisecond * 500)
+
+	started := make(chan struct{})
+	done := make(chan rpc.Code, 1)
+	go func() {
+		close(started)
+		result, err := h.client.HandleAuthorization(context.Background(), h.request(e2eUserKey))
+		if err != nil {
+			t.Errorf("unexpected error for in flight request - %v", err)
+			done <- rpc.UNKNOWN
+			return
+		}
+		done <- rpc.Code(result.Status.Code)
+	}()
+
+	<-started
+	time.Sleep(time.Millisecond * 100)
+
+	if err := h.server.Close(); err != nil {
+		t.Fatalf("unexpected error closing server - %v", err)
+	}
+
+	if code := <-done; code != rpc.OK {
+		t.Errorf("expected in flight request to complete successfully but got %v", code)
+	}
+
+	select {
+	case err := <-h.shutdown:
+		if err != nil {
+			t.Errorf("expected graceful shutdown but got %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("timed out waiting for server to shut down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := h.client.HandleAuthorization(ctx, h.request(e2eUserKey)); err == nil {
+		t.Errorf("expected error for request after shutdown")
+	}
+}
+
+// e2eHarness is an adapter built from the server binary configuration, served over gRPC and backed by a fake 3scale
+type e2eHarness struct {
+	threescale *fake.ThreeScale
+	fakeServer *httptest.Server
+	authorizer threescale.Authorizer
+	server     threescale.Server
+	shutdown   chan error
+	conn       *grpc.ClientConn
+	client     authorization.HandleAuthorizationServiceClient
+	tmpDir     string
+	env        []string
+	// handler params sent with each request
+	handler config.Params
+}
+
+func startE2EHarness(t *testing.T, scenario e2eScenario) *e2eHarness {
+	t.Helper()
+
+	tmpDir, err := ioutil.TempDir("", "threescale-e2e")
+	if err != nil {
+		t.Fatalf("error creating temporary directory - %v", err)
+	}
+
+	h := &e2eHarness{
+		threescale: fake.New(e2eAccessToken, fake.Service{
+			ID:    e2eServiceID,
+			Token: e2eServiceToken,
+			MappingRules: []fake.MappingRule{
+				{Method: http.MethodGet, Pattern: "/", Metric: "hits", Delta: 1},
+			},
+			Applications: []fake.Application{
+				{
+					UserKey: e2eUserKey,
+					Limits:  []fake.Limit{{Metric: "hits", Period: fake.Hour, Value: 3}},
+				},
+			},
+		}),
+		tmpDir: tmpDir,
+	}
+
+	if scenario.setup != nil {
+		scenario.setup(h.threescale)
+	}
+
+	env := make(map[string]string)
+	for k, v := range scenario.env {
+		env[k] = v
+	}
+
+	clientCert, clientKey := generateClientCert(t)
+	switch scenario.tls {
+	case plainText:
+		h.fakeServer = h.threescale.NewServer()
+	case serverTLS:
+		h.fakeServer = h.threescale.NewTLSServer()
+	case mutualTLS:
+		pool := x509.NewCertPool()
+		block, _ := pem.Decode(clientCert)
+		cert, _ := x509.ParseCertificate(block.Bytes)
+		pool.AddCert(cert)
+
+		h.fakeServer = httptest.NewUnstartedServer(h.threescale)
+		h.fakeServer.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+		h.fakeServer.StartTLS()
+	}
+
+	if scenario.withRootCA {
+		env["ROOT_CA"] = h.writeFile(t, "ca.pem", pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: h.fakeServer.Certificate().Raw,
+		}))
+	}
+
+	if scenario.withClientCert {
+		env["CLIENT_CERT"] = h.writeFile(t, "client.pem", clientCert)
+		env["CLIENT_KEY"] = h.writeFile(t, "client-key.pem", clientKey)
+	}
+
+	for k, v := range env {
+		os.Setenv(k, v)
+		h.env = append(h.env, k)
+	}
+
+	httpClient := parseClientConfig()
+	h.authorizer = newManager(httpClient, threescale.AuthorizerOptions{}, nil, nil, nil)
+	h.handler = scenario.handler
+
+	h.server, err = threescale.NewThreescale("0", &threescale.AdapterConfig{
+		Authorizer:        h.authorizer,
+		AuthorizerFactory: newAuthorizerFactory(httpClient, nil, nil, nil),
+		KeepAliveMaxAge:   time.Minute,
+	})
+	if err != nil {
+		h.close()
+		t.Fatalf("error starting adapter - %v", err)
+	}
+
+	h.shutdown = make(chan error, 1)
+	go h.server.Run(h.shutdown)
+
+	_, port, _ := net.SplitHostPort(h.server.Addr())
+	h.conn, err = grpc.Dial(net.JoinHostPort("127.0.0.1", port), grpc.WithInsecure())
+	if err != nil {
+		h.close()
+		t.Fatalf("error connecting to adapter - %v", err)
+	}
+	h.client = authorization.NewHandleAuthorizationServiceClient(h.conn)
+
+	return h
+}
+
+// request builds an authorization request for the service of the fake 3scale
+func (h *e2eHarness) request(userKey string) *authorization.HandleAuthorizationRequest {
+	params := h.handler
+	params.ServiceId = e2eServiceID
+	params.SystemUrl = h.fakeServer.URL
+	params.AccessToken = e2eAccessToken
+	b, _ := params.Marshal()
+
+	return &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Name: "threescale.authorization",
+			Subject: &authorization.SubjectMsg{
+				User: userKey,
+			},
+			Action: &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/",
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	}
+}
+
+// eventuallyUsage waits for the usage of the application to be reported to the fake 3scale
+func (h *e2eHarness) eventuallyUsage(t *testing.T, expect int64) {
+	t.Helper()
+
+	var usage int64
+	for deadline := time.Now().Add(time.Second * 5); time.Now().Before(deadline); {
+		if usage = h.threescale.Usage(e2eServiceID, e2eUserKey, "hits", fake.Eternity); usage == expect {
+			return
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	t.Errorf("expected usage of %d to be reported but got %d", expect, usage)
+}
+
+func (h *e2eHarness) writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(h.tmpDir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("error writing %s - %v", path, err)
+	}
+	return path
+}
+
+func (h *e2eHarness) close() {
+	if h.conn != nil {
+		h.conn.Close()
+	}
+	if h.server != nil {
+		h.server.Close()
+	}
+	if h.authorizer != nil {
+		h.authorizer.Shutdown()
+	}
+	if h.fakeServer != nil {
+		h.fakeServer.Close()
+	}
+	for _, k := range h.env {
+		os.Unsetenv(k)
+	}
+	os.RemoveAll(h.tmpDir)
+}
+
+// generateClientCert returns a self signed client certificate and its private key in PEM format
+func generateClientCert(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key - %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "threescale-istio-adapter"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate - %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error encoding key - %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
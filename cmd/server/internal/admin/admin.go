@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+)
+
+// Paths of the admin API, served alongside the metrics endpoint
+const (
+	AccessListPath   = "/admin/access-list"
+	ServicesPath     = "/admin/services"
+	BackendCachePath = "/admin/backend-cache"
+)
+
+// NewHandler serves the admin API, authenticated by token
+func NewHandler(list *threescale.AccessList, caches *Caches, redactor *threescale.Redactor, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(AccessListPath, AccessListHandler(list, redactor))
+	mux.Handle(ServicesPath, ServicesHandler(caches))
+	mux.Handle(ServicesPath+"/", ServicesHandler(caches))
+	mux.Handle(BackendCachePath+"/", BackendCacheHandler(caches))
+	return WithToken(token, mux)
+}
+
+// WithToken requires requests to h to present the token as a bearer token. If token is empty, requests are not authenticated.
+func WithToken(token string, h http.Handler) http.Handler {
+	if token == "" {
+		return h
+	}
+
+	expect := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expect) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// AccessListHandler lists the entries of the access list which apply, with credentials redacted, on GET.
+// It adds the entry in the JSON body on POST, and removes the entry added at runtime for the type and credential
+// query parameters on DELETE.
+func AccessListHandler(list *threescale.AccessList, redactor *threescale.Redactor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			entries := list.Entries()
+			for i := range entries {
+				entries[i].Credential = redactor.Secret(entries[i].Credential)
+			}
+			writeJSON(w, http.StatusOK, entries)
+
+		case http.MethodPost:
+			entry := threescale.AccessListEntry{}
+			if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+				http.Error(w, fmt.Sprintf("error parsing entry - %v", err), http.StatusBadRequest)
+				return
+			}
+
+			if err := list.Add(entry); err != nil {
+				http.Error(w, fmt.Sprintf("invalid entry - %v", err), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case http.MethodDelete:
+			query := r.URL.Query()
+			if !list.Remove(query.Get("type"), query.Get("credential")) {
+				http.Error(w, "no entry added at runtime for credential", http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	// the status has been written so an encoding error cannot be reported
+	_ = json.NewEncoder(w).Encode(v)
+}
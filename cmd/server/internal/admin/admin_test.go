@@ -0,0 +1,101 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+)
+
+func TestWithToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	inputs := []struct {
+		name       string
+		token      string
+		header     string
+		expectCode int
+	}{
+		{
+			name:       "Test no token required",
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "Test valid token",
+			token:      "secret",
+			header:     "Bearer secret",
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "Test fail - invalid token",
+			token:      "secret",
+			header:     "Bearer other",
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test fail - missing token",
+			token:      "secret",
+			expectCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, AccessListPath, nil)
+			if input.header != "" {
+				r.Header.Set("Authorization", input.header)
+			}
+
+			w := httptest.NewRecorder()
+			WithToken(input.token, ok).ServeHTTP(w, r)
+			if w.Code != input.expectCode {
+				t.Errorf("expected status %d but got %d", input.expectCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestAccessListHandler(t *testing.T) {
+	list := threescale.NewAccessList()
+	redactor, _ := threescale.NewRedactor(threescale.RedactMask)
+	h := AccessListHandler(list, redactor)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := serve(http.MethodPost, AccessListPath, `{"type": "user_key", "credential": "leaked", "action": "deny"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status adding entry %d - %s", w.Code, w.Body.String())
+	}
+
+	if w := serve(http.MethodPost, AccessListPath, `{"type": "user_key", "action": "block"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected invalid entry to be rejected but got status %d", w.Code)
+	}
+
+	w := serve(http.MethodGet, AccessListPath, "")
+	var entries []threescale.AccessListEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("unexpected error parsing entries - %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Credential != "[REDACTED]" || entries[0].Action != threescale.AccessListDeny {
+		t.Errorf("expected entry with redacted credential but got %+v", entries)
+	}
+
+	if w := serve(http.MethodDelete, AccessListPath+"?type=user_key&credential=leaked", ""); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected status removing entry %d", w.Code)
+	}
+
+	if w := serve(http.MethodDelete, AccessListPath+"?type=user_key&credential=leaked", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected removed entry not to be found but got status %d", w.Code)
+	}
+
+	if w := serve(http.MethodPut, AccessListPath, ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed but got status %d", w.Code)
+	}
+}
@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+)
+
+// Caches are the caches of the authorizers created by the adapter, which are created as handlers are first used
+type Caches struct {
+	system  []*threescale.CachingAuthorizer
+	other   []threescale.Invalidator
+	backend []threescale.BackendCacheController
+	mutex   sync.RWMutex
+}
+
+// AddSystemCache registers the cache of proxy configurations of an authorizer
+func (c *Caches) AddSystemCache(cache *threescale.CachingAuthorizer) {
+	c.mutex.Lock()
+	c.system = append(c.system, cache)
+	c.mutex.Unlock()
+}
+
+// AddCache registers any other cache of an authorizer which holds proxy configurations or what is described by them,
+// such as pinned proxy configurations or the backends of products, if it can be invalidated.
+// It is invalidated along with the system caches but is not listed.
+func (c *Caches) AddCache(authz threescale.Authorizer) {
+	invalidator, ok := authz.(threescale.Invalidator)
+	if !ok {
+		return
+	}
+
+	c.mutex.Lock()
+	c.other = append(c.other, invalidator)
+	c.mutex.Unlock()
+}
+
+// AddBackendCache registers the backend cache of an authorizer, if it can be controlled
+func (c *Caches) AddBackendCache(authz threescale.Authorizer) {
+	controller, ok := authz.(threescale.BackendCacheController)
+	if !ok {
+		return
+	}
+
+	c.mutex.Lock()
+	c.backend = append(c.backend, controller)
+	c.mutex.Unlock()
+}
+
+func (c *Caches) systemCaches() []*threescale.CachingAuthorizer {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return append([]*threescale.CachingAuthorizer(nil), c.system...)
+}
+
+func (c *Caches) otherCaches() []threescale.Invalidator {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return append([]threescale.Invalidator(nil), c.other...)
+}
+
+func (c *Caches) backendCaches() []threescale.BackendCacheController {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return append([]threescale.BackendCacheController(nil), c.backend...)
+}
+
+// invalidateService invalidates the cached proxy configs of a service, or of all services if serviceID is empty,
+// along with what is cached from them. It returns the number of entries invalidated.
+func (c *Caches) invalidateService(serviceID string) int {
+	removed := 0
+	for _, cache := range c.systemCaches() {
+		removed += cache.Invalidate(serviceID)
+	}
+
+	for _, cache := range c.otherCaches() {
+		removed += cache.Invalidate(serviceID)
+	}
+	return removed
+}
+
+// invalidateApplication invalidates an application in the backend caches. It returns the number of caches invalidated.
+func (c *Caches) invalidateApplication(serviceID, application string) int {
+	controllers := c.backendCaches()
+	for _, controller := range controllers {
+		controller.InvalidateApplication(serviceID, application)
+	}
+	return len(controllers)
+}
+
+// ServicesHandler describes and invalidates cached proxy configurations:
+// GET on ServicesPath lists the cached services with the version and fetch time of their proxy config,
+// GET on ServicesPath/{id} also lists the mapping rules of the service in order of priority,
+// and DELETE invalidates the proxy configs of the service, or of all services.
+func ServicesHandler(caches *Caches) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serviceID := strings.Trim(strings.TrimPrefix(r.URL.Path, ServicesPath), "/")
+
+		switch r.Method {
+		case http.MethodGet:
+			services := []threescale.CachedService{}
+			for _, cache := range caches.systemCaches() {
+				if serviceID == "" {
+					services = append(services, cache.Services()...)
+				} else {
+					services = append(services, cache.Service(serviceID)...)
+				}
+			}
+
+			if serviceID != "" && len(services) == 0 {
+				http.Error(w, "service not cached", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, services)
+
+		case http.MethodDelete:
+			writeJSON(w, http.StatusOK, map[string]int{"invalidated": caches.invalidateService(serviceID)})
+
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// BackendCacheHandler controls the backend caches of authorizers which support it:
+// POST on BackendCachePath/flush reports cached usage to 3scale and GET on BackendCachePath/pending lists usage not yet reported.
+// If no backend cache can be controlled, it responds with 501 Not Implemented.
+func BackendCacheHandler(caches *Caches) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var method string
+		switch strings.TrimPrefix(r.URL.Path, BackendCachePath) {
+		case "/flush":
+			method = http.MethodPost
+		case "/pending":
+			method = http.MethodGet
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		controllers := caches.backendCaches()
+		if len(controllers) == 0 {
+			http.Error(w, "the backend cache is disabled or cannot be controlled", http.StatusNotImplemented)
+			return
+		}
+
+		if method == http.MethodPost {
+			for _, controller := range controllers {
+				controller.FlushBackendCache()
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		pending := []threescale.PendingUsage{}
+		for _, controller := range controllers {
+			pending = append(pending, controller.PendingUsage()...)
+		}
+		writeJSON(w, http.StatusOK, pending)
+	})
+}
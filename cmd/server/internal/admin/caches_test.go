@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// fakeAuthorizer serves an empty proxy config, holds a backend cache and records what it is asked to invalidate
+type fakeAuthorizer struct {
+	flushed      int
+	invalidated  []string
+	applications []string
+}
+
+func (f *fakeAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	return client.ProxyConfig{Environment: "production"}, nil
+}
+
+func (f *fakeAuthorizer) AuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return &authorizer.BackendResponse{Authorized: true}, nil
+}
+
+func (f *fakeAuthorizer) OauthAuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return f.AuthRep(backendURL, request)
+}
+
+func (f *fakeAuthorizer) Shutdown() {}
+
+func (f *fakeAuthorizer) Invalidate(serviceID string) int {
+	f.invalidated = append(f.invalidated, serviceID)
+	return 0
+}
+
+func (f *fakeAuthorizer) FlushBackendCache() {
+	f.flushed++
+}
+
+func (f *fakeAuthorizer) InvalidateApplication(serviceID, application string) {
+	f.applications = append(f.applications, serviceID+"/"+application)
+}
+
+func (f *fakeAuthorizer) PendingUsage() []threescale.PendingUsage {
+	return []threescale.PendingUsage{{ServiceID: "123", Application: "app", Metrics: api.Metrics{"hits": 2}}}
+}
+
+func TestServicesHandler(t *testing.T) {
+	cache := threescale.NewCachingAuthorizer(&fakeAuthorizer{}, time.Minute, time.Minute, 0)
+	products := &fakeAuthorizer{}
+	caches := &Caches{}
+	caches.AddSystemCache(cache)
+	caches.AddCache(products)
+	h := ServicesHandler(caches)
+
+	for _, serviceID := range []string{"123", "456"} {
+		cache.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: serviceID})
+	}
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	var services []threescale.CachedService
+	json.Unmarshal(serve(http.MethodGet, ServicesPath).Body.Bytes(), &services)
+	if len(services) != 2 || services[0].ServiceID != "123" || services[0].FetchedAt.IsZero() {
+		t.Errorf("unexpected cached services %+v", services)
+	}
+
+	if w := serve(http.MethodGet, ServicesPath+"/999"); w.Code != http.StatusNotFound {
+		t.Errorf("expected uncached service not to be found but got status %d", w.Code)
+	}
+
+	if w := serve(http.MethodDelete, ServicesPath+"/123"); w.Code != http.StatusOK || w.Body.String() != "{\"invalidated\":1}\n" {
+		t.Errorf("unexpected response invalidating service %d - %s", w.Code, w.Body.String())
+	}
+
+	if w := serve(http.MethodGet, ServicesPath+"/123"); w.Code != http.StatusNotFound {
+		t.Errorf("expected invalidated service not to be found but got status %d", w.Code)
+	}
+
+	if w := serve(http.MethodDelete, ServicesPath); w.Body.String() != "{\"invalidated\":1}\n" {
+		t.Errorf("unexpected response invalidating all services - %s", w.Body.String())
+	}
+
+	if !reflect.DeepEqual(products.invalidated, []string{"123", ""}) {
+		t.Errorf("expected other caches to be invalidated along with proxy configs but got %v", products.invalidated)
+	}
+}
+
+func TestBackendCacheHandler(t *testing.T) {
+	serve := func(caches *Caches, method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		BackendCacheHandler(caches).ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	if w := serve(&Caches{}, http.MethodPost, BackendCachePath+"/flush"); w.Code != http.StatusNotImplemented {
+		t.Errorf("expected flush to be unavailable without a controllable backend cache but got status %d", w.Code)
+	}
+
+	if w := serve(&Caches{}, http.MethodGet, BackendCachePath+"/pending"); w.Code != http.StatusNotImplemented {
+		t.Errorf("expected pending usage to be unavailable without a controllable backend cache but got status %d", w.Code)
+	}
+
+	authz := &fakeAuthorizer{}
+	caches := &Caches{}
+	caches.AddBackendCache(authz)
+
+	if w := serve(caches, http.MethodPost, BackendCachePath+"/flush"); w.Code != http.StatusNoContent || authz.flushed != 1 {
+		t.Errorf("expected backend cache to be flushed but got status %d", w.Code)
+	}
+
+	if w := serve(caches, http.MethodGet, BackendCachePath+"/flush"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed but got status %d", w.Code)
+	}
+
+	if w := serve(caches, http.MethodGet, BackendCachePath+"/other"); w.Code != http.StatusNotFound {
+		t.Errorf("expected not found but got status %d", w.Code)
+	}
+
+	var pending []threescale.PendingUsage
+	json.Unmarshal(serve(caches, http.MethodGet, BackendCachePath+"/pending").Body.Bytes(), &pending)
+	if len(pending) != 1 || pending[0].Metrics["hits"] != 2 {
+		t.Errorf("unexpected pending usage %+v", pending)
+	}
+}
@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// WebhookPath is the path at which 3scale webhooks are received
+	WebhookPath = "/webhooks/3scale"
+	// WebhookTokenHeader is the default header holding the shared token, as 3scale does not sign webhooks.
+	// The token is not accepted in the query string, which ends up in access logs.
+	WebhookTokenHeader = "X-3scale-Webhook-Token"
+
+	// maxWebhookSize bounds the body of a webhook, which describes a single object
+	maxWebhookSize = 1 << 20
+)
+
+// WebhookHandler receives 3scale webhooks and invalidates the proxy configs of services which were changed,
+// along with what is cached from them, and applications which were changed in the backend caches.
+// Application events are rejected with 501 Not Implemented if no backend cache can be controlled, so that they are
+// not silently left cached. If token is not empty, webhooks must present it in tokenHeader, or WebhookTokenHeader if empty.
+func WebhookHandler(caches *Caches, token, tokenHeader string, redactor *threescale.Redactor) http.Handler {
+	if tokenHeader == "" {
+		tokenHeader = WebhookTokenHeader
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(tokenHeader)), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error reading webhook - %v", err), http.StatusBadRequest)
+			return
+		}
+
+		event, err := threescale.ParseWebhook(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if event.ChangesApplication() && len(caches.backendCaches()) == 0 {
+			log.Warnf("rejected %s %s webhook for service %s and application %s - the backend cache cannot be invalidated",
+				event.Type, event.Action, event.ServiceID, redactor.Secret(event.Application))
+			http.Error(w, "the backend cache is disabled or cannot be controlled", http.StatusNotImplemented)
+			return
+		}
+
+		invalidated := map[string]int{"services": 0, "applications": 0}
+		if event.ChangesProxyConfig() {
+			invalidated["services"] = caches.invalidateService(event.ServiceID)
+		}
+
+		if event.ChangesApplication() {
+			invalidated["applications"] = caches.invalidateApplication(event.ServiceID, event.Application)
+		}
+
+		log.Infof("received %s %s webhook for service %s and application %s - invalidated %d cached entries and %d backend caches",
+			event.Type, event.Action, event.ServiceID, redactor.Secret(event.Application), invalidated["services"], invalidated["applications"])
+		writeJSON(w, http.StatusOK, map[string]map[string]int{"invalidated": invalidated})
+	})
+}
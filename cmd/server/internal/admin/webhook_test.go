@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	const token = "webhook-token"
+	const tokenHeader = "X-Webhook-Token"
+
+	inputs := []struct {
+		name               string
+		file               string
+		target             string
+		token              string
+		backendCache       bool
+		expectCode         int
+		expectInvalidated  []string
+		expectApplications []string
+		expectCachedConfig bool
+	}{
+		{
+			name:              "Test service change invalidates service",
+			file:              "service_updated.xml",
+			target:            WebhookPath,
+			token:             token,
+			expectCode:        http.StatusOK,
+			expectInvalidated: []string{"123"},
+		},
+		{
+			name:               "Test proxy config change of another service",
+			file:               "proxy_config_promoted.xml",
+			target:             WebhookPath,
+			token:              token,
+			expectCode:         http.StatusOK,
+			expectInvalidated:  []string{"456"},
+			expectCachedConfig: true,
+		},
+		{
+			name:               "Test application change invalidates application in backend cache",
+			file:               "application_updated.xml",
+			target:             WebhookPath,
+			token:              token,
+			backendCache:       true,
+			expectCode:         http.StatusOK,
+			expectApplications: []string{"123/1c3b5f0a9d7e4b2c8a6f3e1d0b9c8a7f"},
+			expectCachedConfig: true,
+		},
+		{
+			name:               "Test application ID change invalidates application in backend cache",
+			file:               "application_key_deleted.xml",
+			target:             WebhookPath,
+			token:              token,
+			backendCache:       true,
+			expectCode:         http.StatusOK,
+			expectApplications: []string{"456/b3c91f2e"},
+			expectCachedConfig: true,
+		},
+		{
+			name:               "Test fail - application change without controllable backend cache",
+			file:               "application_updated.xml",
+			target:             WebhookPath,
+			token:              token,
+			expectCode:         http.StatusNotImplemented,
+			expectCachedConfig: true,
+		},
+		{
+			name:               "Test event without service invalidates nothing",
+			file:               "account_created.xml",
+			target:             WebhookPath,
+			token:              token,
+			expectCode:         http.StatusOK,
+			expectCachedConfig: true,
+		},
+		{
+			name:               "Test fail - invalid token",
+			file:               "service_updated.xml",
+			target:             WebhookPath,
+			token:              "other",
+			expectCode:         http.StatusUnauthorized,
+			expectCachedConfig: true,
+		},
+		{
+			name:               "Test fail - token in query string",
+			file:               "service_updated.xml",
+			target:             WebhookPath + "?token=" + token,
+			expectCode:         http.StatusUnauthorized,
+			expectCachedConfig: true,
+		},
+		{
+			name:               "Test fail - missing token",
+			file:               "service_updated.xml",
+			target:             WebhookPath,
+			expectCode:         http.StatusUnauthorized,
+			expectCachedConfig: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			body, err := ioutil.ReadFile(filepath.Join("../../../../testdata/webhooks", input.file))
+			if err != nil {
+				t.Fatalf("error reading testdata file - %v", err)
+			}
+
+			authz := &fakeAuthorizer{}
+			cache := threescale.NewCachingAuthorizer(authz, time.Minute, time.Minute, 0)
+			cache.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: "123"})
+
+			caches := &Caches{}
+			caches.AddSystemCache(cache)
+			caches.AddCache(authz)
+			if input.backendCache {
+				caches.AddBackendCache(authz)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, input.target, bytes.NewReader(body))
+			if input.token != "" {
+				r.Header.Set(tokenHeader, input.token)
+			}
+			w := httptest.NewRecorder()
+			WebhookHandler(caches, token, tokenHeader, nil).ServeHTTP(w, r)
+
+			if w.Code != input.expectCode {
+				t.Fatalf("expected status %d but got %d - %s", input.expectCode, w.Code, w.Body.String())
+			}
+
+			if !reflect.DeepEqual(authz.invalidated, input.expectInvalidated) {
+				t.Errorf("expected services %v to be invalidated but got %v", input.expectInvalidated, authz.invalidated)
+			}
+
+			if !reflect.DeepEqual(authz.applications, input.expectApplications) {
+				t.Errorf("expected applications %v to be invalidated but got %v", input.expectApplications, authz.applications)
+			}
+
+			if cached := len(cache.Service("123")) == 1; cached != input.expectCachedConfig {
+				t.Errorf("expected proxy config of service 123 to be cached to be %v", input.expectCachedConfig)
+			}
+		})
+	}
+}
+
+func TestWebhookHandlerUnauthenticated(t *testing.T) {
+	w := httptest.NewRecorder()
+	WebhookHandler(&Caches{}, "", "", nil).ServeHTTP(w, httptest.NewRequest(http.MethodPost, WebhookPath, bytes.NewReader([]byte("<event"))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected invalid webhook to be rejected but got status %d", w.Code)
+	}
+}
+
+func TestWebhookHandlerDefaultTokenHeader(t *testing.T) {
+	body, err := ioutil.ReadFile("../../../../testdata/webhooks/account_created.xml")
+	if err != nil {
+		t.Fatalf("error reading testdata file - %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, WebhookPath, bytes.NewReader(body))
+	r.Header.Set(WebhookTokenHeader, "webhook-token")
+	w := httptest.NewRecorder()
+	WebhookHandler(&Caches{}, "webhook-token", "", nil).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected token in default header to be accepted but got status %d", w.Code)
+	}
+}
@@ -46,6 +46,30 @@ var (
 			Help: "Total number of requests to 3scale backend fetched from cache",
 		},
 	)
+
+	systemCacheWarmup = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_system_cache_warmup_total",
+			Help: "Total number of services whose 3scale system configuration was fetched on startup, by result",
+		},
+		[]string{"result"},
+	)
+
+	shadowDenials = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_shadow_denials_total",
+			Help: "Total number of requests allowed by handlers in shadow or report-only mode which would have been denied, by reason",
+		},
+		[]string{"service_id", "mode", "reason"},
+	)
+
+	accessListDecisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_access_list_decisions_total",
+			Help: "Total number of requests allowed or denied by the local access list, by credential type and reason",
+		},
+		[]string{"credential_type", "reason"},
+	)
 )
 
 func ReportCB(tr authorizer.TelemetryReport) {
@@ -64,8 +88,28 @@ func IncrementCacheHits(cache authorizer.Cache) {
 	cacheHitsBackend.Inc()
 }
 
+// IncrementSystemCacheWarmup increments services whose proxy configuration has been fetched on startup by result
+func IncrementSystemCacheWarmup(err error) {
+	if err != nil {
+		systemCacheWarmup.WithLabelValues("failure").Inc()
+		return
+	}
+	systemCacheWarmup.WithLabelValues("success").Inc()
+}
+
+// IncrementShadowDenials increments requests which would have been denied by a handler which does not enforce decisions
+func IncrementShadowDenials(serviceID string, mode string, reason string) {
+	shadowDenials.WithLabelValues(serviceID, mode, reason).Inc()
+}
+
+// IncrementAccessListDecisions increments requests allowed or denied by the local access list
+func IncrementAccessListDecisions(credentialType string, reason string) {
+	accessListDecisions.WithLabelValues(credentialType, reason).Inc()
+}
+
 func Register() {
-	prometheus.MustRegister(threescaleLatency, threescaleHTTP, cacheHitsSystem, cacheHitsBackend)
+	prometheus.MustRegister(threescaleLatency, threescaleHTTP, cacheHitsSystem, cacheHitsBackend, systemCacheWarmup, shadowDenials,
+		accessListDecisions)
 }
 
 func GetHandler() http.Handler {
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"testing"
@@ -77,3 +78,44 @@ func TestIncrementCacheHits(t *testing.T) {
 		t.Errorf("unexpected counter value for %s", backendCollector.Desc().String())
 	}
 }
+
+func TestIncrementSystemCacheWarmup(t *testing.T) {
+	IncrementSystemCacheWarmup(nil)
+	IncrementSystemCacheWarmup(nil)
+	IncrementSystemCacheWarmup(errors.New("unavailable"))
+
+	if got := testutil.ToFloat64(systemCacheWarmup.WithLabelValues("success")); got != 2 {
+		t.Errorf("unexpected success count %v", got)
+	}
+
+	if got := testutil.ToFloat64(systemCacheWarmup.WithLabelValues("failure")); got != 1 {
+		t.Errorf("unexpected failure count %v", got)
+	}
+}
+
+func TestIncrementShadowDenials(t *testing.T) {
+	IncrementShadowDenials("123", "shadow", "limits_exceeded")
+	IncrementShadowDenials("123", "shadow", "limits_exceeded")
+	IncrementShadowDenials("123", "report-only", "UNAVAILABLE")
+
+	if got := testutil.ToFloat64(shadowDenials.WithLabelValues("123", "shadow", "limits_exceeded")); got != 2 {
+		t.Errorf("unexpected shadow denial count %v", got)
+	}
+
+	if got := testutil.ToFloat64(shadowDenials.WithLabelValues("123", "report-only", "UNAVAILABLE")); got != 1 {
+		t.Errorf("unexpected report-only denial count %v", got)
+	}
+}
+
+func TestIncrementAccessListDecisions(t *testing.T) {
+	IncrementAccessListDecisions("user_key", "access_list_denied")
+	IncrementAccessListDecisions("app_id", "access_list_allowed")
+
+	if got := testutil.ToFloat64(accessListDecisions.WithLabelValues("user_key", "access_list_denied")); got != 1 {
+		t.Errorf("unexpected denied count %v", got)
+	}
+
+	if got := testutil.ToFloat64(accessListDecisions.WithLabelValues("app_id", "access_list_allowed")); got != 1 {
+		t.Errorf("unexpected allowed count %v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -10,13 +11,18 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/3scale/3scale-authorizer/pkg/authorizer"
 	"github.com/3scale/3scale-authorizer/pkg/backend/v1"
+	"github.com/3scale/3scale-istio-adapter/cmd/server/internal/admin"
 	"github.com/3scale/3scale-istio-adapter/cmd/server/internal/metrics"
+	"github.com/3scale/3scale-istio-adapter/pkg/kubernetes"
 	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	"github.com/ghodss/yaml"
 	"github.com/spf13/viper"
 
 	"google.golang.org/grpc/grpclog"
@@ -38,12 +44,27 @@ const (
 	defaultMetricsPort     = 8080
 
 	defaultBackendCacheFlushInterval = time.Second * 15
+
+	defaultSystemCacheWarmupTimeout = time.Second * 15
+	threescaleAdapterName           = "threescale"
+
+	configSourceSystem    = "system"
+	configSourceFile      = "file"
+	configSourceConfigMap = "configmap"
+
+	defaultConfigSourceRefreshInterval = time.Second * 30
+
+	// the access list is used during incidents so it is reloaded more often than proxy configs
+	defaultAccessListRefreshInterval = time.Second * 5
+
+	defaultCacheSnapshotMaxStaleness = time.Hour * 24
 )
 
 func init() {
 	_ = viper.BindEnv("log_level")
 	_ = viper.BindEnv("log_json")
 	_ = viper.BindEnv("log_grpc")
+	_ = viper.BindEnv("log_redaction")
 	_ = viper.BindEnv("listen_addr")
 	_ = viper.BindEnv("report_metrics")
 	_ = viper.BindEnv("metrics_port")
@@ -51,6 +72,28 @@ func init() {
 	_ = viper.BindEnv("cache_ttl_seconds")
 	_ = viper.BindEnv("cache_refresh_seconds")
 	_ = viper.BindEnv("cache_entries_max")
+	_ = viper.BindEnv("cache_snapshot_file")
+	_ = viper.BindEnv("cache_snapshot_max_staleness_seconds")
+
+	_ = viper.BindEnv("system_cache_warmup_file")
+	_ = viper.BindEnv("system_cache_warmup_discover")
+	_ = viper.BindEnv("system_cache_warmup_timeout_seconds")
+
+	_ = viper.BindEnv("config_source")
+	_ = viper.BindEnv("config_source_dir")
+	_ = viper.BindEnv("config_source_refresh_seconds")
+	_ = viper.BindEnv("config_source_configmap")
+
+	_ = viper.BindEnv("backend_routing")
+
+	_ = viper.BindEnv("access_list_file")
+	_ = viper.BindEnv("access_list_refresh_seconds")
+	_ = viper.BindEnv("access_list_configmap")
+
+	_ = viper.BindEnv("admin_token")
+	_ = viper.BindEnv("webhook_enabled")
+	_ = viper.BindEnv("webhook_token")
+	_ = viper.BindEnv("webhook_token_header")
 
 	_ = viper.BindEnv("client_timeout_seconds")
 	_ = viper.BindEnv("allow_insecure_conn")
@@ -100,27 +143,44 @@ func stringToLogLevel(loglevel string) log.Level {
 	return log.InfoLevel
 }
 
+// redactingScope scrubs credentials and tokens from messages logged via its formatted logging methods
+type redactingScope struct {
+	*log.Scope
+	redactor *threescale.Redactor
+}
+
+func (s redactingScope) Debugf(template string, args ...interface{}) {
+	s.Scope.Debugf("%s", s.redactor.String(fmt.Sprintf(template, args...)))
+}
+
+func (s redactingScope) Infof(template string, args ...interface{}) {
+	s.Scope.Infof("%s", s.redactor.String(fmt.Sprintf(template, args...)))
+}
+
+func (s redactingScope) Warnf(template string, args ...interface{}) {
+	s.Scope.Warnf("%s", s.redactor.String(fmt.Sprintf(template, args...)))
+}
+
+func (s redactingScope) Errorf(template string, args ...interface{}) {
+	s.Scope.Errorf("%s", s.redactor.String(fmt.Sprintf(template, args...)))
+}
+
+func parseRedactionConfig() *threescale.Redactor {
+	redactor, err := threescale.NewRedactor(viper.GetString("log_redaction"))
+	if err != nil {
+		log.Fatalf("invalid log_redaction - %v", err)
+	}
+	return redactor
+}
+
 func parseMetricsConfig() *authorizer.MetricsReporter {
 	if !viper.IsSet("report_metrics") || !viper.GetBool("report_metrics") {
 		return nil
 	}
 
-	port := defaultMetricsPort
-	if viper.IsSet("metrics_port") {
-		port = viper.GetInt("metrics_port")
-	}
-
 	metrics.Register()
 	http.Handle(defaultMetricsEndpoint, metrics.GetHandler())
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	if err != nil {
-		log.Fatalf("failed to start metrics server %v", err)
-	}
-	go func() {
-		// always returns a non-nil error
-		_ = http.Serve(listener, nil)
-	}()
-	log.Infof("Serving metrics on port %d", port)
+	log.Infof("Serving metrics at %s", defaultMetricsEndpoint)
 
 	return &authorizer.MetricsReporter{
 		ReportMetrics: true,
@@ -129,6 +189,11 @@ func parseMetricsConfig() *authorizer.MetricsReporter {
 	}
 }
 
+// recordShadowDenial counts requests which would have been denied by handlers which do not enforce decisions
+func recordShadowDenial(denial threescale.ShadowDenial) {
+	metrics.IncrementShadowDenials(denial.ServiceID, denial.Mode, denial.Reason)
+}
+
 func parseClientConfig() *http.Client {
 	c := &http.Client{
 		// Setting some sensible default here for http timeouts
@@ -200,7 +265,34 @@ func parseClientConfig() *http.Client {
 	return c
 }
 
-func createSystemCache() *authorizer.SystemCache {
+// newManager creates an authorizer with caches configured from the environment, with any non-zero overrides applied.
+// If caches is not nil, proxy configs are cached by the adapter in place of the system cache so that they can be
+// managed with the admin API, and the caches are registered. Secrets are scrubbed from its logs by the redactor.
+func newManager(client *http.Client, opts threescale.AuthorizerOptions, reporter *authorizer.MetricsReporter, caches *admin.Caches, redactor *threescale.Redactor) threescale.Authorizer {
+	config := systemCacheConfig(opts)
+	if caches == nil {
+		return authorizer.NewManager(client, authorizer.NewSystemCache(config, make(chan struct{})), createBackendConfig(opts, redactor), reporter)
+	}
+
+	disabled := config
+	disabled.MaxSize = 0
+	mgr := authorizer.NewManager(client, authorizer.NewSystemCache(disabled, make(chan struct{})), createBackendConfig(opts, redactor), reporter)
+	// the backend cache of the manager cannot be controlled yet, in which case this is a no-op and the admin API responds 501
+	caches.AddBackendCache(mgr)
+
+	// a max size of zero disables caching
+	if config.MaxSize == 0 {
+		return mgr
+	}
+
+	cache := threescale.NewCachingAuthorizer(mgr, config.TTL, config.RefreshInterval, config.MaxSize)
+	cache.Redactor = redactor
+	caches.AddSystemCache(cache)
+	return cache
+}
+
+// systemCacheConfig configured from the environment, with any non-zero overrides applied
+func systemCacheConfig(opts threescale.AuthorizerOptions) authorizer.SystemCacheConfig {
 	cacheTTL := defaultSystemCacheTTLSeconds
 	cacheEntriesMax := defaultSystemCacheSize
 	cacheUpdateRetries := defaultSystemCacheRetries
@@ -229,13 +321,30 @@ func createSystemCache() *authorizer.SystemCache {
 		TTL:                   time.Duration(cacheTTL) * time.Second,
 	}
 
-	return authorizer.NewSystemCache(config, make(chan struct{}))
+	if opts.SystemCacheTTL > 0 {
+		config.TTL = opts.SystemCacheTTL
+	}
+
+	if opts.SystemCacheRefreshInterval > 0 {
+		config.RefreshInterval = opts.SystemCacheRefreshInterval
+	}
+
+	return config
 }
 
-func createBackendConfig() authorizer.BackendConfig {
-	logger := log.FindScope(log.DefaultScopeName)
+// createBackendConfig configured from the environment, with any non-zero overrides applied
+func createBackendConfig(opts threescale.AuthorizerOptions, redactor *threescale.Redactor) authorizer.BackendConfig {
+	logger := redactingScope{
+		Scope:    log.FindScope(log.DefaultScopeName),
+		redactor: redactor,
+	}
+
+	useCache := viper.GetBool("use_cached_backend")
+	if opts.BackendCache != "" {
+		useCache = opts.BackendCache == threescale.BackendCacheEnabled
+	}
 
-	if viper.GetBool("use_cached_backend") {
+	if useCache {
 		interval := time.Second * time.Duration(viper.GetInt("backend_cache_flush_interval_seconds"))
 		if interval == 0 {
 			interval = defaultBackendCacheFlushInterval
@@ -247,7 +356,7 @@ func createBackendConfig() authorizer.BackendConfig {
 			EnableCaching:      true,
 			CacheFlushInterval: interval,
 			Logger:             logger,
-			Policy:             getFailurePolicy(),
+			Policy:             getFailurePolicy(opts.FailurePolicy),
 		}
 	}
 
@@ -256,11 +365,21 @@ func createBackendConfig() authorizer.BackendConfig {
 	}
 }
 
-func getFailurePolicy() backend.FailurePolicy {
+// getFailurePolicy returns the policy named by override, or the policy configured in the environment if empty
+func getFailurePolicy(override string) backend.FailurePolicy {
 	policy := backend.FailClosedPolicy
 
-	if viper.IsSet("backend_cache_policy_fail_closed") && !viper.GetBool("backend_cache_policy_fail_closed") {
+	switch override {
+	case threescale.FailurePolicyOpen:
 		policy = backend.FailOpenPolicy
+	case threescale.FailurePolicyClosed:
+	default:
+		if viper.IsSet("backend_cache_policy_fail_closed") && !viper.GetBool("backend_cache_policy_fail_closed") {
+			policy = backend.FailOpenPolicy
+		}
+	}
+
+	if policy == backend.FailOpenPolicy {
 		log.Infof("backend cache fail policy set to open")
 	} else {
 		log.Infof("backend cache fail policy set to closed")
@@ -269,6 +388,416 @@ func getFailurePolicy() backend.FailurePolicy {
 	return policy
 }
 
+// newAuthorizerFactory returns a factory for the authorizers of handlers which override the default behaviour.
+// Authorizers share the http client, metrics reporter and redactor of the default authorizer.
+func newAuthorizerFactory(client *http.Client, reporter *authorizer.MetricsReporter, caches *admin.Caches, redactor *threescale.Redactor) threescale.AuthorizerFactory {
+	return func(opts threescale.AuthorizerOptions) (threescale.Authorizer, error) {
+		authz := newManager(client, opts, reporter, caches, redactor)
+		if opts.ProxyConfigVersion > 0 {
+			pinned := threescale.NewPinnedAuthorizer(authz, opts.ProxyConfigVersion, client)
+			if caches != nil {
+				caches.AddCache(pinned)
+			}
+			authz = pinned
+		}
+		return withBackendRouting(authz, opts, client, caches), nil
+	}
+}
+
+// withBackendRouting wraps the authorizer to route requests to products to their backends, if enabled in the environment.
+// Products are cached for as long as the system cache holds the proxy config they are described by.
+// If caches is not nil, products are invalidated along with the proxy configs of their service.
+func withBackendRouting(authz threescale.Authorizer, opts threescale.AuthorizerOptions, client *http.Client, caches *admin.Caches) threescale.Authorizer {
+	if !viper.GetBool("backend_routing") {
+		return authz
+	}
+
+	var ttl time.Duration
+	if opts.ProxyConfigVersion == 0 {
+		ttl = time.Duration(defaultSystemCacheTTLSeconds) * time.Second
+		if viper.IsSet("cache_ttl_seconds") {
+			ttl = time.Duration(viper.GetInt("cache_ttl_seconds")) * time.Second
+		}
+
+		if opts.SystemCacheTTL > 0 {
+			ttl = opts.SystemCacheTTL
+		}
+	}
+
+	products := threescale.NewProductAuthorizer(authz, opts.ProxyConfigVersion, ttl, client)
+	if caches != nil {
+		caches.AddCache(products)
+	}
+	return products
+}
+
+// getWarmupTargets returns the services whose system configuration should be cached on startup,
+// read from the file set in the environment and, if enabled, discovered from the handlers for this adapter
+func getWarmupTargets() []threescale.WarmupTarget {
+	var targets []threescale.WarmupTarget
+
+	if viper.IsSet("system_cache_warmup_file") {
+		path := viper.GetString("system_cache_warmup_file")
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Errorf("failed to read system cache warm up file %s - %v", path, err)
+		} else if err := yaml.Unmarshal(b, &targets); err != nil {
+			log.Errorf("failed to parse system cache warm up file %s - %v", path, err)
+		}
+	}
+
+	if viper.GetBool("system_cache_warmup_discover") {
+		client, err := kubernetes.NewIstioClient("", nil)
+		if err != nil {
+			log.Errorf("failed to create client to discover handlers - %v", err)
+			return targets
+		}
+
+		handlers, err := client.ListHandlers(threescaleAdapterName, "")
+		if err != nil {
+			log.Errorf("failed to discover handlers - %v", err)
+			return targets
+		}
+		targets = append(targets, warmupTargetsFromHandlers(handlers)...)
+	}
+
+	return targets
+}
+
+// warmupTargetsFromHandlers groups the services of handlers by system url, access token and the overrides of the handler.
+// Handlers which do not set a service id are skipped since their services are only known at request time.
+func warmupTargetsFromHandlers(handlers []kubernetes.HandlerSpec) []threescale.WarmupTarget {
+	type targetKey struct {
+		systemURL   string
+		accessToken string
+		opts        threescale.AuthorizerOptions
+	}
+
+	var targets []threescale.WarmupTarget
+	index := make(map[targetKey]int)
+
+	for _, handler := range handlers {
+		params := handler.Params
+		if params.ServiceId == "" {
+			continue
+		}
+
+		key := targetKey{params.SystemUrl, params.AccessToken, threescale.AuthorizerOptionsFromConfig(&params)}
+		i, ok := index[key]
+		if !ok {
+			i = len(targets)
+			index[key] = i
+			targets = append(targets, threescale.WarmupTarget{
+				SystemURL:   params.SystemUrl,
+				AccessToken: params.AccessToken,
+				Options:     key.opts,
+			})
+		}
+		targets[i].ServiceIDs = append(targets[i].ServiceIDs, params.ServiceId)
+	}
+
+	return targets
+}
+
+// warmedAuthorizers creates the authorizers of handlers which override the default behaviour to warm up their caches,
+// and hands them to the adapter when it first asks for them rather than creating them again
+type warmedAuthorizers struct {
+	factory     threescale.AuthorizerFactory
+	authorizers map[threescale.AuthorizerOptions]threescale.Authorizer
+	mutex       sync.Mutex
+}
+
+// get returns the authorizer for opts, creating it if needed
+func (w *warmedAuthorizers) get(opts threescale.AuthorizerOptions) (threescale.Authorizer, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if authz, ok := w.authorizers[opts]; ok {
+		return authz, nil
+	}
+
+	authz, err := w.factory(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.authorizers == nil {
+		w.authorizers = make(map[threescale.AuthorizerOptions]threescale.Authorizer)
+	}
+	w.authorizers[opts] = authz
+	return authz, nil
+}
+
+// take is the factory of the adapter. An authorizer created during warm up is handed over once, after which it is owned
+// by the adapter, which keeps it for all handlers with the same overrides.
+func (w *warmedAuthorizers) take(opts threescale.AuthorizerOptions) (threescale.Authorizer, error) {
+	w.mutex.Lock()
+	authz, ok := w.authorizers[opts]
+	delete(w.authorizers, opts)
+	w.mutex.Unlock()
+
+	if ok {
+		return authz, nil
+	}
+	return w.factory(opts)
+}
+
+// Shutdown shuts down the authorizers created during warm up which were never handed to the adapter
+func (w *warmedAuthorizers) Shutdown() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, authz := range w.authorizers {
+		authz.Shutdown()
+	}
+	w.authorizers = nil
+}
+
+// warmSystemCache caches the system configuration of the services configured for warm up before returning,
+// giving up on those not fetched within the configured timeout. Services of handlers which override the default
+// behaviour are warmed through the authorizer for their overrides, created by authorizers.
+func warmSystemCache(authz threescale.Authorizer, authorizers *warmedAuthorizers, redactor *threescale.Redactor) {
+	targets := getWarmupTargets()
+
+	var total int
+	for _, target := range targets {
+		total += len(target.ServiceIDs)
+	}
+
+	if total == 0 {
+		return
+	}
+
+	timeout := defaultSystemCacheWarmupTimeout
+	if viper.IsSet("system_cache_warmup_timeout_seconds") {
+		timeout = time.Duration(viper.GetInt("system_cache_warmup_timeout_seconds")) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Infof("warming system cache for %d services", total)
+
+	var done int32
+	authorizerFor := func(opts threescale.AuthorizerOptions) (threescale.Authorizer, error) {
+		if opts == (threescale.AuthorizerOptions{}) {
+			return authz, nil
+		}
+		return authorizers.get(opts)
+	}
+
+	warmed, failed := threescale.WarmSystemCache(ctx, authorizerFor, targets, func(systemURL string, serviceID string, err error) {
+		metrics.IncrementSystemCacheWarmup(err)
+
+		n := atomic.AddInt32(&done, 1)
+		if err != nil {
+			log.Warnf("%s", redactor.String(fmt.Sprintf("failed to warm system cache for service %s of %s (%d/%d) - %v", serviceID, systemURL, n, total, err)))
+			return
+		}
+		log.Debugf("%s", redactor.String(fmt.Sprintf("warmed system cache for service %s of %s (%d/%d)", serviceID, systemURL, n, total)))
+	})
+
+	log.Infof("system cache warm up finished - %d warmed, %d failed, %d timed out", warmed, failed, total-warmed-failed)
+}
+
+// createConfigSource returns the source of proxy configurations set in the environment,
+// or nil if they should be fetched from 3scale system. Static sources are kept up to date until stop is closed.
+func createConfigSource(stop <-chan struct{}) threescale.ConfigSource {
+	kind := strings.ToLower(viper.GetString("config_source"))
+
+	switch kind {
+	case "", configSourceSystem:
+		return nil
+
+	case configSourceFile:
+		dir := viper.GetString("config_source_dir")
+		source := threescale.NewStaticConfigSource()
+		if err := source.LoadDir(dir); err != nil {
+			log.Fatalf("failed to load proxy configs from %s - %v", dir, err)
+		}
+
+		refresh := defaultConfigSourceRefreshInterval
+		if viper.IsSet("config_source_refresh_seconds") {
+			refresh = time.Duration(viper.GetInt("config_source_refresh_seconds")) * time.Second
+		}
+
+		if refresh > 0 {
+			go func() {
+				ticker := time.NewTicker(refresh)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+						if err := source.LoadDir(dir); err != nil {
+							log.Errorf("failed to reload proxy configs from %s - %v", dir, err)
+						}
+					}
+				}
+			}()
+		}
+		return source
+
+	case configSourceConfigMap:
+		ref := viper.GetString("config_source_configmap")
+		parts := strings.Split(ref, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Fatalf("invalid proxy config map %q - expected namespace/name", ref)
+		}
+
+		client, err := kubernetes.NewK8Client("", nil)
+		if err != nil {
+			log.Fatalf("failed to create client to watch proxy config map - %v", err)
+		}
+
+		source := threescale.NewStaticConfigSource()
+		onError := func(err error) {
+			log.Errorf("%v", err)
+		}
+		if err := client.WatchProxyConfigs(parts[1], parts[0], source, onError, stop); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return source
+
+	default:
+		log.Fatalf("unsupported config source %q - expected one of %s, %s or %s", kind, configSourceSystem, configSourceFile, configSourceConfigMap)
+		return nil
+	}
+}
+
+// createAccessList loads the access list from a file or ConfigMap, if set, and keeps it up to date until stop is closed
+func createAccessList(stop <-chan struct{}) *threescale.AccessList {
+	list := threescale.NewAccessList()
+
+	if viper.IsSet("access_list_file") {
+		path := viper.GetString("access_list_file")
+		if err := list.LoadFile(path); err != nil {
+			log.Fatalf("failed to load access list - %v", err)
+		}
+
+		refresh := defaultAccessListRefreshInterval
+		if viper.IsSet("access_list_refresh_seconds") {
+			refresh = time.Duration(viper.GetInt("access_list_refresh_seconds")) * time.Second
+		}
+
+		if refresh > 0 {
+			go func() {
+				ticker := time.NewTicker(refresh)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+						if err := list.LoadFile(path); err != nil {
+							log.Errorf("failed to reload access list - %v", err)
+						}
+					}
+				}
+			}()
+		}
+	}
+
+	if viper.IsSet("access_list_configmap") {
+		ref := viper.GetString("access_list_configmap")
+		parts := strings.Split(ref, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Fatalf("invalid access list config map %q - expected namespace/name", ref)
+		}
+
+		client, err := kubernetes.NewK8Client("", nil)
+		if err != nil {
+			log.Fatalf("failed to create client to watch access list config map - %v", err)
+		}
+
+		onError := func(err error) {
+			log.Errorf("%v", err)
+		}
+		if err := client.WatchAccessList(parts[1], parts[0], list, onError, stop); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	return list
+}
+
+// recordAccessListDecision counts requests allowed or denied by the access list
+func recordAccessListDecision(decision threescale.AccessListDecision) {
+	metrics.IncrementAccessListDecisions(decision.CredentialType, decision.Reason)
+}
+
+// parseAdminConfig returns the caches to be managed by the admin API and webhook receiver, or nil if both are disabled.
+// Caches are also returned when a snapshot is enabled, as the snapshot is written by the cache of the adapter.
+func parseAdminConfig() *admin.Caches {
+	if viper.GetString("admin_token") == "" && !viper.GetBool("webhook_enabled") && viper.GetString("cache_snapshot_file") == "" {
+		return nil
+	}
+	return &admin.Caches{}
+}
+
+// restoreSnapshot restores the proxy configs cached by the authorizer from the snapshot file, if configured,
+// and persists them to it from then on
+func restoreSnapshot(authz threescale.Authorizer) {
+	path := viper.GetString("cache_snapshot_file")
+	if path == "" {
+		return
+	}
+
+	cache, ok := authz.(*threescale.CachingAuthorizer)
+	if !ok {
+		log.Warnf("caching is disabled, ignoring snapshot %s", path)
+		return
+	}
+
+	maxStaleness := defaultCacheSnapshotMaxStaleness
+	if viper.IsSet("cache_snapshot_max_staleness_seconds") {
+		maxStaleness = time.Duration(viper.GetInt("cache_snapshot_max_staleness_seconds")) * time.Second
+	}
+
+	restored, err := cache.UseSnapshot(path, maxStaleness)
+	if err != nil {
+		log.Errorf("failed to restore proxy configs from snapshot - %v", err)
+		return
+	}
+	log.Infof("restored %d proxy configs from snapshot %s", restored, path)
+}
+
+// serveHTTP serves the metrics endpoint, admin API and webhook receiver, if enabled, on the metrics port
+func serveHTTP(list *threescale.AccessList, caches *admin.Caches, redactor *threescale.Redactor, reportMetrics bool) {
+	serveAdmin := false
+	if token := viper.GetString("admin_token"); token != "" {
+		http.Handle("/admin/", admin.NewHandler(list, caches, redactor, token))
+		log.Infof("Serving admin API at /admin/")
+		serveAdmin = true
+	}
+
+	if viper.GetBool("webhook_enabled") {
+		http.Handle(admin.WebhookPath, admin.WebhookHandler(caches, viper.GetString("webhook_token"), viper.GetString("webhook_token_header"), redactor))
+		log.Infof("Receiving 3scale webhooks at %s", admin.WebhookPath)
+		serveAdmin = true
+	}
+
+	if !reportMetrics && !serveAdmin {
+		return
+	}
+
+	port := defaultMetricsPort
+	if viper.IsSet("metrics_port") {
+		port = viper.GetInt("metrics_port")
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Fatalf("failed to start metrics server %v", err)
+	}
+	go func() {
+		// always returns a non-nil error
+		_ = http.Serve(listener, nil)
+	}()
+	log.Infof("Serving HTTP on port %d", port)
+}
+
 func main() {
 	var addr string
 
@@ -283,16 +812,48 @@ func main() {
 		grpcKeepAliveFor = time.Second * time.Duration(viper.GetInt("grpc_conn_max_seconds"))
 	}
 
-	authorizerMgr := authorizer.NewManager(
-		parseClientConfig(),
-		createSystemCache(),
-		createBackendConfig(),
-		parseMetricsConfig(),
-	)
+	httpClient := parseClientConfig()
+	metricsReporter := parseMetricsConfig()
+
+	redactor := parseRedactionConfig()
+
+	caches := parseAdminConfig()
+	authorizerMgr := newManager(httpClient, threescale.AuthorizerOptions{}, metricsReporter, caches, redactor)
+	restoreSnapshot(authorizerMgr)
+
+	stopConfigSource := make(chan struct{})
+	configSource := createConfigSource(stopConfigSource)
+
+	accessList := createAccessList(stopConfigSource)
+	serveHTTP(accessList, caches, redactor, metricsReporter != nil)
+
+	authorizers := &warmedAuthorizers{factory: newAuthorizerFactory(httpClient, metricsReporter, caches, redactor)}
+
+	// the adapter only starts listening once warm up is done so that Mixer is not routed to a cold cache
+	if configSource == nil {
+		warmSystemCache(authorizerMgr, authorizers, redactor)
+	}
+
+	// the index of hosts used to resolve services is rebuilt as often as the system cache is refreshed
+	hostIndexRefreshInterval := time.Duration(defaultSystemCacheRefreshIntervalSeconds) * time.Second
+	if viper.IsSet("cache_refresh_seconds") {
+		hostIndexRefreshInterval = time.Duration(viper.GetInt("cache_refresh_seconds")) * time.Second
+	}
+
+	// the default authorizer is shut down through its outermost wrapper, so that every layer is reached
+	authz := withBackendRouting(authorizerMgr, threescale.AuthorizerOptions{}, httpClient, caches)
 
 	adapterConf := &threescale.AdapterConfig{
-		Authorizer:      authorizerMgr,
-		KeepAliveMaxAge: grpcKeepAliveFor,
+		Authorizer:               authz,
+		AuthorizerFactory:        authorizers.take,
+		ConfigSource:             configSource,
+		HTTPClient:               httpClient,
+		HostIndexRefreshInterval: hostIndexRefreshInterval,
+		AccessList:               accessList,
+		OnAccessListDecision:     recordAccessListDecision,
+		OnShadowDenial:           recordShadowDenial,
+		Redactor:                 redactor,
+		KeepAliveMaxAge:          grpcKeepAliveFor,
 	}
 
 	s, err := threescale.NewThreescale(addr, adapterConf)
@@ -316,7 +877,9 @@ func main() {
 		select {
 		case sig := <-sigC:
 			log.Infof("\n%s received. Attempting graceful shutdown\n", sig.String())
-			authorizerMgr.Shutdown()
+			authz.Shutdown()
+			authorizers.Shutdown()
+			close(stopConfigSource)
 			err := s.Close()
 			if err != nil {
 				log.Fatalf("Error calling graceful shutdown")
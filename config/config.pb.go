@@ -19,6 +19,8 @@ import fmt "fmt"
 import math "math"
 import _ "github.com/gogo/protobuf/gogoproto"
 
+import github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
+
 import strings "strings"
 import reflect "reflect"
 
@@ -45,6 +47,34 @@ type Params struct {
 	AccessToken string `protobuf:"bytes,3,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
 	// Url to connect to 3scale backend - optional - override system config to use internal dns etc..
 	BackendUrl string `protobuf:"bytes,4,opt,name=backend_url,json=backendUrl,proto3" json:"backend_url,omitempty"`
+	// Time to live in seconds for cached 3scale system configuration - optional - overrides the adapter default
+	CacheTtlSeconds int64 `protobuf:"varint,5,opt,name=cache_ttl_seconds,json=cacheTtlSeconds,proto3" json:"cache_ttl_seconds,omitempty"`
+	// Interval in seconds at which cached 3scale system configuration is refreshed - optional - overrides the adapter default
+	CacheRefreshSeconds int64 `protobuf:"varint,6,opt,name=cache_refresh_seconds,json=cacheRefreshSeconds,proto3" json:"cache_refresh_seconds,omitempty"`
+	// Authorize against a local cache of 3scale backend, one of "enabled" or "disabled" - optional - overrides the adapter default
+	BackendCache string `protobuf:"bytes,7,opt,name=backend_cache,json=backendCache,proto3" json:"backend_cache,omitempty"`
+	// Behaviour of the backend cache when 3scale backend is unavailable, one of "open" or "closed" - optional - overrides the adapter default
+	FailurePolicy string `protobuf:"bytes,8,opt,name=failure_policy,json=failurePolicy,proto3" json:"failure_policy,omitempty"`
+	// Credential preferred when a request provides credentials for both the API key and application ID patterns, one of "user_key" or "app_id" - optional - by default only the credential required by the backend_version of the service is accepted
+	CredentialPrecedence string `protobuf:"bytes,9,opt,name=credential_precedence,json=credentialPrecedence,proto3" json:"credential_precedence,omitempty"`
+	// Status returned when 3scale denies a request or fails, keyed by 3scale error code (for example "application_not_active") or HTTP status code (for example "503") and naming a gRPC code (for example "FAILED_PRECONDITION") - optional - overrides the adapter default
+	StatusOverrides map[string]string `protobuf:"bytes,10,rep,name=status_overrides,json=statusOverrides" json:"status_overrides,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Templates of the body returned when 3scale denies a request, keyed by 3scale error code or "default" for any other error code - optional
+	DenialBodies map[string]string `protobuf:"bytes,11,rep,name=denial_bodies,json=denialBodies" json:"denial_bodies,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Templates of the headers returned when 3scale denies a request, keyed by header name, or by 3scale error code and header name separated by a colon (for example "limits_exceeded:Retry-After") - optional
+	DenialHeaders map[string]string `protobuf:"bytes,12,rep,name=denial_headers,json=denialHeaders" json:"denial_headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Content type of the body returned when 3scale denies a request - optional - defaults to "application/json"
+	DenialContentType string `protobuf:"bytes,13,opt,name=denial_content_type,json=denialContentType,proto3" json:"denial_content_type,omitempty"`
+	// 3scale environment to fetch the proxy configuration from, one of "production" or "staging" - optional - defaults to "production"
+	Environment string `protobuf:"bytes,14,opt,name=environment,json=environment,proto3" json:"environment,omitempty"`
+	// Version of the proxy configuration to pin the service to - optional - by default the latest version is used
+	ProxyConfigVersion int64 `protobuf:"varint,15,opt,name=proxy_config_version,json=proxyConfigVersion,proto3" json:"proxy_config_version,omitempty"`
+	// Enforcement of authorization decisions, one of "enforce", "shadow" or "report-only" - optional - defaults to "enforce".
+	// In "shadow" and "report-only" modes requests are authorized against 3scale but always allowed and would-be denials are logged,
+	// with usage reported to 3scale only in "report-only" mode
+	Mode string `protobuf:"bytes,16,opt,name=mode,json=mode,proto3" json:"mode,omitempty"`
+	// Percentage of requests, from 0 to 100, whose authorization decision is enforced in "shadow" and "report-only" modes - optional - defaults to 0
+	EnforcePercentage int64 `protobuf:"varint,17,opt,name=enforce_percentage,json=enforcePercentage,proto3" json:"enforce_percentage,omitempty"`
 }
 
 func (m *Params) Reset()                    { *m = Params{} }
@@ -79,8 +109,102 @@ func (m *Params) GetBackendUrl() string {
 	return ""
 }
 
+func (m *Params) GetCacheTtlSeconds() int64 {
+	if m != nil {
+		return m.CacheTtlSeconds
+	}
+	return 0
+}
+
+func (m *Params) GetCacheRefreshSeconds() int64 {
+	if m != nil {
+		return m.CacheRefreshSeconds
+	}
+	return 0
+}
+
+func (m *Params) GetBackendCache() string {
+	if m != nil {
+		return m.BackendCache
+	}
+	return ""
+}
+
+func (m *Params) GetFailurePolicy() string {
+	if m != nil {
+		return m.FailurePolicy
+	}
+	return ""
+}
+
+func (m *Params) GetCredentialPrecedence() string {
+	if m != nil {
+		return m.CredentialPrecedence
+	}
+	return ""
+}
+
+func (m *Params) GetStatusOverrides() map[string]string {
+	if m != nil {
+		return m.StatusOverrides
+	}
+	return nil
+}
+
+func (m *Params) GetDenialBodies() map[string]string {
+	if m != nil {
+		return m.DenialBodies
+	}
+	return nil
+}
+
+func (m *Params) GetDenialHeaders() map[string]string {
+	if m != nil {
+		return m.DenialHeaders
+	}
+	return nil
+}
+
+func (m *Params) GetDenialContentType() string {
+	if m != nil {
+		return m.DenialContentType
+	}
+	return ""
+}
+
+func (m *Params) GetEnvironment() string {
+	if m != nil {
+		return m.Environment
+	}
+	return ""
+}
+
+func (m *Params) GetProxyConfigVersion() int64 {
+	if m != nil {
+		return m.ProxyConfigVersion
+	}
+	return 0
+}
+
+func (m *Params) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+func (m *Params) GetEnforcePercentage() int64 {
+	if m != nil {
+		return m.EnforcePercentage
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Params)(nil), "adapter.threescale.config.Params")
+	proto.RegisterMapType((map[string]string)(nil), "adapter.threescale.config.Params.StatusOverridesEntry")
+	proto.RegisterMapType((map[string]string)(nil), "adapter.threescale.config.Params.DenialBodiesEntry")
+	proto.RegisterMapType((map[string]string)(nil), "adapter.threescale.config.Params.DenialHeadersEntry")
 }
 func (this *Params) Equal(that interface{}) bool {
 	if that == nil {
@@ -113,18 +237,121 @@ func (this *Params) Equal(that interface{}) bool {
 	if this.BackendUrl != that1.BackendUrl {
 		return false
 	}
+	if this.CacheTtlSeconds != that1.CacheTtlSeconds {
+		return false
+	}
+	if this.CacheRefreshSeconds != that1.CacheRefreshSeconds {
+		return false
+	}
+	if this.BackendCache != that1.BackendCache {
+		return false
+	}
+	if this.FailurePolicy != that1.FailurePolicy {
+		return false
+	}
+	if this.CredentialPrecedence != that1.CredentialPrecedence {
+		return false
+	}
+	if len(this.StatusOverrides) != len(that1.StatusOverrides) {
+		return false
+	}
+	for i := range this.StatusOverrides {
+		if this.StatusOverrides[i] != that1.StatusOverrides[i] {
+			return false
+		}
+	}
+	if len(this.DenialBodies) != len(that1.DenialBodies) {
+		return false
+	}
+	for i := range this.DenialBodies {
+		if this.DenialBodies[i] != that1.DenialBodies[i] {
+			return false
+		}
+	}
+	if len(this.DenialHeaders) != len(that1.DenialHeaders) {
+		return false
+	}
+	for i := range this.DenialHeaders {
+		if this.DenialHeaders[i] != that1.DenialHeaders[i] {
+			return false
+		}
+	}
+	if this.DenialContentType != that1.DenialContentType {
+		return false
+	}
+	if this.Environment != that1.Environment {
+		return false
+	}
+	if this.ProxyConfigVersion != that1.ProxyConfigVersion {
+		return false
+	}
+	if this.Mode != that1.Mode {
+		return false
+	}
+	if this.EnforcePercentage != that1.EnforcePercentage {
+		return false
+	}
 	return true
 }
 func (this *Params) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 21)
 	s = append(s, "&config.Params{")
 	s = append(s, "ServiceId: "+fmt.Sprintf("%#v", this.ServiceId)+",\n")
 	s = append(s, "SystemUrl: "+fmt.Sprintf("%#v", this.SystemUrl)+",\n")
 	s = append(s, "AccessToken: "+fmt.Sprintf("%#v", this.AccessToken)+",\n")
 	s = append(s, "BackendUrl: "+fmt.Sprintf("%#v", this.BackendUrl)+",\n")
+	s = append(s, "CacheTtlSeconds: "+fmt.Sprintf("%#v", this.CacheTtlSeconds)+",\n")
+	s = append(s, "CacheRefreshSeconds: "+fmt.Sprintf("%#v", this.CacheRefreshSeconds)+",\n")
+	s = append(s, "BackendCache: "+fmt.Sprintf("%#v", this.BackendCache)+",\n")
+	s = append(s, "FailurePolicy: "+fmt.Sprintf("%#v", this.FailurePolicy)+",\n")
+	s = append(s, "CredentialPrecedence: "+fmt.Sprintf("%#v", this.CredentialPrecedence)+",\n")
+	keysForStatusOverrides := make([]string, 0, len(this.StatusOverrides))
+	for k, _ := range this.StatusOverrides {
+		keysForStatusOverrides = append(keysForStatusOverrides, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForStatusOverrides)
+	mapStringForStatusOverrides := "map[string]string{"
+	for _, k := range keysForStatusOverrides {
+		mapStringForStatusOverrides += fmt.Sprintf("%#v: %#v,", k, this.StatusOverrides[k])
+	}
+	mapStringForStatusOverrides += "}"
+	if this.StatusOverrides != nil {
+		s = append(s, "StatusOverrides: "+mapStringForStatusOverrides+",\n")
+	}
+	keysForDenialBodies := make([]string, 0, len(this.DenialBodies))
+	for k, _ := range this.DenialBodies {
+		keysForDenialBodies = append(keysForDenialBodies, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForDenialBodies)
+	mapStringForDenialBodies := "map[string]string{"
+	for _, k := range keysForDenialBodies {
+		mapStringForDenialBodies += fmt.Sprintf("%#v: %#v,", k, this.DenialBodies[k])
+	}
+	mapStringForDenialBodies += "}"
+	if this.DenialBodies != nil {
+		s = append(s, "DenialBodies: "+mapStringForDenialBodies+",\n")
+	}
+	keysForDenialHeaders := make([]string, 0, len(this.DenialHeaders))
+	for k, _ := range this.DenialHeaders {
+		keysForDenialHeaders = append(keysForDenialHeaders, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForDenialHeaders)
+	mapStringForDenialHeaders := "map[string]string{"
+	for _, k := range keysForDenialHeaders {
+		mapStringForDenialHeaders += fmt.Sprintf("%#v: %#v,", k, this.DenialHeaders[k])
+	}
+	mapStringForDenialHeaders += "}"
+	if this.DenialHeaders != nil {
+		s = append(s, "DenialHeaders: "+mapStringForDenialHeaders+",\n")
+	}
+	s = append(s, "DenialContentType: "+fmt.Sprintf("%#v", this.DenialContentType)+",\n")
+	s = append(s, "Environment: "+fmt.Sprintf("%#v", this.Environment)+",\n")
+	s = append(s, "ProxyConfigVersion: "+fmt.Sprintf("%#v", this.ProxyConfigVersion)+",\n")
+	s = append(s, "Mode: "+fmt.Sprintf("%#v", this.Mode)+",\n")
+	s = append(s, "EnforcePercentage: "+fmt.Sprintf("%#v", this.EnforcePercentage)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -175,6 +402,117 @@ func (m *Params) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintConfig(dAtA, i, uint64(len(m.BackendUrl)))
 		i += copy(dAtA[i:], m.BackendUrl)
 	}
+	if m.CacheTtlSeconds != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.CacheTtlSeconds))
+	}
+	if m.CacheRefreshSeconds != 0 {
+		dAtA[i] = 0x30
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.CacheRefreshSeconds))
+	}
+	if len(m.BackendCache) > 0 {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.BackendCache)))
+		i += copy(dAtA[i:], m.BackendCache)
+	}
+	if len(m.FailurePolicy) > 0 {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.FailurePolicy)))
+		i += copy(dAtA[i:], m.FailurePolicy)
+	}
+	if len(m.CredentialPrecedence) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.CredentialPrecedence)))
+		i += copy(dAtA[i:], m.CredentialPrecedence)
+	}
+	if len(m.StatusOverrides) > 0 {
+		for k, _ := range m.StatusOverrides {
+			dAtA[i] = 0x52
+			i++
+			v := m.StatusOverrides[k]
+			mapSize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			i = encodeVarintConfig(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	if len(m.DenialBodies) > 0 {
+		for k, _ := range m.DenialBodies {
+			dAtA[i] = 0x5a
+			i++
+			v := m.DenialBodies[k]
+			mapSize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			i = encodeVarintConfig(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	if len(m.DenialHeaders) > 0 {
+		for k, _ := range m.DenialHeaders {
+			dAtA[i] = 0x62
+			i++
+			v := m.DenialHeaders[k]
+			mapSize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			i = encodeVarintConfig(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	if len(m.DenialContentType) > 0 {
+		dAtA[i] = 0x6a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.DenialContentType)))
+		i += copy(dAtA[i:], m.DenialContentType)
+	}
+	if len(m.Environment) > 0 {
+		dAtA[i] = 0x72
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.Environment)))
+		i += copy(dAtA[i:], m.Environment)
+	}
+	if m.ProxyConfigVersion != 0 {
+		dAtA[i] = 0x78
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.ProxyConfigVersion))
+	}
+	if len(m.Mode) > 0 {
+		dAtA[i] = 0x82
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.Mode)))
+		i += copy(dAtA[i:], m.Mode)
+	}
+	if m.EnforcePercentage != 0 {
+		dAtA[i] = 0x88
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.EnforcePercentage))
+	}
 	return i, nil
 }
 
@@ -206,6 +544,66 @@ func (m *Params) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovConfig(uint64(l))
 	}
+	if m.CacheTtlSeconds != 0 {
+		n += 1 + sovConfig(uint64(m.CacheTtlSeconds))
+	}
+	if m.CacheRefreshSeconds != 0 {
+		n += 1 + sovConfig(uint64(m.CacheRefreshSeconds))
+	}
+	l = len(m.BackendCache)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.FailurePolicy)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.CredentialPrecedence)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if len(m.StatusOverrides) > 0 {
+		for k, v := range m.StatusOverrides {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			n += mapEntrySize + 1 + sovConfig(uint64(mapEntrySize))
+		}
+	}
+	if len(m.DenialBodies) > 0 {
+		for k, v := range m.DenialBodies {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			n += mapEntrySize + 1 + sovConfig(uint64(mapEntrySize))
+		}
+	}
+	if len(m.DenialHeaders) > 0 {
+		for k, v := range m.DenialHeaders {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			n += mapEntrySize + 1 + sovConfig(uint64(mapEntrySize))
+		}
+	}
+	l = len(m.DenialContentType)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.Environment)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if m.ProxyConfigVersion != 0 {
+		n += 1 + sovConfig(uint64(m.ProxyConfigVersion))
+	}
+	l = len(m.Mode)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	if m.EnforcePercentage != 0 {
+		n += 2 + sovConfig(uint64(m.EnforcePercentage))
+	}
 	return n
 }
 
@@ -226,11 +624,54 @@ func (this *Params) String() string {
 	if this == nil {
 		return "nil"
 	}
+	keysForStatusOverrides := make([]string, 0, len(this.StatusOverrides))
+	for k, _ := range this.StatusOverrides {
+		keysForStatusOverrides = append(keysForStatusOverrides, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForStatusOverrides)
+	mapStringForStatusOverrides := "map[string]string{"
+	for _, k := range keysForStatusOverrides {
+		mapStringForStatusOverrides += fmt.Sprintf("%v: %v,", k, this.StatusOverrides[k])
+	}
+	mapStringForStatusOverrides += "}"
+	keysForDenialBodies := make([]string, 0, len(this.DenialBodies))
+	for k, _ := range this.DenialBodies {
+		keysForDenialBodies = append(keysForDenialBodies, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForDenialBodies)
+	mapStringForDenialBodies := "map[string]string{"
+	for _, k := range keysForDenialBodies {
+		mapStringForDenialBodies += fmt.Sprintf("%v: %v,", k, this.DenialBodies[k])
+	}
+	mapStringForDenialBodies += "}"
+	keysForDenialHeaders := make([]string, 0, len(this.DenialHeaders))
+	for k, _ := range this.DenialHeaders {
+		keysForDenialHeaders = append(keysForDenialHeaders, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForDenialHeaders)
+	mapStringForDenialHeaders := "map[string]string{"
+	for _, k := range keysForDenialHeaders {
+		mapStringForDenialHeaders += fmt.Sprintf("%v: %v,", k, this.DenialHeaders[k])
+	}
+	mapStringForDenialHeaders += "}"
 	s := strings.Join([]string{`&Params{`,
 		`ServiceId:` + fmt.Sprintf("%v", this.ServiceId) + `,`,
 		`SystemUrl:` + fmt.Sprintf("%v", this.SystemUrl) + `,`,
 		`AccessToken:` + fmt.Sprintf("%v", this.AccessToken) + `,`,
 		`BackendUrl:` + fmt.Sprintf("%v", this.BackendUrl) + `,`,
+		`CacheTtlSeconds:` + fmt.Sprintf("%v", this.CacheTtlSeconds) + `,`,
+		`CacheRefreshSeconds:` + fmt.Sprintf("%v", this.CacheRefreshSeconds) + `,`,
+		`BackendCache:` + fmt.Sprintf("%v", this.BackendCache) + `,`,
+		`FailurePolicy:` + fmt.Sprintf("%v", this.FailurePolicy) + `,`,
+		`CredentialPrecedence:` + fmt.Sprintf("%v", this.CredentialPrecedence) + `,`,
+		`StatusOverrides:` + mapStringForStatusOverrides + `,`,
+		`DenialBodies:` + mapStringForDenialBodies + `,`,
+		`DenialHeaders:` + mapStringForDenialHeaders + `,`,
+		`DenialContentType:` + fmt.Sprintf("%v", this.DenialContentType) + `,`,
+		`Environment:` + fmt.Sprintf("%v", this.Environment) + `,`,
+		`ProxyConfigVersion:` + fmt.Sprintf("%v", this.ProxyConfigVersion) + `,`,
+		`Mode:` + fmt.Sprintf("%v", this.Mode) + `,`,
+		`EnforcePercentage:` + fmt.Sprintf("%v", this.EnforcePercentage) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -388,6 +829,610 @@ func (m *Params) Unmarshal(dAtA []byte) error {
 			}
 			m.BackendUrl = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CacheTtlSeconds", wireType)
+			}
+			m.CacheTtlSeconds = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CacheTtlSeconds |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CacheRefreshSeconds", wireType)
+			}
+			m.CacheRefreshSeconds = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CacheRefreshSeconds |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BackendCache", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BackendCache = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FailurePolicy", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FailurePolicy = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CredentialPrecedence", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CredentialPrecedence = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StatusOverrides", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.StatusOverrides == nil {
+				m.StatusOverrides = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowConfig
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipConfig(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthConfig
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.StatusOverrides[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DenialBodies", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.DenialBodies == nil {
+				m.DenialBodies = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowConfig
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipConfig(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthConfig
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.DenialBodies[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DenialHeaders", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.DenialHeaders == nil {
+				m.DenialHeaders = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowConfig
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipConfig(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthConfig
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.DenialHeaders[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DenialContentType", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DenialContentType = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Environment", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Environment = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProxyConfigVersion", wireType)
+			}
+			m.ProxyConfigVersion = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ProxyConfigVersion |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Mode", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Mode = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 17:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EnforcePercentage", wireType)
+			}
+			m.EnforcePercentage = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.EnforcePercentage |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipConfig(dAtA[iNdEx:])
@@ -519,22 +1564,45 @@ func init() {
 }
 
 var fileDescriptorConfig = []byte{
-	// 258 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x34, 0x8f, 0xbd, 0x4e, 0xc3, 0x30,
-	0x14, 0x85, 0x73, 0x01, 0x45, 0xaa, 0xcb, 0x14, 0x31, 0x04, 0x24, 0x2e, 0x3f, 0x13, 0x4b, 0x93,
-	0xa1, 0x88, 0x07, 0x60, 0x63, 0x43, 0x88, 0x2e, 0x2c, 0x91, 0xeb, 0x5c, 0x82, 0xd5, 0x24, 0xae,
-	0xae, 0x0d, 0x82, 0x8d, 0x99, 0x89, 0xc7, 0xe0, 0x51, 0x18, 0x3b, 0x32, 0x12, 0xb3, 0x30, 0xf6,
-	0x11, 0x50, 0x9d, 0x30, 0xd9, 0xfa, 0xce, 0x77, 0xae, 0x74, 0xc4, 0x45, 0xa3, 0x9f, 0x89, 0x73,
-	0x59, 0xca, 0xa5, 0x23, 0xce, 0xa7, 0x56, 0xc9, 0x9a, 0x26, 0xda, 0x3a, 0x6d, 0x26, 0xff, 0x50,
-	0x99, 0xf6, 0x5e, 0x57, 0xc3, 0x93, 0x2d, 0xd9, 0x38, 0x93, 0xec, 0x0f, 0x61, 0xe6, 0x1e, 0x98,
-	0x28, 0xb4, 0xb2, 0x5e, 0x38, 0xd8, 0xab, 0x4c, 0x65, 0x82, 0x95, 0x6f, 0x7e, 0x7d, 0xe1, 0xf4,
-	0x0d, 0x44, 0x7c, 0x2d, 0x59, 0x36, 0x36, 0x39, 0x14, 0xc2, 0x12, 0x3f, 0x69, 0x45, 0x85, 0x2e,
-	0x53, 0x38, 0x86, 0xb3, 0xd1, 0xcd, 0x68, 0x20, 0x57, 0x65, 0x88, 0x5f, 0xac, 0xa3, 0xa6, 0x78,
-	0xe4, 0x3a, 0xdd, 0x1a, 0xe2, 0x40, 0x66, 0x5c, 0x27, 0x27, 0x62, 0x57, 0x2a, 0x45, 0xd6, 0x16,
-	0xce, 0x2c, 0xa8, 0x4d, 0xb7, 0x83, 0x30, 0xee, 0xd9, 0xed, 0x06, 0x25, 0x47, 0x62, 0x3c, 0x97,
-	0x6a, 0x41, 0x6d, 0x19, 0x4e, 0xec, 0x04, 0x43, 0x0c, 0x68, 0xc6, 0xf5, 0xe5, 0xf9, 0xaa, 0xc3,
-	0xe8, 0xab, 0xc3, 0x68, 0xdd, 0x21, 0xbc, 0x7a, 0x84, 0x0f, 0x8f, 0xf0, 0xe9, 0x11, 0x56, 0x1e,
-	0xe1, 0xdb, 0x23, 0xfc, 0x7a, 0x8c, 0xd6, 0x1e, 0xe1, 0xfd, 0x07, 0xa3, 0xbb, 0xb8, 0x1f, 0x36,
-	0x8f, 0xc3, 0x92, 0xe9, 0x5f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xd3, 0x6f, 0x2d, 0xbe, 0x34, 0x01,
-	0x00, 0x00,
+	// 627 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x94, 0xc1, 0x6e, 0xd3, 0x4a,
+	0x14, 0x86, 0xe3, 0xa6, 0xcd, 0x6d, 0x27, 0x49, 0xdb, 0x4c, 0x53, 0x69, 0x6e, 0xa5, 0xeb, 0x1b,
+	0x40, 0x48, 0x15, 0x52, 0x5d, 0xd4, 0x54, 0x15, 0x62, 0x03, 0x6a, 0x41, 0x82, 0x15, 0x51, 0x5a,
+	0x10, 0x82, 0x85, 0x35, 0x1d, 0x9f, 0x24, 0x56, 0x9d, 0x19, 0x6b, 0x66, 0x12, 0xd5, 0x3b, 0x1e,
+	0x01, 0x89, 0x97, 0xe0, 0x51, 0x58, 0x76, 0xc9, 0x92, 0x9a, 0x0d, 0xcb, 0x3e, 0x02, 0xf2, 0x19,
+	0xa7, 0x14, 0x15, 0x04, 0x5d, 0x65, 0xfc, 0x7f, 0xff, 0xff, 0xeb, 0x68, 0x8e, 0x1d, 0xb2, 0x37,
+	0x8e, 0x4f, 0x41, 0x6f, 0xf3, 0x88, 0xa7, 0x16, 0xf4, 0x76, 0xd7, 0x08, 0x9e, 0xc0, 0x56, 0x6c,
+	0x6c, 0xac, 0xb6, 0x66, 0xa2, 0x50, 0x72, 0x10, 0x0f, 0xcb, 0x9f, 0x20, 0xd5, 0xca, 0x2a, 0xfa,
+	0x6f, 0x09, 0x03, 0x3b, 0xd2, 0x00, 0x98, 0x0a, 0x9c, 0x61, 0xa3, 0x3d, 0x54, 0x43, 0x85, 0xae,
+	0xed, 0xe2, 0xe4, 0x02, 0xb7, 0x3f, 0x2c, 0x92, 0x5a, 0x8f, 0x6b, 0x3e, 0x36, 0xf4, 0x3f, 0x42,
+	0x0c, 0xe8, 0x69, 0x2c, 0x20, 0x8c, 0x23, 0xe6, 0x75, 0xbc, 0xcd, 0xa5, 0xfe, 0x52, 0xa9, 0x3c,
+	0x8f, 0x10, 0x67, 0xc6, 0xc2, 0x38, 0x9c, 0xe8, 0x84, 0xcd, 0x95, 0x18, 0x95, 0x97, 0x3a, 0xa1,
+	0xb7, 0x48, 0x83, 0x0b, 0x01, 0xc6, 0x84, 0x56, 0x9d, 0x80, 0x64, 0x55, 0x34, 0xd4, 0x9d, 0x76,
+	0x54, 0x48, 0xf4, 0x7f, 0x52, 0x3f, 0xe6, 0xe2, 0x04, 0x64, 0x84, 0x15, 0xf3, 0xe8, 0x20, 0xa5,
+	0x54, 0x74, 0xdc, 0x23, 0x2d, 0xc1, 0xc5, 0x08, 0x42, 0x6b, 0x93, 0xd0, 0x80, 0x50, 0x32, 0x32,
+	0x6c, 0xa1, 0xe3, 0x6d, 0x56, 0xfb, 0x2b, 0x08, 0x8e, 0x6c, 0x72, 0xe8, 0x64, 0xba, 0x43, 0xd6,
+	0x9d, 0x57, 0xc3, 0x40, 0x83, 0x19, 0x5d, 0xfa, 0x6b, 0xe8, 0x5f, 0x43, 0xd8, 0x77, 0x6c, 0x96,
+	0xb9, 0x43, 0x9a, 0xb3, 0x01, 0x10, 0xb3, 0x7f, 0x70, 0x84, 0x46, 0x29, 0x1e, 0x14, 0x1a, 0xbd,
+	0x4b, 0x96, 0x07, 0x3c, 0x4e, 0x26, 0x1a, 0xc2, 0x54, 0x25, 0xb1, 0xc8, 0xd8, 0x22, 0xba, 0x9a,
+	0xa5, 0xda, 0x43, 0x91, 0x76, 0xc9, 0xba, 0xd0, 0x10, 0x81, 0xb4, 0x31, 0x4f, 0xc2, 0x54, 0x83,
+	0x28, 0x1e, 0x04, 0xb0, 0x25, 0x74, 0xb7, 0x7f, 0xc0, 0xde, 0x25, 0xa3, 0x9c, 0xac, 0x1a, 0xcb,
+	0xed, 0xc4, 0x84, 0x6a, 0x0a, 0x5a, 0xc7, 0x11, 0x18, 0x46, 0x3a, 0xd5, 0xcd, 0xfa, 0xce, 0x5e,
+	0xf0, 0xdb, 0xcd, 0x05, 0x6e, 0x3f, 0xc1, 0x21, 0x26, 0x5f, 0xcc, 0x82, 0x4f, 0xa5, 0xd5, 0x59,
+	0x7f, 0xc5, 0xfc, 0xac, 0xd2, 0xd7, 0xa4, 0x19, 0x81, 0x2c, 0x66, 0x3a, 0x56, 0x51, 0x0c, 0x86,
+	0xd5, 0xb1, 0xbf, 0xfb, 0xe7, 0xfe, 0x27, 0x18, 0xdb, 0xc7, 0x94, 0x2b, 0x6f, 0x44, 0x57, 0x24,
+	0xfa, 0x96, 0x2c, 0x97, 0xcd, 0x23, 0xe0, 0x11, 0x68, 0xc3, 0x1a, 0x58, 0xbd, 0xfb, 0xb7, 0xd5,
+	0xcf, 0x5c, 0xcc, 0x75, 0x37, 0xa3, 0xab, 0x1a, 0x0d, 0xc8, 0x5a, 0x59, 0x2e, 0x94, 0xb4, 0x20,
+	0x6d, 0x68, 0xb3, 0x14, 0x58, 0x13, 0x2f, 0xb3, 0xe5, 0xd0, 0x81, 0x23, 0x47, 0x59, 0x0a, 0xb4,
+	0x43, 0xea, 0x20, 0xa7, 0xb1, 0x56, 0x72, 0x0c, 0xd2, 0xb2, 0x65, 0xf7, 0xb6, 0x5d, 0x91, 0xe8,
+	0x7d, 0xd2, 0x4e, 0xb5, 0x3a, 0xcd, 0x42, 0x37, 0x4a, 0x38, 0x05, 0x6d, 0x62, 0x25, 0xd9, 0x0a,
+	0xbe, 0x1f, 0x14, 0xd9, 0x01, 0xa2, 0x57, 0x8e, 0x50, 0x4a, 0xe6, 0xc7, 0x2a, 0x02, 0xb6, 0x8a,
+	0x65, 0x78, 0xa6, 0x5b, 0x84, 0x82, 0x1c, 0x28, 0x2d, 0x20, 0x4c, 0x41, 0x0b, 0x90, 0x96, 0x0f,
+	0x81, 0xb5, 0xb0, 0xa3, 0x55, 0x92, 0xde, 0x25, 0xd8, 0xd8, 0x27, 0xed, 0x5f, 0xad, 0x89, 0xae,
+	0x92, 0xea, 0x09, 0x64, 0xe5, 0x47, 0x55, 0x1c, 0x69, 0x9b, 0x2c, 0x4c, 0x79, 0x32, 0x81, 0xf2,
+	0x4b, 0x72, 0x0f, 0x0f, 0xe7, 0x1e, 0x78, 0x1b, 0x8f, 0x48, 0xeb, 0xda, 0x2a, 0x6e, 0x54, 0xf0,
+	0x98, 0xd0, 0xeb, 0x17, 0x7e, 0x93, 0x86, 0xfd, 0xdd, 0xb3, 0x73, 0xbf, 0xf2, 0xf9, 0xdc, 0xaf,
+	0x5c, 0x9c, 0xfb, 0xde, 0xbb, 0xdc, 0xf7, 0x3e, 0xe6, 0xbe, 0xf7, 0x29, 0xf7, 0xbd, 0xb3, 0xdc,
+	0xf7, 0xbe, 0xe4, 0xbe, 0xf7, 0x2d, 0xf7, 0x2b, 0x17, 0xb9, 0xef, 0xbd, 0xff, 0xea, 0x57, 0xde,
+	0xd4, 0xdc, 0x0d, 0x1f, 0xd7, 0xf0, 0x2f, 0xa5, 0xfb, 0x7d, 0x00, 0xb1, 0x39, 0xca, 0x76, 0xbd,
+	0x04, 0x00, 0x00,
 }
@@ -0,0 +1,168 @@
+package kubernetes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// configMapRewatchInterval is the delay before re-establishing a closed or failed watch
+var configMapRewatchInterval = 5 * time.Second
+
+// configMapLoader loads the data of a ConfigMap, with each data key treated as a file name
+type configMapLoader interface {
+	Load(files map[string][]byte) error
+}
+
+// WatchProxyConfigs loads the proxy configs held in the data of a ConfigMap into source and reloads them whenever the
+// ConfigMap is modified, until stop is closed. Each data key is treated as a file name, see threescale.StaticConfigSource.
+// An error is returned if the initial load fails. Errors while watching are passed to onError, if not nil, and the
+// previously loaded proxy configs continue to be served.
+func (c *K8sClient) WatchProxyConfigs(name, namespace string, source *threescale.StaticConfigSource, onError func(error), stop <-chan struct{}) error {
+	return c.watchConfigMapData(name, namespace, "proxy config", source, onError, stop)
+}
+
+// WatchAccessList loads the entries of an access list held in the data of a ConfigMap and reloads them whenever the
+// ConfigMap is modified, until stop is closed. Errors are handled as for WatchProxyConfigs.
+func (c *K8sClient) WatchAccessList(name, namespace string, list *threescale.AccessList, onError func(error), stop <-chan struct{}) error {
+	return c.watchConfigMapData(name, namespace, "access list", list, onError, stop)
+}
+
+func (c *K8sClient) watchConfigMapData(name, namespace, kind string, loader configMapLoader, onError func(error), stop <-chan struct{}) error {
+	cm, err := c.cs.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error fetching %s map %s/%s - %v", kind, namespace, name, err)
+	}
+
+	if err := loadConfigMap(cm, kind, loader); err != nil {
+		return err
+	}
+
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	go c.watchConfigMap(cm, kind, loader, onError, stop)
+	return nil
+}
+
+// watchConfigMap watches the ConfigMap from its resource version, re-establishing the watch when it closes.
+// When the watch fails, for example because the resource version has expired, the ConfigMap is fetched and loaded
+// again so that the watch resumes from its current resource version.
+func (c *K8sClient) watchConfigMap(cm *corev1.ConfigMap, kind string, loader configMapLoader, onError func(error), stop <-chan struct{}) {
+	name, namespace := cm.Name, cm.Namespace
+	version := cm.ResourceVersion
+	relist := false
+
+	for {
+		if relist {
+			version, relist = c.reloadConfigMap(name, namespace, kind, loader, onError)
+		}
+
+		if !relist {
+			opts := metav1.ListOptions{
+				FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+				ResourceVersion: version,
+			}
+
+			w, err := c.cs.CoreV1().ConfigMaps(namespace).Watch(opts)
+			if err != nil {
+				onError(fmt.Errorf("error watching %s map %s/%s - %v", kind, namespace, name, err))
+				relist = true
+			} else {
+				last, stopped, err := handleConfigMapEvents(w, name, kind, loader, onError, stop)
+				if stopped {
+					return
+				}
+
+				if err != nil {
+					onError(fmt.Errorf("error watching %s map %s/%s - %v", kind, namespace, name, err))
+					relist = true
+				} else if last != "" {
+					version = last
+				}
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(configMapRewatchInterval):
+		}
+	}
+}
+
+// reloadConfigMap fetches and loads the ConfigMap, returning its resource version and whether it must be fetched again
+func (c *K8sClient) reloadConfigMap(name, namespace, kind string, loader configMapLoader, onError func(error)) (string, bool) {
+	cm, err := c.cs.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		onError(fmt.Errorf("error fetching %s map %s/%s - %v", kind, namespace, name, err))
+		return "", true
+	}
+
+	if err := loadConfigMap(cm, kind, loader); err != nil {
+		onError(err)
+	}
+	return cm.ResourceVersion, false
+}
+
+// handleConfigMapEvents reloads the loader on every change to the ConfigMap until the watch is closed or stop is closed.
+// It returns the last seen resource version, whether stop was closed and the error reported by the watch, if any.
+func handleConfigMapEvents(w watch.Interface, name, kind string, loader configMapLoader, onError func(error), stop <-chan struct{}) (string, bool, error) {
+	defer w.Stop()
+
+	var version string
+	for {
+		select {
+		case <-stop:
+			return version, true, nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return version, false, nil
+			}
+
+			if event.Type == watch.Error {
+				if status, isStatus := event.Object.(*metav1.Status); isStatus {
+					return version, false, fmt.Errorf("%s (%d)", status.Message, status.Code)
+				}
+				return version, false, fmt.Errorf("unexpected watch error %v", event.Object)
+			}
+
+			cm, isConfigMap := event.Object.(*corev1.ConfigMap)
+			if !isConfigMap || cm.Name != name {
+				continue
+			}
+			version = cm.ResourceVersion
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				if err := loadConfigMap(cm, kind, loader); err != nil {
+					onError(err)
+				}
+			case watch.Deleted:
+				onError(fmt.Errorf("%s map %s/%s deleted - serving previously loaded %ss", kind, cm.Namespace, cm.Name, kind))
+			}
+		}
+	}
+}
+
+func loadConfigMap(cm *corev1.ConfigMap, kind string, loader configMapLoader) error {
+	files := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for key, value := range cm.Data {
+		files[key] = []byte(value)
+	}
+	for key, value := range cm.BinaryData {
+		files[key] = value
+	}
+
+	if err := loader.Load(files); err != nil {
+		return fmt.Errorf("error loading %s map %s/%s - %v", kind, cm.Namespace, cm.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+const testProxyConfig = `{"proxy_config": {"environment": "production", "content": {"backend_version": "1"}}}`
+
+func TestWatchProxyConfigs(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "proxy-configs",
+			Namespace: "istio-system",
+		},
+		Data: map[string]string{"123.json": testProxyConfig},
+	}
+
+	client := fake.NewSimpleClientset()
+	client.CoreV1().ConfigMaps(cm.Namespace).Create(cm)
+
+	fakeWatch := watch.NewFake()
+	client.PrependWatchReactor("configmaps", ktesting.DefaultWatchReactor(fakeWatch, nil))
+
+	k8 := K8sClient{cs: client}
+	source := threescale.NewStaticConfigSource()
+	errs := make(chan error)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	if err := k8.WatchProxyConfigs("missing", cm.Namespace, source, nil, stop); err == nil {
+		t.Errorf("expected error watching missing config map")
+	}
+
+	err := k8.WatchProxyConfigs(cm.Name, cm.Namespace, source, func(err error) { errs <- err }, stop)
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	assertLoaded := func(serviceID string, expect bool) {
+		t.Helper()
+		_, err := source.GetSystemConfiguration("", authorizer.SystemRequest{ServiceID: serviceID})
+		if (err == nil) != expect {
+			t.Errorf("unexpected loaded state for service %s - %v", serviceID, err)
+		}
+	}
+
+	assertLoaded("123", true)
+
+	updated := cm.DeepCopy()
+	updated.Data = map[string]string{"456.json": testProxyConfig}
+	fakeWatch.Modify(updated)
+
+	invalid := cm.DeepCopy()
+	invalid.Data = map[string]string{"789.json": "not json"}
+	fakeWatch.Modify(invalid)
+
+	// events are handled in order so the update has been loaded once the invalid config is reported
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "789.json") {
+			t.Errorf("unexpected error - %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected invalid proxy config to be reported")
+	}
+
+	assertLoaded("123", false)
+	assertLoaded("456", true)
+	assertLoaded("789", false)
+}
+
+func TestWatchProxyConfigsRelist(t *testing.T) {
+	interval := configMapRewatchInterval
+	configMapRewatchInterval = time.Millisecond
+	defer func() { configMapRewatchInterval = interval }()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "proxy-configs",
+			Namespace:       "istio-system",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{"123.json": testProxyConfig},
+	}
+
+	client := fake.NewSimpleClientset()
+	client.CoreV1().ConfigMaps(cm.Namespace).Create(cm)
+
+	expired := watch.NewFake()
+	resumed := watch.NewFake()
+	versions := make(chan string, 2)
+	client.PrependWatchReactor("configmaps", func(action ktesting.Action) (bool, watch.Interface, error) {
+		version := action.(ktesting.WatchAction).GetWatchRestrictions().ResourceVersion
+		versions <- version
+		if version == "1" {
+			return true, expired, nil
+		}
+		return true, resumed, nil
+	})
+
+	k8 := K8sClient{cs: client}
+	source := threescale.NewStaticConfigSource()
+	errs := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	if err := k8.WatchProxyConfigs(cm.Name, cm.Namespace, source, func(err error) { errs <- err }, stop); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if version := <-versions; version != "1" {
+		t.Fatalf("expected watch from resource version 1 but got %q", version)
+	}
+
+	// the config map changes while the watch is failing, so the change is only seen by fetching it again
+	updated := cm.DeepCopy()
+	updated.ResourceVersion = "2"
+	updated.Data = map[string]string{"456.json": testProxyConfig}
+	client.CoreV1().ConfigMaps(cm.Namespace).Update(updated)
+	expired.Error(&metav1.Status{Code: 410, Reason: metav1.StatusReasonGone, Message: "too old resource version"})
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "too old resource version") {
+			t.Errorf("unexpected error - %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected watch error to be reported")
+	}
+
+	select {
+	case version := <-versions:
+		if version != "2" {
+			t.Errorf("expected watch to resume from the fetched resource version but got %q", version)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected watch to be re-established")
+	}
+
+	if _, err := source.GetSystemConfiguration("", authorizer.SystemRequest{ServiceID: "456"}); err != nil {
+		t.Errorf("expected config map to be loaded again after watch error - %v", err)
+	}
+}
+
+func TestWatchAccessList(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "access-list",
+			Namespace: "istio-system",
+		},
+		Data: map[string]string{"access-list.yaml": "- {type: user_key, credential: leaked, action: deny}"},
+	}
+
+	client := fake.NewSimpleClientset()
+	client.CoreV1().ConfigMaps(cm.Namespace).Create(cm)
+
+	k8 := K8sClient{cs: client}
+	list := threescale.NewAccessList()
+	stop := make(chan struct{})
+	defer close(stop)
+
+	if err := k8.WatchAccessList(cm.Name, cm.Namespace, list, nil, stop); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if entries := list.Entries(); len(entries) != 1 || entries[0].Credential != "leaked" {
+		t.Errorf("expected access list to be loaded from config map but got %+v", entries)
+	}
+}
@@ -0,0 +1,129 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	"istio.io/istio/mixer/pkg/lang/ast"
+)
+
+// CredentialSourceType is a location on a request which a credential can be read from
+type CredentialSourceType string
+
+const (
+	// QueryParamSource reads a credential from a query parameter
+	QueryParamSource CredentialSourceType = "query"
+	// HeaderSource reads a credential from a request header
+	HeaderSource CredentialSourceType = "header"
+	// ClaimSource reads a credential from a claim of a JWT validated by Istio
+	ClaimSource CredentialSourceType = "claim"
+	// CookieSource reads a credential from a cookie. Since cookies are not part of the Mixer attribute vocabulary,
+	// the adapter extracts the value from the cookie header and this source is evaluated after all other sources
+	CookieSource CredentialSourceType = "cookie"
+
+	cookieHeaderAttribute = `request.headers["cookie"] | ""`
+)
+
+// CredentialSource is a single named location on a request which a credential can be read from
+type CredentialSource struct {
+	Type CredentialSourceType
+	Name string
+}
+
+// CredentialSources is a list of locations to read a credential from, in order of precedence
+type CredentialSources []CredentialSource
+
+// ParseCredentialSources parses a comma separated list of sources in the form type:name,
+// for example "header:x-api-key,query:api_key,cookie:api_key"
+func ParseCredentialSources(sources string) (CredentialSources, error) {
+	var parsed CredentialSources
+	if strings.TrimSpace(sources) == "" {
+		return parsed, nil
+	}
+
+	for _, source := range strings.Split(sources, ",") {
+		parts := strings.SplitN(strings.TrimSpace(source), ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid credential source %q - expected format is type:name", source)
+		}
+
+		sourceType := CredentialSourceType(strings.ToLower(parts[0]))
+		switch sourceType {
+		case QueryParamSource, HeaderSource, ClaimSource, CookieSource:
+		default:
+			return nil, fmt.Errorf("unsupported credential source type %q - must be one of query, header, claim or cookie", parts[0])
+		}
+
+		if strings.ContainsAny(parts[1], `"\`) {
+			return nil, fmt.Errorf("credential source name %q contains invalid characters", parts[1])
+		}
+
+		parsed = append(parsed, CredentialSource{Type: sourceType, Name: parts[1]})
+	}
+
+	if len(parsed.cookies()) > 1 {
+		return nil, fmt.Errorf("only a single cookie source is supported per credential")
+	}
+
+	return parsed, nil
+}
+
+// AttributeExpression returns a Mixer attribute expression reading the credential from the query parameter,
+// header and claim sources in order of precedence. The expression defaults to an empty string when no source is set.
+func (cs CredentialSources) AttributeExpression() (string, error) {
+	var attributes []string
+	for _, source := range cs {
+		switch source.Type {
+		case QueryParamSource:
+			attributes = append(attributes, fmt.Sprintf(`request.query_params["%s"]`, source.Name))
+		case HeaderSource:
+			// Istio expects header keys to be lower case
+			attributes = append(attributes, fmt.Sprintf(`request.headers["%s"]`, strings.ToLower(source.Name)))
+		case ClaimSource:
+			attributes = append(attributes, fmt.Sprintf(`request.auth.claims["%s"]`, source.Name))
+		}
+	}
+
+	expression := strings.Join(append(attributes, `""`), " | ")
+	if err := ValidateAttributeExpression(expression); err != nil {
+		return "", err
+	}
+	return expression, nil
+}
+
+// Cookie returns the name of the cookie the credential should be read from, or an empty string if not set
+func (cs CredentialSources) Cookie() string {
+	if cookies := cs.cookies(); len(cookies) > 0 {
+		return cookies[0].Name
+	}
+	return ""
+}
+
+func (cs CredentialSources) cookies() CredentialSources {
+	var cookies CredentialSources
+	for _, source := range cs {
+		if source.Type == CookieSource {
+			cookies = append(cookies, source)
+		}
+	}
+	return cookies
+}
+
+// SetCookieSource configures the instance to read the credential identified by credentialKey
+// (one of user_key, app_id, app_key or client_id) from the provided cookie
+func (bi *BaseInstance) SetCookieSource(credentialKey string, cookieName string) {
+	if bi.Params.Subject.Properties == nil {
+		bi.Params.Subject.Properties = make(map[string]interface{})
+	}
+	bi.Params.Subject.Properties[threescale.CookieAttributeKey] = cookieHeaderAttribute
+	bi.Params.Subject.Properties[credentialKey+threescale.CookieSourceSuffix] = fmt.Sprintf(`"%s"`, cookieName)
+}
+
+// ValidateAttributeExpression verifies that the provided string is a syntactically valid Mixer expression
+func ValidateAttributeExpression(expression string) error {
+	if _, err := ast.Parse(expression); err != nil {
+		return fmt.Errorf("invalid attribute expression %q - %v", expression, err)
+	}
+	return nil
+}
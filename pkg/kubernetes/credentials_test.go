@@ -0,0 +1,159 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCredentialSources(t *testing.T) {
+	inputs := []struct {
+		name      string
+		sources   string
+		expectErr bool
+		expect    CredentialSources
+	}{
+		{
+			name:    "Test empty sources",
+			sources: "",
+		},
+		{
+			name:    "Test sources are parsed in order",
+			sources: "header:X-Api-Key, query:api_key,claim:azp,cookie:session",
+			expect: CredentialSources{
+				{Type: HeaderSource, Name: "X-Api-Key"},
+				{Type: QueryParamSource, Name: "api_key"},
+				{Type: ClaimSource, Name: "azp"},
+				{Type: CookieSource, Name: "session"},
+			},
+		},
+		{
+			name:      "Test fail with missing name",
+			sources:   "header:",
+			expectErr: true,
+		},
+		{
+			name:      "Test fail with missing type",
+			sources:   "api_key",
+			expectErr: true,
+		},
+		{
+			name:      "Test fail with unsupported type",
+			sources:   "body:api_key",
+			expectErr: true,
+		},
+		{
+			name:      "Test fail with name which would break expression",
+			sources:   `header:api"key`,
+			expectErr: true,
+		},
+		{
+			name:      "Test fail with multiple cookies",
+			sources:   "cookie:one,cookie:two",
+			expectErr: true,
+		},
+	}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			sources, err := ParseCredentialSources(input.sources)
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+			if !reflect.DeepEqual(sources, input.expect) {
+				t.Errorf("unexpected sources.\nWanted:\n%v\nGot:\n%v", input.expect, sources)
+			}
+		})
+	}
+}
+
+func TestCredentialSourcesAttributeExpression(t *testing.T) {
+	inputs := []struct {
+		name         string
+		sources      string
+		expect       string
+		expectCookie string
+	}{
+		{
+			name:    "Test empty sources default to empty string",
+			sources: "",
+			expect:  `""`,
+		},
+		{
+			name:    "Test default api key expression is reproduced",
+			sources: "query:user_key,header:user_key",
+			expect:  DefaultApiKeyAttribute,
+		},
+		{
+			name:    "Test header keys are lower cased",
+			sources: "header:X-Api-Key",
+			expect:  `request.headers["x-api-key"] | ""`,
+		},
+		{
+			name:    "Test claims",
+			sources: "claim:azp",
+			expect:  DefaultOIDCAttribute,
+		},
+		{
+			name:         "Test cookie is excluded from expression",
+			sources:      "cookie:session,header:app_id",
+			expect:       `request.headers["app_id"] | ""`,
+			expectCookie: "session",
+		},
+	}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			sources, err := ParseCredentialSources(input.sources)
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			expression, err := sources.AttributeExpression()
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if expression != input.expect {
+				t.Errorf("unexpected expression.\nWanted:\n%s\nGot:\n%s", input.expect, expression)
+			}
+
+			if sources.Cookie() != input.expectCookie {
+				t.Errorf("unexpected cookie, wanted %s but got %s", input.expectCookie, sources.Cookie())
+			}
+		})
+	}
+}
+
+func TestSetCookieSource(t *testing.T) {
+	instance := NewApiKeyInstance(`""`)
+	instance.SetCookieSource("user_key", "session")
+
+	expect := map[string]interface{}{
+		"cookie":          `request.headers["cookie"] | ""`,
+		"user_key_cookie": `"session"`,
+	}
+	if !reflect.DeepEqual(instance.Params.Subject.Properties, expect) {
+		t.Errorf("unexpected properties.\nWanted:\n%v\nGot:\n%v", expect, instance.Params.Subject.Properties)
+	}
+}
+
+func TestValidateAttributeExpression(t *testing.T) {
+	for _, valid := range []string{
+		DefaultApiKeyAttribute,
+		DefaultAppIDAttribute,
+		DefaultAppKeyAttribute,
+		DefaultOIDCAttribute,
+	} {
+		if err := ValidateAttributeExpression(valid); err != nil {
+			t.Errorf("unexpected error for valid expression %s - %v", valid, err)
+		}
+	}
+
+	if err := ValidateAttributeExpression(`request.headers["user_key" |`); err == nil {
+		t.Errorf("expected error for invalid expression")
+	}
+}
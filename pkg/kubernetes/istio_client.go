@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"k8s.io/client-go/rest"
@@ -38,6 +39,32 @@ func (c *IstioClientImpl) CreateHandler(name string, inNamespace string, spec Ha
 	return &result, err
 }
 
+// ListHandlers for the provided adapter from the provided namespace
+// If provided namespace is empty string, all readable namespaces as authorised by the receivers config will be read
+func (c *IstioClientImpl) ListHandlers(adapter string, inNamespace string) ([]HandlerSpec, error) {
+	b, err := c.rc.Get().Namespace(inNamespace).Resource(handlerPlural).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Items []struct {
+			Spec HandlerSpec `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("error decoding handlers - %v", err)
+	}
+
+	var handlers []HandlerSpec
+	for _, item := range list.Items {
+		if item.Spec.Adapter == adapter {
+			handlers = append(handlers, item.Spec)
+		}
+	}
+	return handlers, nil
+}
+
 func getBaseResource(name, namespace, kind string) *IstioResource {
 	return &IstioResource{
 		TypeMeta: getTypeMeta(kind),
@@ -92,6 +92,54 @@ func TestCreateHandler(t *testing.T) {
 	}
 }
 
+func TestListHandlers(t *testing.T) {
+	const list = `{
+  "apiVersion": "config.istio.io/v1alpha2",
+  "kind": "handlerList",
+  "items": [
+    {
+      "metadata": {"name": "threescale", "namespace": "istio-system"},
+      "spec": {
+        "adapter": "threescale",
+        "params": {"service_id": "123", "system_url": "https://tenant-admin.3scale.net", "access_token": "secret", "cache_ttl_seconds": 60},
+        "connection": {"address": "threescale-istio-adapter:3333"}
+      }
+    },
+    {
+      "metadata": {"name": "denier", "namespace": "istio-system"},
+      "spec": {"adapter": "denier"}
+    }
+  ]
+}`
+
+	client := IstioClientImpl{
+		rc: &fake.RESTClient{
+			GroupVersion:         schema.GroupVersion{Group: istioObjGroupName, Version: istioObjGroupVersion},
+			NegotiatedSerializer: serializer.DirectCodecFactory{CodecFactory: scheme.Codecs},
+			Client: fake.CreateHTTPClient(func(request *http.Request) (response *http.Response, e error) {
+				if request.Method != http.MethodGet || !strings.HasSuffix(request.URL.Path, "/"+handlerPlural) {
+					t.Errorf("unexpected request %s %s", request.Method, request.URL.Path)
+				}
+				return &http.Response{StatusCode: http.StatusOK, Header: defaultHeader(t), Body: ioutil.NopCloser(strings.NewReader(list))}, nil
+			}),
+		},
+	}
+
+	handlers, err := client.ListHandlers("threescale", "")
+	if err != nil {
+		t.Fatalf("unexpected error listing handlers - %v", err)
+	}
+
+	if len(handlers) != 1 {
+		t.Fatalf("expected only handlers for the adapter to be listed but got %v", handlers)
+	}
+
+	params := handlers[0].Params
+	if params.ServiceId != "123" || params.SystemUrl != "https://tenant-admin.3scale.net" || params.AccessToken != "secret" || params.CacheTtlSeconds != 60 {
+		t.Errorf("unexpected handler params %v", params)
+	}
+}
+
 func defaultHeader(t *testing.T) http.Header {
 	t.Helper()
 	header := http.Header{}
@@ -2,11 +2,14 @@ package kubernetes
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/3scale/3scale-istio-adapter/config"
 	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	system "github.com/3scale/3scale-porta-go-client/client"
 	"istio.io/api/policy/v1beta1"
 	v1 "k8s.io/api/core/v1"
 )
@@ -26,6 +29,7 @@ const (
 	defaultThreescaleAppIdLabel  = threescale.AppIDAttributeKey
 	defaultThreescaleAppKeyLabel = threescale.AppKeyAttributeKey
 	defaultThreescaleOIDCLabel   = threescale.OIDCAttributeKey
+	defaultThreescaleHostLabel   = threescale.HostAttributeKey
 
 	//DefaultApiKeyAttribute string for a 3scale adapter instance - Api Key pattern
 	DefaultApiKeyAttribute = `request.query_params["user_key"] | request.headers["user_key"] | ""`
@@ -35,6 +39,13 @@ const (
 	DefaultAppKeyAttribute = `request.query_params["app_key"] | request.headers["app_key"] | ""`
 	//DefaultOIDCAttribute string for a 3scale adapter instance - OIDC pattern
 	DefaultOIDCAttribute = `request.auth.claims["azp"] | ""`
+	//DefaultHostAttribute string for a 3scale adapter instance - resolves the service when no service ID is provided
+	DefaultHostAttribute = `request.host | ""`
+
+	// backend versions as reported by 3scale for each supported authentication pattern
+	backendVersionApiKey = "1"
+	backendVersionAppID  = "2"
+	backendVersionOIDC   = "oauth"
 )
 
 // NewThreescaleHandlerSpec returns a handler spec as per 3scale config
@@ -62,15 +73,20 @@ func NewThreescaleHandlerSpec(accessToken, systemURL, svcID string) (*HandlerSpe
 
 // NewDefaultHybridInstance - new base instance supporting all authentication methods with default values
 func NewDefaultHybridInstance() *BaseInstance {
+	return NewHybridInstance(DefaultCredentialAttributes())
+}
+
+// NewHybridInstance - new base instance supporting all authentication methods
+func NewHybridInstance(attrs CredentialAttributes) *BaseInstance {
 	return &BaseInstance{
 		Template: defaultThreescaleAuthorizationTemplateName,
 		Params: InstanceParams{
 			Subject: InstanceSubject{
-				User: DefaultApiKeyAttribute,
+				User: attrs.UserKey,
 				Properties: map[string]interface{}{
-					defaultThreescaleAppIdLabel:  DefaultAppIDAttribute,
-					defaultThreescaleAppKeyLabel: DefaultAppKeyAttribute,
-					defaultThreescaleOIDCLabel:   DefaultOIDCAttribute,
+					defaultThreescaleAppIdLabel:  attrs.AppID,
+					defaultThreescaleAppKeyLabel: attrs.AppKey,
+					defaultThreescaleOIDCLabel:   attrs.ClientID,
 				},
 			},
 			Action: getDefaultThreescaleInstanceAction(),
@@ -123,19 +139,111 @@ func NewOIDCInstance(appIdentifier, appKeyIdentifier string) *BaseInstance {
 	}
 }
 
+// NewInstanceForBackendVersion returns a base instance supporting the authentication pattern
+// which 3scale reports for a service via its backend version
+func NewInstanceForBackendVersion(backendVersion string, attrs CredentialAttributes) (*BaseInstance, error) {
+	switch backendVersion {
+	case backendVersionApiKey:
+		return NewApiKeyInstance(attrs.UserKey), nil
+	case backendVersionAppID:
+		return NewAppIDAppKeyInstance(attrs.AppID, attrs.AppKey), nil
+	case backendVersionOIDC:
+		return NewOIDCInstance(attrs.ClientID, attrs.AppKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported backend version %q", backendVersion)
+	}
+}
+
+// GetThreescaleServices lists the services which belong to the account owning the provided access token.
+// If filter is non-nil, only services whose name or system name matches the filter are returned.
+func GetThreescaleServices(accessToken, systemURL string, filter *regexp.Regexp, httpClient *http.Client) ([]ThreescaleService, error) {
+	c, err := threescale.NewSystemClient(systemURL, accessToken, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceList, err := c.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("error listing services from 3scale - %v", err)
+	}
+
+	var services []ThreescaleService
+	for _, svc := range serviceList.Services {
+		if filter != nil && !filter.MatchString(svc.Name) && !filter.MatchString(svc.SystemName) {
+			continue
+		}
+
+		services = append(services, ThreescaleService{
+			ID:             svc.ID,
+			Name:           svc.Name,
+			SystemName:     svc.SystemName,
+			BackendVersion: svc.BackendVersion,
+		})
+	}
+	return services, nil
+}
+
+// GetProxyConfig fetches the latest proxy config for the service in the provided environment
+func GetProxyConfig(accessToken, systemURL, serviceID, environment string, httpClient *http.Client) (system.ProxyConfig, error) {
+	c, err := threescale.NewSystemClient(systemURL, accessToken, httpClient)
+	if err != nil {
+		return system.ProxyConfig{}, err
+	}
+
+	element, err := c.GetLatestProxyConfig(serviceID, environment)
+	if err != nil {
+		return system.ProxyConfig{}, fmt.Errorf("error fetching proxy config from 3scale - %v", err)
+	}
+	return element.ProxyConfig, nil
+}
+
 // NewRule constructor for Istio Rule specific to 3scale requirements
-// This rule will 'AND' the provided match conditions and does not accept multiple handlers,instances
+// This rule will 'AND' the provided match conditions and dispatch the instance to the handler
 func NewRule(matchConditions MatchConditions, handler string, instance string) Rule {
-	matchOn := matchConditions.conditionsToMatchString()
-	r := Rule{
-		Match: matchOn,
-		Actions: []*v1beta1.Action{
-			{
-				Handler:   handler,
-				Instances: []string{instance}},
-		},
+	return NewMultiActionRule(matchConditions, NewAction(handler, instance))
+}
+
+// NewMultiActionRule constructor for Istio Rule which will 'AND' the provided match conditions
+// and perform each of the provided actions
+func NewMultiActionRule(matchConditions MatchConditions, actions ...*v1beta1.Action) Rule {
+	return Rule{
+		Match:   matchConditions.conditionsToMatchString(),
+		Actions: actions,
+	}
+}
+
+// NewAction which dispatches the provided instances to the handler
+func NewAction(handler string, instances ...string) *v1beta1.Action {
+	return &v1beta1.Action{
+		Handler:   handler,
+		Instances: instances,
+	}
+}
+
+// ParseAction parses an action from a string in the form handler=instance[,instance]
+func ParseAction(action string) (*v1beta1.Action, error) {
+	parts := strings.SplitN(action, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+		return nil, fmt.Errorf("invalid action %q - expected format is handler=instance[,instance]", action)
+	}
+
+	var instances []string
+	for _, instance := range strings.Split(parts[1], ",") {
+		if instance = strings.TrimSpace(instance); instance != "" {
+			instances = append(instances, instance)
+		}
+	}
+	return NewAction(strings.TrimSpace(parts[0]), instances...), nil
+}
+
+// DefaultCredentialAttributes returns the attribute expressions used to read credentials when none are provided
+func DefaultCredentialAttributes() CredentialAttributes {
+	return CredentialAttributes{
+		UserKey:  DefaultApiKeyAttribute,
+		AppID:    DefaultAppIDAttribute,
+		AppKey:   DefaultAppKeyAttribute,
+		ClientID: DefaultOIDCAttribute,
 	}
-	return r
 }
 
 func getDefaultThreescaleInstanceAction() InstanceAction {
@@ -143,6 +251,9 @@ func getDefaultThreescaleInstanceAction() InstanceAction {
 		Path:    "request.url_path",
 		Method:  `request.method | "get"`,
 		Service: `destination.labels["service-mesh.3scale.net/service-id"] | ""`,
+		Properties: map[string]interface{}{
+			defaultThreescaleHostLabel: DefaultHostAttribute,
+		},
 	}
 }
 
@@ -155,11 +266,85 @@ func GetDefaultMatchConditions(credentialsName string) MatchConditions {
 	}
 }
 
+// GetServiceMatchConditions for a 3scale adapter rule which should only apply to workloads labelled with the provided service ID
+func GetServiceMatchConditions(credentialsName string, serviceID string) MatchConditions {
+	return append(GetDefaultMatchConditions(credentialsName),
+		fmt.Sprintf(`destination.labels["service-mesh.3scale.net/service-id"] == "%s"`, serviceID),
+	)
+}
+
+// ExcludePathPrefixes returns a copy of the match conditions which will not match requests whose path begins with any of the prefixes
+func (mc MatchConditions) ExcludePathPrefixes(prefixes ...string) MatchConditions {
+	conditions := mc.copy()
+	for _, prefix := range prefixes {
+		conditions = append(conditions, fmt.Sprintf(`!request.url_path.startsWith("%s")`, prefix))
+	}
+	return conditions
+}
+
+// ForDestinationServices returns a copy of the match conditions which will only match requests to any of the named services
+func (mc MatchConditions) ForDestinationServices(services ...string) MatchConditions {
+	return mc.withAnyOf("destination.service.name", services)
+}
+
+// ForDestinationNamespaces returns a copy of the match conditions which will only match requests to any of the namespaces
+func (mc MatchConditions) ForDestinationNamespaces(namespaces ...string) MatchConditions {
+	return mc.withAnyOf("destination.namespace", namespaces)
+}
+
+// ForRequestMethods returns a copy of the match conditions which will only match requests using any of the HTTP methods
+func (mc MatchConditions) ForRequestMethods(methods ...string) MatchConditions {
+	upper := make([]string, len(methods))
+	for i, method := range methods {
+		upper[i] = strings.ToUpper(method)
+	}
+	return mc.withAnyOf("request.method", upper)
+}
+
+// WithExpressions returns a copy of the match conditions including the provided expressions
+func (mc MatchConditions) WithExpressions(expressions ...string) MatchConditions {
+	return append(mc.copy(), expressions...)
+}
+
+// Validate that the match conditions produce a syntactically valid expression
+func (mc MatchConditions) Validate() error {
+	for _, condition := range mc {
+		if strings.TrimSpace(condition) == "" {
+			return fmt.Errorf("match conditions cannot be empty")
+		}
+	}
+	return ValidateAttributeExpression(mc.conditionsToMatchString())
+}
+
 // conditionsToMatchString returns a valid expression for Istio match condition
 func (mc MatchConditions) conditionsToMatchString() string {
 	return strings.Join(mc, " &&\n")
 }
 
+// withAnyOf adds a condition which matches when the attribute is equal to any of the values
+func (mc MatchConditions) withAnyOf(attribute string, values []string) MatchConditions {
+	conditions := mc.copy()
+	if len(values) == 0 {
+		return conditions
+	}
+
+	var anyOf []string
+	for _, value := range values {
+		anyOf = append(anyOf, fmt.Sprintf(`%s == "%s"`, attribute, value))
+	}
+
+	if len(anyOf) == 1 {
+		return append(conditions, anyOf[0])
+	}
+	return append(conditions, fmt.Sprintf("(%s)", strings.Join(anyOf, " || ")))
+}
+
+func (mc MatchConditions) copy() MatchConditions {
+	conditions := make(MatchConditions, len(mc))
+	copy(conditions, mc)
+	return conditions
+}
+
 // convertSecret contents to 3scale credentials
 // Returns credentials if successfully validated and boolean to verify the validation
 func convertSecret(secret *v1.Secret) (*ThreescaleCredentials, bool) {
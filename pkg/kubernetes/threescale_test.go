@@ -3,7 +3,10 @@ package kubernetes
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -99,6 +102,8 @@ func TestNewApiKeyInstance(t *testing.T) {
   action:
     method: request.method | "get"
     path: request.url_path
+    properties:
+      host: request.host | ""
     service: destination.labels["service-mesh.3scale.net/service-id"] | ""
   subject:
     user: request.query_params["user_key"] | request.headers["user_key"] | ""
@@ -124,6 +129,8 @@ func TestNewAppIDAppKeyInstance(t *testing.T) {
   action:
     method: request.method | "get"
     path: request.url_path
+    properties:
+      host: request.host | ""
     service: destination.labels["service-mesh.3scale.net/service-id"] | ""
   subject:
     properties:
@@ -154,6 +161,8 @@ func TestNewOIDCInstance(t *testing.T) {
   action:
     method: request.method | "get"
     path: request.url_path
+    properties:
+      host: request.host | ""
     service: destination.labels["service-mesh.3scale.net/service-id"] | ""
   subject:
     properties:
@@ -201,3 +210,316 @@ match: |-
 		t.Errorf("unexpected YAML returned.\nWanted:\n%s\nGot:\n%s", expect, string(b))
 	}
 }
+
+func TestNewInstanceForBackendVersion(t *testing.T) {
+	inputs := []struct {
+		name           string
+		backendVersion string
+		expectErr      bool
+		expect         *BaseInstance
+	}{
+		{
+			name:           "Test api key instance for backend version 1",
+			backendVersion: "1",
+			expect:         NewApiKeyInstance(DefaultApiKeyAttribute),
+		},
+		{
+			name:           "Test app id instance for backend version 2",
+			backendVersion: "2",
+			expect:         NewAppIDAppKeyInstance(DefaultAppIDAttribute, DefaultAppKeyAttribute),
+		},
+		{
+			name:           "Test oidc instance for backend version oauth",
+			backendVersion: "oauth",
+			expect:         NewOIDCInstance(DefaultOIDCAttribute, DefaultAppKeyAttribute),
+		},
+		{
+			name:           "Test fail with unknown backend version",
+			backendVersion: "unknown",
+			expectErr:      true,
+		},
+	}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			instance, err := NewInstanceForBackendVersion(input.backendVersion, DefaultCredentialAttributes())
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if !reflect.DeepEqual(instance, input.expect) {
+				t.Errorf("unexpected instance returned for backend version %s", input.backendVersion)
+			}
+		})
+	}
+}
+
+func TestGetThreescaleServices(t *testing.T) {
+	const token = "secret-token"
+	const servicesXML = `<?xml version="1.0" encoding="UTF-8"?>
+<services>
+  <service>
+    <id>1</id>
+    <account_id>2</account_id>
+    <name>Echo API</name>
+    <state>incomplete</state>
+    <system_name>echo_api</system_name>
+    <backend_version>1</backend_version>
+  </service>
+  <service>
+    <id>3</id>
+    <account_id>2</account_id>
+    <name>Payments</name>
+    <state>incomplete</state>
+    <system_name>payments</system_name>
+    <backend_version>oauth</backend_version>
+  </service>
+</services>`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/admin/api/services.xml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(servicesXML))
+	}))
+	defer ts.Close()
+
+	inputs := []struct {
+		name      string
+		token     string
+		url       string
+		filter    *regexp.Regexp
+		expectErr bool
+		expect    []ThreescaleService
+	}{
+		{
+			name:      "Test fail with invalid url",
+			token:     token,
+			url:       "https://<t est.com",
+			expectErr: true,
+		},
+		{
+			name:      "Test fail with invalid token",
+			token:     "invalid",
+			url:       ts.URL,
+			expectErr: true,
+		},
+		{
+			name:  "Test all services returned without filter",
+			token: token,
+			url:   ts.URL,
+			expect: []ThreescaleService{
+				{ID: "1", Name: "Echo API", SystemName: "echo_api", BackendVersion: "1"},
+				{ID: "3", Name: "Payments", SystemName: "payments", BackendVersion: "oauth"},
+			},
+		},
+		{
+			name:   "Test filter matches name",
+			token:  token,
+			url:    ts.URL,
+			filter: regexp.MustCompile("^Echo"),
+			expect: []ThreescaleService{
+				{ID: "1", Name: "Echo API", SystemName: "echo_api", BackendVersion: "1"},
+			},
+		},
+		{
+			name:   "Test filter matches system name",
+			token:  token,
+			url:    ts.URL,
+			filter: regexp.MustCompile("^payments$"),
+			expect: []ThreescaleService{
+				{ID: "3", Name: "Payments", SystemName: "payments", BackendVersion: "oauth"},
+			},
+		},
+		{
+			name:   "Test no services returned when filter does not match",
+			token:  token,
+			url:    ts.URL,
+			filter: regexp.MustCompile("none"),
+		},
+	}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			services, err := GetThreescaleServices(input.token, input.url, input.filter, ts.Client())
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+			if !reflect.DeepEqual(services, input.expect) {
+				t.Errorf("unexpected services returned.\nWanted:\n%v\nGot:\n%v", input.expect, services)
+			}
+		})
+	}
+}
+
+func TestNewMultiActionRule(t *testing.T) {
+	conditions := MatchConditions{`context.reporter.kind == "inbound"`}
+	r := NewMultiActionRule(conditions,
+		NewAction("handler-test", "instance-test"),
+		NewAction("handler-other", "instance-one", "instance-two"),
+	)
+	expect := `actions:
+- handler: handler-test
+  instances:
+  - instance-test
+- handler: handler-other
+  instances:
+  - instance-one
+  - instance-two
+match: context.reporter.kind == "inbound"`
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		t.Errorf("unexpected error when converting to JSON")
+	}
+
+	b, err = yaml.JSONToYAML(b)
+	if err != nil {
+		t.Errorf("unexpected error when converting JSON to YAML")
+	}
+
+	if strings.TrimSpace(string(b)) != expect {
+		t.Errorf("unexpected YAML returned.\nWanted:\n%s\nGot:\n%s", expect, string(b))
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	inputs := []struct {
+		name      string
+		action    string
+		expectErr bool
+		expect    *v1beta1.Action
+	}{
+		{
+			name:   "Test single instance",
+			action: "logger.handler.istio-system=accesslog.instance.istio-system",
+			expect: NewAction("logger.handler.istio-system", "accesslog.instance.istio-system"),
+		},
+		{
+			name:   "Test multiple instances",
+			action: "logger.handler.istio-system = one.instance.istio-system, two.instance.istio-system",
+			expect: NewAction("logger.handler.istio-system", "one.instance.istio-system", "two.instance.istio-system"),
+		},
+		{
+			name:      "Test fail with missing instance",
+			action:    "logger.handler.istio-system=",
+			expectErr: true,
+		},
+		{
+			name:      "Test fail with missing handler",
+			action:    "one.instance.istio-system",
+			expectErr: true,
+		},
+	}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			action, err := ParseAction(input.action)
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if !reflect.DeepEqual(action, input.expect) {
+				t.Errorf("unexpected action.\nWanted:\n%v\nGot:\n%v", input.expect, action)
+			}
+		})
+	}
+}
+
+func TestMatchConditionOptions(t *testing.T) {
+	base := MatchConditions{`context.reporter.kind == "inbound"`}
+
+	conditions := base.
+		ExcludePathPrefixes("/health", "/metrics").
+		ForDestinationServices("productpage").
+		ForDestinationNamespaces("bookinfo", "staging").
+		ForRequestMethods("get", "post").
+		WithExpressions(`request.headers["x-skip"] == ""`)
+
+	expect := MatchConditions{
+		`context.reporter.kind == "inbound"`,
+		`!request.url_path.startsWith("/health")`,
+		`!request.url_path.startsWith("/metrics")`,
+		`destination.service.name == "productpage"`,
+		`(destination.namespace == "bookinfo" || destination.namespace == "staging")`,
+		`(request.method == "GET" || request.method == "POST")`,
+		`request.headers["x-skip"] == ""`,
+	}
+
+	if !reflect.DeepEqual(conditions, expect) {
+		t.Errorf("unexpected match conditions.\nWanted:\n%v\nGot:\n%v", expect, conditions)
+	}
+
+	if len(base) != 1 {
+		t.Errorf("expected original match conditions to be unmodified")
+	}
+
+	if err := conditions.Validate(); err != nil {
+		t.Errorf("unexpected error validating match conditions - %v", err)
+	}
+
+	if err := base.WithExpressions(`destination.namespace ==`).Validate(); err == nil {
+		t.Errorf("expected error validating invalid match conditions")
+	}
+
+	if err := base.WithExpressions("").Validate(); err == nil {
+		t.Errorf("expected error validating empty match condition")
+	}
+}
+
+func TestGetProxyConfig(t *testing.T) {
+	const token = "secret-token"
+	const proxyConfigJSON = `{
+  "proxy_config": {
+    "environment": "production",
+    "content": {
+      "backend_version": "2",
+      "proxy": {
+        "proxy_rules": [
+          {"http_method": "GET", "pattern": "/", "metric_system_name": "hits", "delta": 1, "position": 1, "last": false}
+        ]
+      }
+    }
+  }
+}`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/admin/api/services/123/proxy/configs/production/latest.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(proxyConfigJSON))
+	}))
+	defer ts.Close()
+
+	conf, err := GetProxyConfig(token, ts.URL, "123", "production", ts.Client())
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if conf.Content.BackendVersion != "2" || len(conf.Content.Proxy.ProxyRules) != 1 {
+		t.Errorf("unexpected proxy config returned %v", conf)
+	}
+
+	if _, err := GetProxyConfig(token, ts.URL, "321", "production", ts.Client()); err == nil {
+		t.Errorf("expected error for unknown service")
+	}
+}
@@ -27,6 +27,7 @@ type K8sClient struct {
 // These resources are currently specific to the out-of-process adapters
 type IstioClient interface {
 	CreateHandler(name string, inNamespace string, spec HandlerSpec) (*IstioResource, error)
+	ListHandlers(adapter string, inNamespace string) ([]HandlerSpec, error)
 }
 
 // IstioClientImpl provides access to a specific set of Istio resources on Kubernetes
@@ -76,6 +77,8 @@ type InstanceAction struct {
 	Path    string `json:"path,omitempty"`
 	Method  string `json:"method,omitempty"`
 	Service string `json:"service,omitempty"`
+	// Additional attributes about the action.
+	Properties map[string]interface{} `json:"properties,omitempty"`
 }
 
 // MatchConditions - A list of conditions that must be through for a request to match
@@ -90,6 +93,23 @@ type ThreescaleCredentials struct {
 	accessToken string
 }
 
+// CredentialAttributes are the attribute expressions used by an instance to read each 3scale credential from a request
+type CredentialAttributes struct {
+	UserKey  string
+	AppID    string
+	AppKey   string
+	ClientID string
+}
+
+// ThreescaleService describes the subset of a 3scale service required to generate configuration for it
+type ThreescaleService struct {
+	ID         string
+	Name       string
+	SystemName string
+	// BackendVersion determines the authentication pattern configured for the service
+	BackendVersion string
+}
+
 // OutputFormat for configuration
 type OutputFormat int
 
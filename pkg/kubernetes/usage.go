@@ -0,0 +1,50 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+)
+
+// metricSystemNameRegex matches the characters 3scale allows in the system name of a metric
+var metricSystemNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-.]+$`)
+
+// UsageSource is an attribute expression whose value is reported as usage of a 3scale metric
+type UsageSource struct {
+	Metric     string
+	Expression string
+}
+
+// ParseUsageSource parses a usage source in the form metric=expression, for example
+// uploaded_bytes=request.size | 0
+func ParseUsageSource(source string) (UsageSource, error) {
+	parts := strings.SplitN(source, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return UsageSource{}, fmt.Errorf("invalid usage source %q - expected format is metric=expression", source)
+	}
+
+	usage := UsageSource{
+		Metric:     strings.TrimSpace(parts[0]),
+		Expression: strings.TrimSpace(parts[1]),
+	}
+
+	if !metricSystemNameRegex.MatchString(usage.Metric) {
+		return UsageSource{}, fmt.Errorf("invalid metric system name %q in usage source", usage.Metric)
+	}
+
+	if err := ValidateAttributeExpression(usage.Expression); err != nil {
+		return UsageSource{}, err
+	}
+	return usage, nil
+}
+
+// SetUsageSource configures the instance to report the value of the usage expression against its metric,
+// in addition to the deltas of the mapping rules which match a request
+func (bi *BaseInstance) SetUsageSource(usage UsageSource) {
+	if bi.Params.Subject.Properties == nil {
+		bi.Params.Subject.Properties = make(map[string]interface{})
+	}
+	bi.Params.Subject.Properties[threescale.UsageAttributePrefix+usage.Metric] = usage.Expression
+}
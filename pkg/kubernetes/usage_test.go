@@ -0,0 +1,94 @@
+package kubernetes
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseUsageSource(t *testing.T) {
+	inputs := []struct {
+		name      string
+		source    string
+		expect    UsageSource
+		expectErr bool
+	}{
+		{
+			name:   "Test attribute expression",
+			source: "uploaded_bytes=request.size | 0",
+			expect: UsageSource{Metric: "uploaded_bytes", Expression: "request.size | 0"},
+		},
+		{
+			name:   "Test header expression containing separator",
+			source: ` items = request.headers["x-batch-size"] | "" `,
+			expect: UsageSource{Metric: "items", Expression: `request.headers["x-batch-size"] | ""`},
+		},
+		{
+			name:      "Test fail - missing expression",
+			source:    "items=",
+			expectErr: true,
+		},
+		{
+			name:      "Test fail - missing separator",
+			source:    "items",
+			expectErr: true,
+		},
+		{
+			name:      "Test fail - invalid metric",
+			source:    `it"ems=request.size`,
+			expectErr: true,
+		},
+		{
+			name:      "Test fail - invalid expression",
+			source:    `items=request.headers["x-batch-size" |`,
+			expectErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			usage, err := ParseUsageSource(input.source)
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error for source %s", input.source)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if usage != input.expect {
+				t.Errorf("unexpected usage source, wanted %v but got %v", input.expect, usage)
+			}
+		})
+	}
+}
+
+func TestSetUsageSource(t *testing.T) {
+	instance := NewApiKeyInstance(`""`)
+	instance.SetUsageSource(UsageSource{Metric: "uploaded_bytes", Expression: "request.size | 0"})
+
+	expect := map[string]interface{}{
+		"usage.uploaded_bytes": "request.size | 0",
+	}
+	if !reflect.DeepEqual(instance.Params.Subject.Properties, expect) {
+		t.Errorf("unexpected properties.\nWanted:\n%v\nGot:\n%v", expect, instance.Params.Subject.Properties)
+	}
+	// the documented example must pass validation of the generated configuration
+	var generated bytes.Buffer
+	h, _ := NewThreescaleHandlerSpec("secret-token", "http://127.0.0.1:8090", "")
+	rule := NewRule(GetDefaultMatchConditions("threescale"), "threescale.handler.istio-system", "threescale.instance.istio-system")
+	cg, _ := NewConfigGenerator("threescale", *h, *instance, rule)
+	cg.OutputAll(&generated)
+
+	v, err := NewValidator(nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating validator - %v", err)
+	}
+
+	if errs := v.Validate(generated.Bytes()); len(errs) != 0 {
+		t.Errorf("expected configuration reporting usage to be valid but got %v", errs)
+	}
+}
@@ -0,0 +1,327 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	"github.com/ghodss/yaml"
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/pkg/lang"
+	"istio.io/istio/mixer/pkg/lang/ast"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const attributeManifestKind = "attributemanifest"
+
+var documentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// Validator checks handler, instance and rule resources against the Mixer attribute vocabulary
+// so that errors are caught before Mixer rejects the configuration at apply time
+type Validator struct {
+	checker lang.TypeChecker
+}
+
+// rawResource is an Istio resource whose spec has not yet been decoded
+type rawResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              json.RawMessage `json:"spec"`
+}
+
+// attributeManifest is the subset of an Istio attributemanifest resource describing the attribute vocabulary
+type attributeManifest struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Attributes map[string]struct {
+			ValueType string `json:"valueType"`
+		} `json:"attributes"`
+	} `json:"spec"`
+}
+
+//go:generate go run ../../scripts/attributes.go ../../testdata/attributes.yaml attributes.go
+
+// NewValidator creates a Validator for the vocabulary described by the provided attribute manifests in YAML format.
+// The default vocabulary of the Istio proxy and Kubernetes environment is used if no manifests are provided.
+func NewValidator(attributeManifests []byte) (*Validator, error) {
+	if len(attributeManifests) == 0 {
+		attributeManifests = []byte(defaultAttributeManifests)
+	}
+
+	attributes := make(map[string]*v1beta1.AttributeManifest_AttributeInfo)
+	for _, doc := range splitDocuments(attributeManifests) {
+		manifest := attributeManifest{}
+		if err := yaml.Unmarshal(doc, &manifest); err != nil {
+			return nil, fmt.Errorf("error parsing attribute manifest - %v", err)
+		}
+
+		if manifest.Kind != attributeManifestKind {
+			continue
+		}
+
+		for name, info := range manifest.Spec.Attributes {
+			valueType, ok := v1beta1.ValueType_value[info.ValueType]
+			if !ok {
+				return nil, fmt.Errorf("unknown value type %s for attribute %s", info.ValueType, name)
+			}
+			attributes[name] = &v1beta1.AttributeManifest_AttributeInfo{ValueType: v1beta1.ValueType(valueType)}
+		}
+	}
+
+	if len(attributes) == 0 {
+		return nil, fmt.Errorf("no attributes found in provided attribute manifests")
+	}
+
+	return &Validator{checker: lang.NewTypeChecker(ast.NewFinder(attributes), lang.CEXL)}, nil
+}
+
+// Validate the handler, instance and rule resources found in the provided multi document YAML.
+// Resources of other kinds are ignored. All handlers and instances referenced by a rule must be
+// present in the provided resources.
+func (v *Validator) Validate(manifests []byte) []error {
+	var errs []error
+
+	handlers := make(map[string]bool)
+	instances := make(map[string]bool)
+	var rules []rawResource
+
+	for _, doc := range splitDocuments(manifests) {
+		resource := rawResource{}
+		if err := yaml.Unmarshal(doc, &resource); err != nil {
+			errs = append(errs, fmt.Errorf("error parsing resource - %v", err))
+			continue
+		}
+
+		namespace := resource.Namespace
+		if namespace == "" {
+			namespace = DefaultNamespace
+		}
+
+		switch resource.Kind {
+		case handlerKind:
+			handlers[qualifiedName(resource.Name, handlerKind, namespace)] = true
+			errs = append(errs, v.validateHandler(resource)...)
+		case instanceKind:
+			instances[qualifiedName(resource.Name, instanceKind, namespace)] = true
+			errs = append(errs, v.validateInstance(resource)...)
+		case ruleKind:
+			rules = append(rules, resource)
+		}
+	}
+
+	for _, rule := range rules {
+		errs = append(errs, v.validateRule(rule, handlers, instances)...)
+	}
+
+	return errs
+}
+
+// ValidateExpression verifies that the expression is valid for the attribute vocabulary and evaluates to the expected type
+func (v *Validator) ValidateExpression(expression string, expectedType v1beta1.ValueType) error {
+	valueType, err := v.checker.EvalType(expression)
+	if err != nil {
+		return err
+	}
+
+	if valueType != expectedType {
+		return fmt.Errorf("expression evaluates to %s but %s is required", valueType, expectedType)
+	}
+	return nil
+}
+
+func (v *Validator) validateHandler(resource rawResource) []error {
+	var errs []error
+	handler := HandlerSpec{}
+	if err := json.Unmarshal(resource.Spec, &handler); err != nil {
+		return []error{resourceError(resource, "spec", err)}
+	}
+
+	if handler.Adapter == "" {
+		errs = append(errs, resourceError(resource, "adapter", fmt.Errorf("adapter must be provided")))
+	}
+
+	if handler.Params.SystemUrl != "" {
+		if _, err := parseURL(handler.Params.SystemUrl); err != nil {
+			errs = append(errs, resourceError(resource, "params.system_url", err))
+		}
+	}
+
+	if handler.Params.BackendUrl != "" {
+		if _, err := parseURL(handler.Params.BackendUrl); err != nil {
+			errs = append(errs, resourceError(resource, "params.backend_url", err))
+		}
+	}
+
+	return errs
+}
+
+func (v *Validator) validateInstance(resource rawResource) []error {
+	var errs []error
+	instance := BaseInstance{}
+	if err := json.Unmarshal(resource.Spec, &instance); err != nil {
+		return []error{resourceError(resource, "spec", err)}
+	}
+
+	if instance.Template == "" {
+		errs = append(errs, resourceError(resource, "template", fmt.Errorf("template must be provided")))
+	}
+
+	expressions := map[string]string{
+		"params.action.path":    instance.Params.Action.Path,
+		"params.action.method":  instance.Params.Action.Method,
+		"params.action.service": instance.Params.Action.Service,
+		"params.subject.user":   instance.Params.Subject.User,
+	}
+
+	properties := []struct {
+		prefix string
+		values map[string]interface{}
+		// usage is set if the properties can hold usage, read by the adapter from the subject
+		usage bool
+	}{
+		{prefix: "params.subject.properties", values: instance.Params.Subject.Properties, usage: true},
+		{prefix: "params.action.properties", values: instance.Params.Action.Properties},
+	}
+
+	// usage properties hold deltas, which the adapter accepts as integers or strings holding integers
+	usage := make(map[string]bool)
+	for _, props := range properties {
+		for key, property := range props.values {
+			field := fmt.Sprintf("%s.%s", props.prefix, key)
+			expression, ok := property.(string)
+			if !ok {
+				errs = append(errs, resourceError(resource, field, fmt.Errorf("property must be an attribute expression")))
+				continue
+			}
+			expressions[field] = expression
+			usage[field] = props.usage && strings.HasPrefix(key, threescale.UsageAttributePrefix)
+		}
+	}
+
+	for _, field := range sortedKeys(expressions) {
+		if expressions[field] == "" {
+			continue
+		}
+
+		allowed := []v1beta1.ValueType{v1beta1.STRING}
+		if usage[field] {
+			allowed = append(allowed, v1beta1.INT64)
+		}
+
+		if err := v.validateExpressionOf(expressions[field], allowed...); err != nil {
+			errs = append(errs, resourceError(resource, field, err))
+		}
+	}
+
+	return errs
+}
+
+// validateExpressionOf verifies that the expression is valid for the attribute vocabulary and evaluates to one of the allowed types
+func (v *Validator) validateExpressionOf(expression string, allowed ...v1beta1.ValueType) error {
+	if len(allowed) == 1 {
+		return v.ValidateExpression(expression, allowed[0])
+	}
+
+	valueType, err := v.checker.EvalType(expression)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(allowed))
+	for _, t := range allowed {
+		if valueType == t {
+			return nil
+		}
+		names = append(names, t.String())
+	}
+	return fmt.Errorf("expression evaluates to %s but one of %s is required", valueType, strings.Join(names, ", "))
+}
+
+func (v *Validator) validateRule(resource rawResource, handlers, instances map[string]bool) []error {
+	var errs []error
+	rule := Rule{}
+	if err := json.Unmarshal(resource.Spec, &rule); err != nil {
+		return []error{resourceError(resource, "spec", err)}
+	}
+
+	if rule.Match != "" {
+		if err := v.ValidateExpression(rule.Match, v1beta1.BOOL); err != nil {
+			errs = append(errs, resourceError(resource, "match", err))
+		}
+	}
+
+	if len(rule.Actions) == 0 {
+		errs = append(errs, resourceError(resource, "actions", fmt.Errorf("at least one action must be provided")))
+	}
+
+	namespace := resource.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	for i, action := range rule.Actions {
+		if !handlers[resolveReference(action.Handler, handlerKind, namespace)] {
+			errs = append(errs, resourceError(resource, fmt.Sprintf("actions[%d].handler", i),
+				fmt.Errorf("handler %s not found", action.Handler)))
+		}
+
+		if len(action.Instances) == 0 {
+			errs = append(errs, resourceError(resource, fmt.Sprintf("actions[%d].instances", i),
+				fmt.Errorf("at least one instance must be provided")))
+		}
+
+		for _, instance := range action.Instances {
+			if !instances[resolveReference(instance, instanceKind, namespace)] {
+				errs = append(errs, resourceError(resource, fmt.Sprintf("actions[%d].instances", i),
+					fmt.Errorf("instance %s not found", instance)))
+			}
+		}
+	}
+
+	return errs
+}
+
+// resolveReference returns the fully qualified name for a reference made by a rule, which is either
+// fully qualified in the form name.kind.namespace or a short name within the namespace of the rule
+func resolveReference(reference, kind, namespace string) string {
+	parts := strings.Split(reference, ".")
+	switch {
+	case len(parts) == 1:
+		return qualifiedName(reference, kind, namespace)
+	case len(parts) == 3 && parts[1] == kind:
+		return reference
+	default:
+		return ""
+	}
+}
+
+func qualifiedName(name, kind, namespace string) string {
+	return fmt.Sprintf("%s.%s.%s", name, kind, namespace)
+}
+
+func resourceError(resource rawResource, field string, err error) error {
+	return fmt.Errorf("%s %s: %s - %v", resource.Kind, resource.Name, field, err)
+}
+
+// splitDocuments of a multi document YAML stream, dropping empty documents
+func splitDocuments(manifests []byte) [][]byte {
+	var docs [][]byte
+	for _, doc := range documentSeparator.Split(string(manifests), -1) {
+		if strings.TrimSpace(doc) != "" {
+			docs = append(docs, []byte(doc))
+		}
+	}
+	return docs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,287 @@
+package kubernetes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"istio.io/api/policy/v1beta1"
+)
+
+func TestDefaultAttributeManifests(t *testing.T) {
+	path, _ := filepath.Abs("../../testdata/attributes.yaml")
+	testdata, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading testdata file")
+	}
+
+	if strings.TrimSpace(string(testdata)) != strings.TrimSpace(defaultAttributeManifests) {
+		t.Errorf("default attribute manifests are out of date with integration testing test fixtures, run go generate")
+	}
+}
+
+func TestNewValidator(t *testing.T) {
+	if _, err := NewValidator(nil); err != nil {
+		t.Errorf("unexpected error creating validator with default vocabulary - %v", err)
+	}
+
+	if _, err := NewValidator([]byte(`kind: handler`)); err == nil {
+		t.Errorf("expected error when no attributes are provided")
+	}
+
+	_, err := NewValidator([]byte(`
+kind: attributemanifest
+spec:
+  attributes:
+    request.path:
+      valueType: NOT_A_TYPE`))
+	if err == nil {
+		t.Errorf("expected error for unknown value type")
+	}
+}
+
+func TestValidateExpression(t *testing.T) {
+	v, err := NewValidator([]byte(`
+kind: attributemanifest
+spec:
+  attributes:
+    request.path:
+      valueType: STRING
+    request.headers:
+      valueType: STRING_MAP`))
+	if err != nil {
+		t.Fatalf("unexpected error creating validator - %v", err)
+	}
+
+	inputs := []struct {
+		name       string
+		expression string
+		expectType v1beta1.ValueType
+		expectErr  bool
+	}{
+		{
+			name:       "Test valid string expression",
+			expression: `request.headers["user_key"] | ""`,
+			expectType: v1beta1.STRING,
+		},
+		{
+			name:       "Test valid bool expression",
+			expression: `request.path == "/"`,
+			expectType: v1beta1.BOOL,
+		},
+		{
+			name:       "Test fail with unknown attribute",
+			expression: `request.url_path`,
+			expectType: v1beta1.STRING,
+			expectErr:  true,
+		},
+		{
+			name:       "Test fail with unexpected type",
+			expression: `request.path`,
+			expectType: v1beta1.BOOL,
+			expectErr:  true,
+		},
+		{
+			name:       "Test fail with invalid syntax",
+			expression: `request.headers["user_key" | ""`,
+			expectType: v1beta1.STRING,
+			expectErr:  true,
+		},
+	}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			err := v.ValidateExpression(input.expression, input.expectType)
+			if input.expectErr && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !input.expectErr && err != nil {
+				t.Errorf("unexpected error - %v", err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	const name = "threescale"
+
+	var generated bytes.Buffer
+	h, _ := NewThreescaleHandlerSpec("secret-token", "http://127.0.0.1:8090", "")
+	rule := NewRule(GetDefaultMatchConditions(name).ExcludePathPrefixes("/health"),
+		"threescale.handler.istio-system",
+		"threescale.instance.istio-system")
+	cg, _ := NewConfigGenerator(name, *h, *NewDefaultHybridInstance(), rule)
+	cg.OutputAll(&generated)
+
+	const handler = `apiVersion: config.istio.io/v1alpha2
+kind: handler
+metadata:
+  name: threescale
+  namespace: istio-system
+spec:
+  adapter: threescale
+  params:
+    access_token: secret-token
+    system_url: http://127.0.0.1:8090
+`
+
+	const instance = `apiVersion: config.istio.io/v1alpha2
+kind: instance
+metadata:
+  name: threescale
+  namespace: istio-system
+spec:
+  template: threescale-authorization
+  params:
+    action:
+      path: request.url_path
+    subject:
+      user: %s
+`
+
+	inputs := []struct {
+		name           string
+		manifests      string
+		expectErrs     int
+		expectContains string
+	}{
+		{
+			name:      "Test generated configuration is valid",
+			manifests: generated.String(),
+		},
+		{
+			name: "Test short names resolve within rule namespace",
+			manifests: strings.Join([]string{
+				handler,
+				strings.Replace(instance, "%s", `request.headers["user_key"] | ""`, 1),
+				`kind: rule
+metadata:
+  name: threescale
+  namespace: istio-system
+spec:
+  actions:
+  - handler: threescale
+    instances:
+    - threescale
+`,
+			}, "---\n"),
+		},
+		{
+			name: "Test usage property may be an integer",
+			manifests: strings.Join([]string{
+				handler,
+				strings.Replace(instance, "%s", `""
+      properties:
+        usage.uploaded_bytes: request.size | 0
+        usage.items: request.headers["x-items"] | ""`, 1),
+			}, "---\n"),
+		},
+		{
+			name: "Test fail with integer property which is not usage",
+			manifests: strings.Join([]string{
+				handler,
+				strings.Replace(instance, "%s", `""
+      properties:
+        uploaded_bytes: request.size | 0`, 1),
+			}, "---\n"),
+			expectErrs:     1,
+			expectContains: "params.subject.properties.uploaded_bytes",
+		},
+		{
+			name: "Test fail with usage property which is not an integer or string",
+			manifests: strings.Join([]string{
+				handler,
+				strings.Replace(instance, "%s", `""
+      properties:
+        usage.hits: connection.mtls | false`, 1),
+			}, "---\n"),
+			expectErrs:     1,
+			expectContains: "params.subject.properties.usage.hits",
+		},
+		{
+			name: "Test fail with unknown attribute in instance",
+			manifests: strings.Join([]string{
+				handler,
+				strings.Replace(instance, "%s", `request.headers["user_key"] | request.cookie | ""`, 1),
+			}, "---\n"),
+			expectErrs:     1,
+			expectContains: "instance threescale: params.subject.user",
+		},
+		{
+			name: "Test fail with match which is not a boolean",
+			manifests: strings.Join([]string{
+				handler,
+				strings.Replace(instance, "%s", `""`, 1),
+				`kind: rule
+metadata:
+  name: threescale
+  namespace: istio-system
+spec:
+  match: request.url_path
+  actions:
+  - handler: threescale.handler.istio-system
+    instances:
+    - threescale.instance.istio-system
+`,
+			}, "---\n"),
+			expectErrs:     1,
+			expectContains: "rule threescale: match",
+		},
+		{
+			name: "Test fail with unresolved handler and instance",
+			manifests: `kind: rule
+metadata:
+  name: threescale
+  namespace: istio-system
+spec:
+  actions:
+  - handler: missing.handler.istio-system
+    instances:
+    - threescale.instance.other
+`,
+			expectErrs:     2,
+			expectContains: "handler missing.handler.istio-system not found",
+		},
+		{
+			name: "Test fail with invalid handler",
+			manifests: `kind: handler
+metadata:
+  name: threescale
+spec:
+  params:
+    system_url: not-a-url
+`,
+			expectErrs:     2,
+			expectContains: "handler threescale: params.system_url",
+		},
+	}
+
+	v, err := NewValidator(nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating validator - %v", err)
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			errs := v.Validate([]byte(input.manifests))
+			if len(errs) != input.expectErrs {
+				t.Fatalf("expected %d errors but got %d - %v", input.expectErrs, len(errs), errs)
+			}
+
+			if input.expectContains == "" {
+				return
+			}
+
+			var found bool
+			for _, err := range errs {
+				if strings.Contains(err.Error(), input.expectContains) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected an error containing %q but got %v", input.expectContains, errs)
+			}
+		})
+	}
+}
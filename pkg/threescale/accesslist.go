@@ -0,0 +1,289 @@
+package threescale
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/ghodss/yaml"
+	"github.com/gogo/googleapis/google/rpc"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+	"istio.io/istio/mixer/pkg/status"
+	"istio.io/istio/mixer/template/authorization"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// values accepted as the action of an access list entry
+	AccessListAllow = "allow"
+	AccessListDeny  = "deny"
+
+	// AccessListAllowedCode is the message of the status of requests allowed by the access list
+	AccessListAllowedCode = "access_list_allowed"
+	// AccessListDeniedCode is the error code of requests denied by the access list, used in place of a 3scale error code
+	AccessListDeniedCode = "access_list_denied"
+)
+
+// accessListCredentialTypes are the credentials which can be allowed or denied, in the order they are evaluated
+var accessListCredentialTypes = []string{UserKeyAttributeKey, AppIDAttributeKey, OIDCAttributeKey}
+
+// AccessListEntry allows or denies requests which provide a credential, regardless of 3scale
+type AccessListEntry struct {
+	// Type of the credential, one of UserKeyAttributeKey, AppIDAttributeKey or OIDCAttributeKey
+	Type       string `json:"type"`
+	Credential string `json:"credential"`
+	// Action is one of AccessListAllow or AccessListDeny
+	Action string `json:"action"`
+	// Expires is the time after which the entry no longer applies. If zero, the entry does not expire
+	Expires time.Time `json:"expires,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// AccessListDecision describes a request allowed or denied by the access list
+type AccessListDecision struct {
+	ServiceID      string
+	CredentialType string
+	// Reason is one of AccessListAllowedCode or AccessListDeniedCode
+	Reason string
+}
+
+type accessListKey struct {
+	credentialType string
+	credential     string
+}
+
+// AccessList holds credentials which are allowed or denied before requests are authorized against 3scale.
+// Entries are either loaded from files, which replace all previously loaded entries, or added at runtime.
+// An entry added at runtime takes precedence over a loaded entry for the same credential.
+type AccessList struct {
+	loaded  map[accessListKey]AccessListEntry
+	runtime map[accessListKey]AccessListEntry
+	mutex   sync.RWMutex
+}
+
+// NewAccessList returns an empty AccessList
+func NewAccessList() *AccessList {
+	return &AccessList{
+		loaded:  make(map[accessListKey]AccessListEntry),
+		runtime: make(map[accessListKey]AccessListEntry),
+	}
+}
+
+// Load replaces the loaded entries with those listed in files, keyed by file name, as YAML or JSON arrays.
+// If any file is invalid, the previously loaded entries are kept.
+func (l *AccessList) Load(files map[string][]byte) error {
+	loaded := make(map[accessListKey]AccessListEntry)
+	for name, data := range files {
+		var entries []AccessListEntry
+		if err := yaml.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("error parsing access list %s - %v", name, err)
+		}
+
+		for _, entry := range entries {
+			if err := entry.validate(); err != nil {
+				return fmt.Errorf("invalid entry in access list %s - %v", name, err)
+			}
+			loaded[entry.key()] = entry
+		}
+	}
+
+	l.mutex.Lock()
+	l.loaded = loaded
+	l.mutex.Unlock()
+	return nil
+}
+
+// LoadFile replaces the loaded entries with those listed in the file at path
+func (l *AccessList) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading access list - %v", err)
+	}
+	return l.Load(map[string][]byte{path: data})
+}
+
+// Add adds or replaces an entry at runtime
+func (l *AccessList) Add(entry AccessListEntry) error {
+	if err := entry.validate(); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	l.runtime[entry.key()] = entry
+	l.mutex.Unlock()
+	return nil
+}
+
+// Remove removes an entry added at runtime. It returns false if no such entry exists.
+func (l *AccessList) Remove(credentialType, credential string) bool {
+	key := accessListKey{credentialType: credentialType, credential: credential}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, ok := l.runtime[key]; !ok {
+		return false
+	}
+	delete(l.runtime, key)
+	return true
+}
+
+// Entries returns the entries which apply, sorted by credential type and credential
+func (l *AccessList) Entries() []AccessListEntry {
+	now := time.Now()
+
+	l.mutex.RLock()
+	merged := make(map[accessListKey]AccessListEntry, len(l.loaded)+len(l.runtime))
+	for key, entry := range l.loaded {
+		merged[key] = entry
+	}
+	for key, entry := range l.runtime {
+		merged[key] = entry
+	}
+	l.mutex.RUnlock()
+
+	entries := make([]AccessListEntry, 0, len(merged))
+	for _, entry := range merged {
+		if !entry.expired(now) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		return entries[i].Credential < entries[j].Credential
+	})
+	return entries
+}
+
+// lookup returns the entry which applies to a credential, if any
+func (l *AccessList) lookup(credentialType, credential string) (AccessListEntry, bool) {
+	key := accessListKey{credentialType: credentialType, credential: credential}
+	now := time.Now()
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if entry, ok := l.runtime[key]; ok && !entry.expired(now) {
+		return entry, true
+	}
+	if entry, ok := l.loaded[key]; ok && !entry.expired(now) {
+		return entry, true
+	}
+	return AccessListEntry{}, false
+}
+
+// decide returns the entry which decides a request providing credentials, keyed by credential type.
+// A denied credential takes precedence over an allowed one.
+func (l *AccessList) decide(credentials map[string]string) (AccessListEntry, bool) {
+	var allowed *AccessListEntry
+	for _, credentialType := range accessListCredentialTypes {
+		credential := credentials[credentialType]
+		if credential == "" {
+			continue
+		}
+
+		entry, ok := l.lookup(credentialType, credential)
+		if !ok {
+			continue
+		}
+
+		if entry.Action == AccessListDeny {
+			return entry, true
+		}
+
+		if allowed == nil {
+			allowed = &entry
+		}
+	}
+
+	if allowed == nil {
+		return AccessListEntry{}, false
+	}
+	return *allowed, true
+}
+
+func (e AccessListEntry) key() accessListKey {
+	return accessListKey{credentialType: e.Type, credential: e.Credential}
+}
+
+func (e AccessListEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+func (e AccessListEntry) validate() error {
+	var errMsgs []string
+	switch e.Type {
+	case UserKeyAttributeKey, AppIDAttributeKey, OIDCAttributeKey:
+	default:
+		errMsgs = append(errMsgs, fmt.Sprintf("type must be one of %q, %q or %q", UserKeyAttributeKey, AppIDAttributeKey, OIDCAttributeKey))
+	}
+
+	if e.Credential == "" {
+		errMsgs = append(errMsgs, "credential must be set")
+	}
+
+	switch e.Action {
+	case AccessListAllow, AccessListDeny:
+	default:
+		errMsgs = append(errMsgs, fmt.Sprintf("action must be one of %q or %q", AccessListAllow, AccessListDeny))
+	}
+
+	if len(errMsgs) > 0 {
+		return errors.New(strings.Join(errMsgs, ", "))
+	}
+	return nil
+}
+
+// credentialsFromInstance returns the credentials provided by the instance, keyed by credential type.
+// Unlike the credentials sent to 3scale, these do not depend on the authentication pattern of the service.
+func credentialsFromInstance(instance *authorization.InstanceMsg) map[string]string {
+	credentials := make(map[string]string)
+	if instance == nil || instance.Subject == nil {
+		return credentials
+	}
+
+	credentials[UserKeyAttributeKey] = instance.Subject.User
+	for _, key := range []string{AppIDAttributeKey, OIDCAttributeKey} {
+		credentials[key] = instance.Subject.Properties[key].GetStringValue()
+	}
+
+	for _, key := range accessListCredentialTypes {
+		if credentials[key] == "" {
+			credentials[key] = credentialFromCookie(instance.Subject, key)
+		}
+	}
+	return credentials
+}
+
+// accessListResult sets the status of the result for a request decided by the access list.
+// Denied requests are described as if they had been denied by 3scale with AccessListDeniedCode.
+func (s *Threescale) accessListResult(entry AccessListEntry, result *v1beta1.CheckResult, cfg *config.Params, requestID string) *v1beta1.CheckResult {
+	decision := AccessListDecision{
+		ServiceID:      cfg.ServiceId,
+		CredentialType: entry.Type,
+		Reason:         AccessListAllowedCode,
+	}
+
+	if entry.Action == AccessListDeny {
+		decision.Reason = AccessListDeniedCode
+		result, _ = s.convertAuthResponse(&authorizer.BackendResponse{ErrorCode: AccessListDeniedCode}, result, nil, cfg, requestID)
+	} else {
+		result.Status = status.WithMessage(rpc.OK, AccessListAllowedCode)
+	}
+
+	log.Infof("request to service %s with %s %s decided by access list - %s",
+		cfg.ServiceId, entry.Type, s.conf.Redactor.Secret(entry.Credential), decision.Reason)
+
+	if s.conf.OnAccessListDecision != nil {
+		s.conf.OnAccessListDecision(decision)
+	}
+	return result
+}
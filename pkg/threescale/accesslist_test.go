@@ -0,0 +1,232 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	policy "istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+const testAccessList = `
+- type: user_key
+  credential: leaked
+  action: deny
+  comment: leaked in incident 42
+- type: app_id
+  credential: partner
+  action: allow
+- type: user_key
+  credential: expired
+  action: deny
+  expires: "2000-01-01T00:00:00Z"
+`
+
+func TestAccessList(t *testing.T) {
+	list := NewAccessList()
+	if err := list.Load(map[string][]byte{"access-list.yaml": []byte(testAccessList)}); err != nil {
+		t.Fatalf("unexpected error loading access list - %v", err)
+	}
+
+	inputs := []struct {
+		name         string
+		credentials  map[string]string
+		expectAction string
+	}{
+		{
+			name:         "Test denied credential",
+			credentials:  map[string]string{UserKeyAttributeKey: "leaked"},
+			expectAction: AccessListDeny,
+		},
+		{
+			name:         "Test allowed credential",
+			credentials:  map[string]string{AppIDAttributeKey: "partner"},
+			expectAction: AccessListAllow,
+		},
+		{
+			name:         "Test denial takes precedence over allowed credential",
+			credentials:  map[string]string{UserKeyAttributeKey: "leaked", AppIDAttributeKey: "partner"},
+			expectAction: AccessListDeny,
+		},
+		{
+			name:        "Test credential type must match",
+			credentials: map[string]string{OIDCAttributeKey: "partner"},
+		},
+		{
+			name:        "Test expired entry does not apply",
+			credentials: map[string]string{UserKeyAttributeKey: "expired"},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			entry, ok := list.decide(input.credentials)
+			if ok != (input.expectAction != "") || entry.Action != input.expectAction {
+				t.Errorf("expected action %q but got %+v", input.expectAction, entry)
+			}
+		})
+	}
+
+	if entries := list.Entries(); len(entries) != 2 || entries[0].Credential != "partner" {
+		t.Errorf("expected unexpired entries sorted by type but got %+v", entries)
+	}
+
+	if err := list.Load(map[string][]byte{"access-list.yaml": []byte(`[{"type": "password", "credential": "x", "action": "deny"}]`)}); err == nil {
+		t.Errorf("expected error loading invalid entry")
+	}
+
+	if _, ok := list.decide(map[string]string{UserKeyAttributeKey: "leaked"}); !ok {
+		t.Errorf("expected previously loaded entries to be kept when loading fails")
+	}
+
+	runtimeEntry := AccessListEntry{Type: UserKeyAttributeKey, Credential: "leaked", Action: AccessListAllow, Expires: time.Now().Add(time.Hour)}
+	if err := list.Add(runtimeEntry); err != nil {
+		t.Fatalf("unexpected error adding entry - %v", err)
+	}
+
+	if entry, _ := list.decide(map[string]string{UserKeyAttributeKey: "leaked"}); entry.Action != AccessListAllow {
+		t.Errorf("expected entry added at runtime to take precedence but got %+v", entry)
+	}
+
+	if !list.Remove(UserKeyAttributeKey, "leaked") || list.Remove(UserKeyAttributeKey, "leaked") {
+		t.Errorf("expected entry added at runtime to be removed once")
+	}
+
+	if entry, _ := list.decide(map[string]string{UserKeyAttributeKey: "leaked"}); entry.Action != AccessListDeny {
+		t.Errorf("expected loaded entry to apply once runtime entry is removed but got %+v", entry)
+	}
+}
+
+func TestHandleAuthorizationAccessList(t *testing.T) {
+	list := NewAccessList()
+	if err := list.Load(map[string][]byte{"access-list.yaml": []byte(testAccessList)}); err != nil {
+		t.Fatalf("unexpected error loading access list - %v", err)
+	}
+
+	inputs := []struct {
+		name          string
+		params        config.Params
+		subject       *authorization.SubjectMsg
+		expectCode    rpc.Code
+		expectMessage string
+		expectBackend bool
+		expectReason  string
+	}{
+		{
+			name:          "Test denied credential is not authorized against 3scale",
+			params:        config.Params{ServiceId: "123"},
+			subject:       &authorization.SubjectMsg{User: "leaked"},
+			expectCode:    rpc.PERMISSION_DENIED,
+			expectMessage: AccessListDeniedCode,
+			expectReason:  AccessListDeniedCode,
+		},
+		{
+			name:          "Test denied credential in shadow mode",
+			params:        config.Params{ServiceId: "123", Mode: ModeShadow},
+			subject:       &authorization.SubjectMsg{User: "leaked"},
+			expectCode:    rpc.PERMISSION_DENIED,
+			expectMessage: AccessListDeniedCode,
+			expectReason:  AccessListDeniedCode,
+		},
+		{
+			name:   "Test status override applies to denied credential",
+			params: config.Params{ServiceId: "123", StatusOverrides: map[string]string{AccessListDeniedCode: "UNAUTHENTICATED"}},
+			subject: &authorization.SubjectMsg{
+				Properties: map[string]*policy.Value{
+					CookieAttributeKey:                       stringValue("key=leaked"),
+					UserKeyAttributeKey + CookieSourceSuffix: stringValue("key"),
+				},
+			},
+			expectCode:    rpc.UNAUTHENTICATED,
+			expectMessage: AccessListDeniedCode,
+			expectReason:  AccessListDeniedCode,
+		},
+		{
+			name:          "Test allowed credential is not authorized against 3scale",
+			params:        config.Params{ServiceId: "123"},
+			subject:       &authorization.SubjectMsg{Properties: map[string]*policy.Value{AppIDAttributeKey: stringValue("partner")}},
+			expectCode:    rpc.OK,
+			expectMessage: AccessListAllowedCode,
+			expectReason:  AccessListAllowedCode,
+		},
+		{
+			name:          "Test other credentials are authorized against 3scale",
+			params:        config.Params{ServiceId: "123"},
+			subject:       &authorization.SubjectMsg{User: "VALID"},
+			expectCode:    rpc.OK,
+			expectBackend: true,
+		},
+	}
+
+	conf := proxyConfigWithRules(1)
+	conf.Content.BackendVersion = apiKeyTypeIdentifier
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var decisions []AccessListDecision
+			calledBackend := false
+
+			input.params.SystemUrl = "https://www.fake-system.3scale.net"
+			input.params.AccessToken = "happy-path"
+			b, _ := input.params.Marshal()
+
+			s := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: conf,
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							calledBackend = true
+						},
+						withAuthResponse: &authorizer.BackendResponse{},
+						t:                t,
+					},
+					AccessList: list,
+					OnAccessListDecision: func(decision AccessListDecision) {
+						decisions = append(decisions, decision)
+					},
+				},
+			}
+
+			result, err := s.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Subject: input.subject,
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/resource/0/items",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if err != nil {
+				t.Errorf("unexpected error - %v", err)
+			}
+
+			if result.Status.Code != int32(input.expectCode) || result.Status.Message != input.expectMessage {
+				t.Errorf("expected status %v with message %q but got %v", input.expectCode, input.expectMessage, result.Status)
+			}
+
+			if calledBackend != input.expectBackend {
+				t.Errorf("expected call to 3scale backend to be %v", input.expectBackend)
+			}
+
+			if input.expectReason == "" {
+				if len(decisions) != 0 {
+					t.Errorf("expected no access list decisions but got %v", decisions)
+				}
+				return
+			}
+
+			if len(decisions) != 1 || decisions[0].Reason != input.expectReason || decisions[0].ServiceID != "123" {
+				t.Errorf("expected decision with reason %s but got %v", input.expectReason, decisions)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package threescale
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-porta-go-client/client"
+
+	"istio.io/istio/pkg/log"
+)
+
+// CachedService describes the proxy configuration of a service held by a CachingAuthorizer
+type CachedService struct {
+	SystemURL   string    `json:"system_url"`
+	ServiceID   string    `json:"service_id"`
+	Environment string    `json:"environment"`
+	Version     int       `json:"version"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	// Restored is set for configurations restored from a snapshot which have not been fetched again yet
+	Restored bool `json:"restored,omitempty"`
+	// ProxyRules are the mapping rules of the service in order of priority, only set when describing a single service
+	ProxyRules []client.ProxyRule `json:"proxy_rules,omitempty"`
+}
+
+// PendingUsage is usage held by a backend cache which has not been reported to 3scale yet
+type PendingUsage struct {
+	ServiceID   string      `json:"service_id"`
+	Application string      `json:"application"`
+	Metrics     api.Metrics `json:"metrics"`
+}
+
+// BackendCacheController is implemented by Authorizers whose backend cache can be flushed, inspected and invalidated on demand
+type BackendCacheController interface {
+	FlushBackendCache()
+	PendingUsage() []PendingUsage
+	// InvalidateApplication removes the cached state of an application, identified by its user key or application ID
+	InvalidateApplication(serviceID, application string)
+}
+
+// Invalidator is implemented by Authorizers which cache proxy configurations, or what is described by them
+type Invalidator interface {
+	// Invalidate removes the cached entries of a service, or of all services if serviceID is empty.
+	// It returns the number of entries removed.
+	Invalidate(serviceID string) int
+}
+
+// CachingAuthorizer caches the proxy configurations fetched by an Authorizer so that they can be inspected and invalidated,
+// and delegates all other calls. The system cache of the wrapped Authorizer should be disabled.
+// Configurations older than the refresh interval are served while they are fetched again in the background,
+// and configurations older than the ttl are fetched before being served. If fetching fails, a cached configuration is
+// served until it is older than the ttl. Configurations can be persisted to a snapshot, see UseSnapshot.
+type CachingAuthorizer struct {
+	Authorizer
+	// Redactor scrubs tokens from logs. If nil, secrets are replaced by fingerprints.
+	Redactor *Redactor
+
+	ttl     time.Duration
+	refresh time.Duration
+	maxSize int
+
+	cache map[pinnedKey]*cachedConfig
+	mutex sync.RWMutex
+
+	maxStaleness    time.Duration
+	snapshotChanged chan struct{}
+	snapshotStop    chan struct{}
+	snapshotDone    chan struct{}
+	snapshotMutex   sync.Mutex
+}
+
+type cachedConfig struct {
+	conf       client.ProxyConfig
+	fetched    time.Time
+	refreshing bool
+	// restored configurations are served until they are older than the max staleness and are always refreshed
+	restored bool
+}
+
+// NewCachingAuthorizer returns an Authorizer which caches up to maxSize proxy configurations. If maxSize is zero, the size is unbounded.
+func NewCachingAuthorizer(authz Authorizer, ttl, refresh time.Duration, maxSize int) *CachingAuthorizer {
+	return &CachingAuthorizer{
+		Authorizer: authz,
+		ttl:        ttl,
+		refresh:    refresh,
+		maxSize:    maxSize,
+		cache:      make(map[pinnedKey]*cachedConfig),
+	}
+}
+
+// GetSystemConfiguration returns the cached proxy configuration of the requested service, fetching it if required
+func (c *CachingAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	// access tokens are held as digests so that they are not persisted to snapshots
+	key := pinnedKey{
+		systemURL:   systemURL,
+		accessToken: tokenDigest(request.AccessToken),
+		serviceID:   request.ServiceID,
+		environment: request.Environment,
+	}
+	now := time.Now()
+
+	c.mutex.Lock()
+	cached, ok := c.cache[key]
+	if ok && c.usable(cached, now) {
+		if (cached.restored || now.Sub(cached.fetched) >= c.refresh) && !cached.refreshing {
+			cached.refreshing = true
+			go c.fetch(key, systemURL, request, true)
+		}
+		conf := cached.conf
+		c.mutex.Unlock()
+		return conf, nil
+	}
+	c.mutex.Unlock()
+
+	return c.fetch(key, systemURL, request, false)
+}
+
+// fetch fetches the proxy configuration from the wrapped Authorizer and caches it.
+// A configuration refreshed in the background is discarded if it was invalidated while being fetched.
+func (c *CachingAuthorizer) fetch(key pinnedKey, systemURL string, request authorizer.SystemRequest, background bool) (client.ProxyConfig, error) {
+	conf, err := c.Authorizer.GetSystemConfiguration(systemURL, request)
+
+	c.mutex.Lock()
+	cached, ok := c.cache[key]
+	if err != nil {
+		defer c.mutex.Unlock()
+		if !ok {
+			return client.ProxyConfig{}, err
+		}
+
+		cached.refreshing = false
+		if !c.usable(cached, time.Now()) {
+			return client.ProxyConfig{}, err
+		}
+		log.Warnf("%s", c.Redactor.String(fmt.Sprintf("failed to refresh proxy config of service %s, serving cached config - %v", request.ServiceID, err)))
+		return cached.conf, nil
+	}
+
+	if !ok && background {
+		c.mutex.Unlock()
+		return conf, nil
+	}
+
+	if !ok && c.maxSize > 0 && len(c.cache) >= c.maxSize {
+		c.evictOldest()
+	}
+	c.cache[key] = &cachedConfig{conf: conf, fetched: time.Now()}
+	c.mutex.Unlock()
+
+	c.scheduleSnapshot()
+	return conf, nil
+}
+
+// usable returns true if a cached configuration can be served. The mutex must be held.
+func (c *CachingAuthorizer) usable(cached *cachedConfig, now time.Time) bool {
+	if cached.restored {
+		return now.Sub(cached.fetched) < c.maxStaleness
+	}
+	return now.Sub(cached.fetched) < c.ttl
+}
+
+// evictOldest removes the least recently fetched configuration. The mutex must be held.
+func (c *CachingAuthorizer) evictOldest() {
+	var oldest pinnedKey
+	var oldestFetched time.Time
+	for key, cached := range c.cache {
+		if oldestFetched.IsZero() || cached.fetched.Before(oldestFetched) {
+			oldest, oldestFetched = key, cached.fetched
+		}
+	}
+	delete(c.cache, oldest)
+}
+
+// Services describes the cached proxy configurations, sorted by service ID, system URL and environment
+func (c *CachingAuthorizer) Services() []CachedService {
+	return c.describe("", false)
+}
+
+// Service describes the cached proxy configurations of a service, including its mapping rules
+func (c *CachingAuthorizer) Service(serviceID string) []CachedService {
+	return c.describe(serviceID, true)
+}
+
+func (c *CachingAuthorizer) describe(serviceID string, withRules bool) []CachedService {
+	c.mutex.RLock()
+	services := make([]CachedService, 0, len(c.cache))
+	for key, cached := range c.cache {
+		if serviceID != "" && key.serviceID != serviceID {
+			continue
+		}
+
+		service := CachedService{
+			SystemURL:   key.systemURL,
+			ServiceID:   key.serviceID,
+			Environment: cached.conf.Environment,
+			Version:     cached.conf.Version,
+			FetchedAt:   cached.fetched,
+			Restored:    cached.restored,
+		}
+
+		if withRules {
+			service.ProxyRules = append([]client.ProxyRule(nil), cached.conf.Content.Proxy.ProxyRules...)
+			sort.SliceStable(service.ProxyRules, func(i, j int) bool {
+				return service.ProxyRules[i].Position < service.ProxyRules[j].Position
+			})
+		}
+		services = append(services, service)
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].ServiceID != services[j].ServiceID {
+			return services[i].ServiceID < services[j].ServiceID
+		}
+		if services[i].SystemURL != services[j].SystemURL {
+			return services[i].SystemURL < services[j].SystemURL
+		}
+		return services[i].Environment < services[j].Environment
+	})
+	return services
+}
+
+// Invalidate removes the cached proxy configurations of a service, or of all services if serviceID is empty,
+// so that they are fetched on their next request. It returns the number of configurations removed.
+func (c *CachingAuthorizer) Invalidate(serviceID string) int {
+	c.mutex.Lock()
+	removed := 0
+	for key := range c.cache {
+		if serviceID == "" || key.serviceID == serviceID {
+			delete(c.cache, key)
+			removed++
+		}
+	}
+	c.mutex.Unlock()
+
+	if removed > 0 {
+		c.scheduleSnapshot()
+	}
+	return removed
+}
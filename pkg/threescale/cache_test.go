@@ -0,0 +1,116 @@
+package threescale
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// countingAuthorizer counts fetches of proxy configurations
+type countingAuthorizer struct {
+	mockAuthorizer
+	calls map[string]int
+	err   error
+	mutex sync.Mutex
+}
+
+func (c *countingAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls[request.ServiceID]++
+	return c.withConfig, c.err
+}
+
+func (c *countingAuthorizer) callsFor(serviceID string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.calls[serviceID]
+}
+
+func (c *countingAuthorizer) setErr(err error) {
+	c.mutex.Lock()
+	c.err = err
+	c.mutex.Unlock()
+}
+
+func TestCachingAuthorizer(t *testing.T) {
+	conf := proxyConfigWithRules(3)
+	conf.Version = 7
+	authz := &countingAuthorizer{mockAuthorizer: mockAuthorizer{withConfig: conf}, calls: make(map[string]int)}
+	c := NewCachingAuthorizer(authz, time.Minute, time.Minute, 2)
+
+	for _, serviceID := range []string{"123", "123", "456"} {
+		if _, err := c.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: serviceID}); err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+	}
+
+	if calls := authz.callsFor("123"); calls != 1 {
+		t.Errorf("expected cached config to be served but got %d fetches", calls)
+	}
+
+	services := c.Services()
+	if len(services) != 2 || services[0].ServiceID != "123" || services[0].Version != 7 || services[0].ProxyRules != nil {
+		t.Errorf("unexpected cached services %+v", services)
+	}
+
+	described := c.Service("123")
+	if len(described) != 1 || len(described[0].ProxyRules) != 3 || described[0].ProxyRules[0].Position != 1 {
+		t.Errorf("expected service with mapping rules in order of priority but got %+v", described)
+	}
+
+	if removed := c.Invalidate("123"); removed != 1 {
+		t.Errorf("expected one config to be invalidated but got %d", removed)
+	}
+
+	c.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: "123"})
+	if calls := authz.callsFor("123"); calls != 2 {
+		t.Errorf("expected invalidated config to be fetched but got %d fetches", calls)
+	}
+
+	c.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: "789"})
+	if services := c.Services(); len(services) != 2 || services[0].ServiceID != "123" || services[1].ServiceID != "789" {
+		t.Errorf("expected oldest config to be evicted when full but got %+v", services)
+	}
+
+	if removed := c.Invalidate(""); removed != 2 || len(c.Services()) != 0 {
+		t.Errorf("expected all configs to be invalidated but got %d", removed)
+	}
+}
+
+func TestCachingAuthorizerRefresh(t *testing.T) {
+	authz := &countingAuthorizer{mockAuthorizer: mockAuthorizer{withConfig: proxyConfigWithRules(1)}, calls: make(map[string]int)}
+	c := NewCachingAuthorizer(authz, time.Minute, time.Second, 0)
+	request := authorizer.SystemRequest{ServiceID: "123"}
+
+	if _, err := c.GetSystemConfiguration("https://system", request); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	authz.setErr(errors.New("system unavailable"))
+	c.cache[pinnedKey{systemURL: "https://system", serviceID: "123"}].fetched = time.Now().Add(-time.Second * 2)
+
+	if _, err := c.GetSystemConfiguration("https://system", request); err != nil {
+		t.Errorf("expected config past its refresh interval to be served - %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for authz.callsFor("123") < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if calls := authz.callsFor("123"); calls != 2 {
+		t.Fatalf("expected config to be refreshed in the background but got %d fetches", calls)
+	}
+
+	c.mutex.Lock()
+	c.cache[pinnedKey{systemURL: "https://system", serviceID: "123"}].fetched = time.Now().Add(-time.Minute * 2)
+	c.mutex.Unlock()
+
+	if _, err := c.GetSystemConfiguration("https://system", request); err == nil {
+		t.Errorf("expected error once cached config is older than the ttl")
+	}
+}
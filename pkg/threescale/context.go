@@ -0,0 +1,203 @@
+package threescale
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+
+	"istio.io/istio/mixer/pkg/status"
+)
+
+// ContextAuthorizer is an Authorizer whose calls are bound to the context of the request being authorized,
+// so that they are given up on once the request is cancelled or its deadline is exceeded
+type ContextAuthorizer interface {
+	GetSystemConfigurationContext(ctx context.Context, systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error)
+	AuthRepContext(ctx context.Context, backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error)
+	OauthAuthRepContext(ctx context.Context, backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error)
+}
+
+// ContextProductSource is a ProductSource whose calls are bound to the context of the request being authorized
+type ContextProductSource interface {
+	GetProductContext(ctx context.Context, systemURL string, request authorizer.SystemRequest) (Product, error)
+}
+
+// maxShimCalls bounds the calls made by contextShim in goroutines of their own. Calls which are given up on keep running,
+// as their HTTP requests cannot be cancelled, so once the bound is reached calls are made on the goroutine of the caller
+// and are not given up on, so that a slow 3scale cannot pile up goroutines.
+const maxShimCalls = 1024
+
+var shimCalls = make(chan struct{}, maxShimCalls)
+
+// WithContext returns the Authorizer as a ContextAuthorizer. Calls to an Authorizer which is not a ContextAuthorizer,
+// such as an authorizer.Manager, are made in a new goroutine which the caller stops waiting for once the context is done.
+// The HTTP requests of such calls cannot be cancelled and run until the timeout of the http client of the Authorizer.
+// Up to maxShimCalls such calls run at once, after which calls wait for 3scale regardless of the context.
+func WithContext(authz Authorizer) ContextAuthorizer {
+	if c, ok := authz.(ContextAuthorizer); ok {
+		return c
+	}
+	return contextShim{Authorizer: authz}
+}
+
+// contextShim adapts an Authorizer which takes no context
+type contextShim struct {
+	Authorizer
+}
+
+type systemResult struct {
+	conf client.ProxyConfig
+	err  error
+}
+
+type backendResult struct {
+	resp *authorizer.BackendResponse
+	err  error
+}
+
+func (s contextShim) GetSystemConfigurationContext(ctx context.Context, systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	if err := ctx.Err(); err != nil {
+		return client.ProxyConfig{}, err
+	}
+
+	release, ok := acquireShimCall()
+	if !ok {
+		return s.GetSystemConfiguration(systemURL, request)
+	}
+
+	// buffered so that the goroutine of an abandoned call does not block
+	results := make(chan systemResult, 1)
+	go func() {
+		defer release()
+		conf, err := s.GetSystemConfiguration(systemURL, request)
+		results <- systemResult{conf: conf, err: err}
+	}()
+
+	select {
+	case result := <-results:
+		return result.conf, result.err
+	case <-ctx.Done():
+		return client.ProxyConfig{}, ctx.Err()
+	}
+}
+
+func (s contextShim) AuthRepContext(ctx context.Context, backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return callBackend(ctx, func() (*authorizer.BackendResponse, error) {
+		return s.AuthRep(backendURL, request)
+	})
+}
+
+func (s contextShim) OauthAuthRepContext(ctx context.Context, backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return callBackend(ctx, func() (*authorizer.BackendResponse, error) {
+		return s.OauthAuthRep(backendURL, request)
+	})
+}
+
+// callBackend makes a call to 3scale backend in a new goroutine, if available, returning early if the context is done
+func callBackend(ctx context.Context, call func() (*authorizer.BackendResponse, error)) (*authorizer.BackendResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	release, ok := acquireShimCall()
+	if !ok {
+		return call()
+	}
+
+	results := make(chan backendResult, 1)
+	go func() {
+		defer release()
+		resp, err := call()
+		results <- backendResult{resp: resp, err: err}
+	}()
+
+	select {
+	case result := <-results:
+		return result.resp, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// acquireShimCall returns true if a call can be made in a goroutine of its own, which must release it once done
+func acquireShimCall() (func(), bool) {
+	calls := shimCalls
+	select {
+	case calls <- struct{}{}:
+		return func() { <-calls }, true
+	default:
+		return nil, false
+	}
+}
+
+// configFromSource returns the proxy config of the source, bound to the context if the source is an Authorizer
+func configFromSource(ctx context.Context, source ConfigSource, systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	if authz, ok := source.(Authorizer); ok {
+		return WithContext(authz).GetSystemConfigurationContext(ctx, systemURL, request)
+	}
+	return source.GetSystemConfiguration(systemURL, request)
+}
+
+// contextErrorToRpcStatus returns the status of a request which failed because its context is done, or fn otherwise
+func contextErrorToRpcStatus(ctx context.Context, fn func(string) rpc.Status) func(string) rpc.Status {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return status.WithDeadlineExceeded
+	case context.Canceled:
+		return status.WithCancelled
+	}
+	return fn
+}
+
+// clientWithContext returns a copy of the http client whose requests are bound to the context.
+// The deadline set on requests by the timeout of the client still applies.
+func clientWithContext(ctx context.Context, httpClient *http.Client) *http.Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	c := *httpClient
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.Transport = contextTransport{ctx: ctx, base: transport}
+	return &c
+}
+
+// contextTransport sends requests with its context in place of their own
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := t.ctx, context.CancelFunc(func() {})
+	if deadline, ok := req.Context().Deadline(); ok {
+		ctx, cancel = context.WithDeadline(t.ctx, deadline)
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the context must not be cancelled before the body has been read
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
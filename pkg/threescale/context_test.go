@@ -0,0 +1,301 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// blockingAuthorizer blocks calls to 3scale backend until released
+type blockingAuthorizer struct {
+	mockAuthorizer
+	called  chan struct{}
+	release chan struct{}
+}
+
+func newBlockingAuthorizer() *blockingAuthorizer {
+	return &blockingAuthorizer{
+		mockAuthorizer: mockAuthorizer{withAuthResponse: &authorizer.BackendResponse{Authorized: true}},
+		called:         make(chan struct{}, 1),
+		release:        make(chan struct{}),
+	}
+}
+
+func (b *blockingAuthorizer) AuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	b.called <- struct{}{}
+	<-b.release
+	return b.withAuthResponse, nil
+}
+
+// waitForGoroutines waits for the number of goroutines to drop to n, returning false if it does not
+func waitForGoroutines(n int) bool {
+	for i := 0; i < 100; i++ {
+		if runtime.NumGoroutine() <= n {
+			return true
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return false
+}
+
+func TestWithContext(t *testing.T) {
+	inputs := []struct {
+		name      string
+		ctx       func() (context.Context, context.CancelFunc)
+		cancel    bool
+		expectErr error
+	}{
+		{
+			name: "Test call is given up on when cancelled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			cancel:    true,
+			expectErr: context.Canceled,
+		},
+		{
+			name: "Test call is given up on when deadline is exceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond*50)
+			},
+			expectErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			goroutines := runtime.NumGoroutine()
+			authz := newBlockingAuthorizer()
+			ctx, cancel := input.ctx()
+			defer cancel()
+
+			errs := make(chan error, 1)
+			go func() {
+				_, err := WithContext(authz).AuthRepContext(ctx, "", authorizer.BackendRequest{})
+				errs <- err
+			}()
+
+			<-authz.called
+			if input.cancel {
+				cancel()
+			}
+
+			select {
+			case err := <-errs:
+				if err != input.expectErr {
+					t.Errorf("expected error %v but got %v", input.expectErr, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected caller to be released while 3scale backend is blocked")
+			}
+
+			// the abandoned call holds a single goroutine until it returns
+			if !waitForGoroutines(goroutines + 1) {
+				t.Errorf("expected only the abandoned call to hold a goroutine but %d are running, up from %d", runtime.NumGoroutine(), goroutines)
+			}
+
+			close(authz.release)
+			if !waitForGoroutines(goroutines) {
+				t.Errorf("expected goroutines to be released but %d are running, up from %d", runtime.NumGoroutine(), goroutines)
+			}
+		})
+	}
+
+	t.Run("Test abandoned calls are bounded", func(t *testing.T) {
+		defer func(calls chan struct{}) { shimCalls = calls }(shimCalls)
+		shimCalls = make(chan struct{}, 1)
+
+		goroutines := runtime.NumGoroutine()
+		authz := newBlockingAuthorizer()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		errs := make(chan error, 2)
+		go func() {
+			_, err := WithContext(authz).AuthRepContext(ctx, "", authorizer.BackendRequest{})
+			errs <- err
+		}()
+		<-authz.called
+		cancel()
+		<-errs
+
+		// no goroutine is left to make the call in, so it is made by the caller and not given up on
+		go func() {
+			_, err := WithContext(authz).AuthRepContext(context.Background(), "", authorizer.BackendRequest{})
+			errs <- err
+		}()
+		<-authz.called
+
+		if !waitForGoroutines(goroutines + 2) {
+			t.Errorf("expected calls beyond the bound not to hold a goroutine but %d are running, up from %d", runtime.NumGoroutine(), goroutines)
+		}
+
+		close(authz.release)
+		if err := <-errs; err != nil {
+			t.Errorf("unexpected error - %v", err)
+		}
+
+		if !waitForGoroutines(goroutines) {
+			t.Errorf("expected goroutines to be released but %d are running, up from %d", runtime.NumGoroutine(), goroutines)
+		}
+	})
+
+	t.Run("Test done context is not called", func(t *testing.T) {
+		authz := newBlockingAuthorizer()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := WithContext(authz).AuthRepContext(ctx, "", authorizer.BackendRequest{}); err != context.Canceled {
+			t.Errorf("expected error %v but got %v", context.Canceled, err)
+		}
+
+		if len(authz.called) != 0 {
+			t.Errorf("expected 3scale backend not to be called")
+		}
+	})
+
+	t.Run("Test context authorizer is used as is", func(t *testing.T) {
+		pinned := NewPinnedAuthorizer(mockAuthorizer{}, 1, nil)
+		if WithContext(pinned) != ContextAuthorizer(pinned) {
+			t.Errorf("expected context authorizer not to be wrapped")
+		}
+	})
+}
+
+func TestContextCancelsSystemRequests(t *testing.T) {
+	inputs := []struct {
+		name string
+		call func(ctx context.Context, systemURL string) error
+	}{
+		{
+			name: "Test pinned proxy config",
+			call: func(ctx context.Context, systemURL string) error {
+				authz := NewPinnedAuthorizer(mockAuthorizer{}, 1, http.DefaultClient)
+				_, err := authz.GetSystemConfigurationContext(ctx, systemURL, authorizer.SystemRequest{ServiceID: "123"})
+				return err
+			},
+		},
+		{
+			name: "Test product",
+			call: func(ctx context.Context, systemURL string) error {
+				authz := NewProductAuthorizer(mockAuthorizer{}, 0, 0, http.DefaultClient)
+				_, err := authz.GetProductContext(ctx, systemURL, authorizer.SystemRequest{ServiceID: "123"})
+				return err
+			},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			cancelled := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				close(cancelled)
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+			defer cancel()
+
+			if err := input.call(ctx, server.URL); err == nil {
+				t.Errorf("expected error when deadline is exceeded")
+			}
+
+			select {
+			case <-cancelled:
+			case <-time.After(time.Second):
+				t.Errorf("expected request to 3scale system to be cancelled")
+			}
+		})
+	}
+}
+
+func TestHandleAuthorizationContext(t *testing.T) {
+	source := NewStaticConfigSource()
+	if err := source.Load(map[string][]byte{"123.json": proxyConfigFile(EnvironmentProduction)}); err != nil {
+		t.Fatalf("unexpected error loading proxy configs - %v", err)
+	}
+
+	inputs := []struct {
+		name       string
+		ctx        func() (context.Context, context.CancelFunc)
+		cancel     bool
+		expectCode rpc.Code
+	}{
+		{
+			name: "Test cancelled request",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			cancel:     true,
+			expectCode: rpc.CANCELLED,
+		},
+		{
+			name: "Test request past its deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond*50)
+			},
+			expectCode: rpc.DEADLINE_EXCEEDED,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			authz := newBlockingAuthorizer()
+			defer close(authz.release)
+
+			s := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer:   authz,
+					ConfigSource: source,
+				},
+			}
+
+			params := config.Params{ServiceId: "123"}
+			b, _ := params.Marshal()
+
+			ctx, cancel := input.ctx()
+			defer cancel()
+
+			results := make(chan int32, 1)
+			go func() {
+				result, _ := s.HandleAuthorization(ctx, &authorization.HandleAuthorizationRequest{
+					Instance: &authorization.InstanceMsg{
+						Subject: &authorization.SubjectMsg{
+							User: "VALID",
+						},
+						Action: &authorization.ActionMsg{
+							Method: http.MethodGet,
+							Path:   "/",
+						},
+					},
+					AdapterConfig: &types.Any{Value: b},
+				})
+				results <- result.Status.Code
+			}()
+
+			<-authz.called
+			if input.cancel {
+				cancel()
+			}
+
+			select {
+			case code := <-results:
+				if code != int32(input.expectCode) {
+					t.Errorf("expected status code %v but got %v", input.expectCode, rpc.Code(code))
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected request to be released while 3scale backend is blocked")
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package threescale
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	policy "istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/pkg/status"
+)
+
+// Fields of the google.protobuf.Struct returned in the details of the status of a request denied by 3scale
+const (
+	DenialCodeField      = "code"
+	DenialMessageField   = "message"
+	DenialServiceIDField = "service_id"
+	DenialRequestIDField = "request_id"
+)
+
+const defaultDenialMessage = "the request was denied by 3scale"
+
+const (
+	// DenialDefaultKey of the denial_bodies handler param holds the body returned for error codes without a body of their own
+	DenialDefaultKey = "default"
+	// DefaultDenialContentType of the body returned when 3scale denies a request
+	DefaultDenialContentType = "application/json"
+
+	denialHeaderSeparator = ":"
+	contentTypeHeader     = "Content-Type"
+
+	// maxDenialTemplates bounds the parsed templates held, which are dropped once reached
+	maxDenialTemplates = 1024
+)
+
+// errorCodeMessages describe the error codes returned by 3scale backend when a request is denied
+var errorCodeMessages = map[string]string{
+	"user_key_invalid":        "the user key is not valid",
+	"application_not_found":   "no application matches the application ID or client ID",
+	"application_key_invalid": "the application key is missing or not valid",
+	"application_not_active":  "the application is not active",
+	"user.inactive_contract":  "the contract of the user is not active",
+	"limits_exceeded":         "the usage limits of the application have been exceeded",
+	"referrer_not_allowed":    "the referrer is not allowed",
+	"referrer_filter_invalid": "the referrer is not valid",
+	"oauth_not_enabled":       "OAuth is not enabled for the service",
+	"redirect_uri_invalid":    "the redirect URI is not valid",
+	"metric_invalid":          "a metric reported for the request does not exist",
+	"usage_value_invalid":     "a usage value reported for the request is not valid",
+	"service_token_invalid":   "the service token is not valid",
+	"service_id_invalid":      "the service ID is not valid",
+	// requests denied by the local access list are described with a code of their own
+	AccessListDeniedCode: "the credential is denied by the local access list",
+}
+
+// errorCodeStatus maps the error codes returned by 3scale backend which should not result in PermissionDenied
+var errorCodeStatus = map[string]rpc.Code{
+	// return equiv of 429
+	"limits_exceeded": rpc.RESOURCE_EXHAUSTED,
+	// unknown credentials return equiv of 401
+	"user_key_invalid":      rpc.UNAUTHENTICATED,
+	"application_not_found": rpc.UNAUTHENTICATED,
+	// return equiv of 403 for credentials denied locally, since the code is unknown to 3scale
+	AccessListDeniedCode: rpc.PERMISSION_DENIED,
+}
+
+// statusOverrides map 3scale error codes and HTTP status codes to the status returned in their place
+type statusOverrides map[string]rpc.Code
+
+// parseStatusOverrides parses the status_overrides handler param, whose values name a gRPC code
+func parseStatusOverrides(overrides map[string]string) (statusOverrides, error) {
+	parsed := make(statusOverrides, len(overrides))
+
+	var invalid []string
+	for key, value := range overrides {
+		code, ok := rpc.Code_value[strings.ToUpper(strings.TrimSpace(value))]
+		if key == "" || !ok {
+			invalid = append(invalid, fmt.Sprintf("%s=%s", key, value))
+			continue
+		}
+		parsed[key] = rpc.Code(code)
+	}
+
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return nil, fmt.Errorf("status_overrides must map 3scale error codes or HTTP status codes to gRPC codes, invalid entries: %s", strings.Join(invalid, ", "))
+	}
+	return parsed, nil
+}
+
+// lookup returns the status for key if it has been overridden
+func (o statusOverrides) lookup(key string) (func(string) rpc.Status, bool) {
+	code, ok := o[key]
+	if !ok {
+		return nil, false
+	}
+	return withCode(code), true
+}
+
+func withCode(code rpc.Code) func(string) rpc.Status {
+	return func(msg string) rpc.Status {
+		return status.WithMessage(code, msg)
+	}
+}
+
+// denialMessage describes a 3scale error code
+func denialMessage(errorCode string) string {
+	if message, ok := errorCodeMessages[errorCode]; ok {
+		return message
+	}
+	return defaultDenialMessage
+}
+
+// denialDetails describes a request denied by 3scale for the details of the returned status.
+// Empty fields are omitted.
+func denialDetails(errorCode, serviceID, requestID string) (*types.Any, error) {
+	fields := make(map[string]*types.Value)
+	for name, value := range map[string]string{
+		DenialCodeField:      errorCode,
+		DenialMessageField:   denialMessage(errorCode),
+		DenialServiceIDField: serviceID,
+		DenialRequestIDField: requestID,
+	} {
+		if value != "" {
+			fields[name] = &types.Value{Kind: &types.Value_StringValue{StringValue: value}}
+		}
+	}
+
+	return types.MarshalAny(&types.Struct{Fields: fields})
+}
+
+// denialTemplateData is passed to the templates of the denial_bodies and denial_headers handler params
+type denialTemplateData struct {
+	// Code is the 3scale error code
+	Code string
+	// Message describes the 3scale error code
+	Message string
+	// ServiceID is the 3scale service the request was authorized against
+	ServiceID string
+	// RequestID identifies the request, if provided by the instance
+	RequestID string
+	// Status is the HTTP status code of the response
+	Status int
+}
+
+// rpcToHTTPStatus maps the status returned for a denied request to the HTTP status code of the response
+var rpcToHTTPStatus = map[rpc.Code]int{
+	rpc.INVALID_ARGUMENT:    http.StatusBadRequest,
+	rpc.FAILED_PRECONDITION: http.StatusBadRequest,
+	rpc.OUT_OF_RANGE:        http.StatusBadRequest,
+	rpc.UNAUTHENTICATED:     http.StatusUnauthorized,
+	rpc.PERMISSION_DENIED:   http.StatusForbidden,
+	rpc.NOT_FOUND:           http.StatusNotFound,
+	rpc.ALREADY_EXISTS:      http.StatusConflict,
+	rpc.ABORTED:             http.StatusConflict,
+	rpc.RESOURCE_EXHAUSTED:  http.StatusTooManyRequests,
+	rpc.UNIMPLEMENTED:       http.StatusNotImplemented,
+	rpc.UNAVAILABLE:         http.StatusServiceUnavailable,
+	rpc.DEADLINE_EXCEEDED:   http.StatusGatewayTimeout,
+}
+
+type denialTemplateKey struct {
+	name string
+	text string
+}
+
+// denialTemplates holds parsed denial templates, since handler params are received with every request
+var denialTemplates = struct {
+	parsed map[denialTemplateKey]*template.Template
+	mutex  sync.RWMutex
+}{parsed: make(map[denialTemplateKey]*template.Template)}
+
+// parseDenialTemplate returns the parsed template, parsing it only if it has not been parsed before
+func parseDenialTemplate(name, text string) (*template.Template, error) {
+	key := denialTemplateKey{name: name, text: text}
+
+	denialTemplates.mutex.RLock()
+	tmpl, ok := denialTemplates.parsed[key]
+	denialTemplates.mutex.RUnlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	denialTemplates.mutex.Lock()
+	if len(denialTemplates.parsed) >= maxDenialTemplates {
+		denialTemplates.parsed = make(map[denialTemplateKey]*template.Template)
+	}
+	denialTemplates.parsed[key] = tmpl
+	denialTemplates.mutex.Unlock()
+	return tmpl, nil
+}
+
+// validateDenialTemplates ensures the templates of the denial_bodies and denial_headers handler params can be parsed
+func validateDenialTemplates(cfg *config.Params) error {
+	var invalid []string
+	for key, body := range cfg.DenialBodies {
+		if _, err := parseDenialTemplate(key, body); key == "" || err != nil {
+			invalid = append(invalid, fmt.Sprintf("denial_bodies[%s]", key))
+		}
+	}
+
+	for key, value := range cfg.DenialHeaders {
+		_, header := splitDenialHeaderKey(key)
+		if _, err := parseDenialTemplate(key, value); header == "" || err != nil {
+			invalid = append(invalid, fmt.Sprintf("denial_headers[%s]", key))
+		}
+	}
+
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("denial response templates must be valid, invalid entries: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// denialResponse renders the response returned to the client for a request denied by 3scale.
+// Returns nil if the handler does not customise the response.
+func denialResponse(cfg *config.Params, code rpc.Code, data denialTemplateData) (*types.Any, error) {
+	if len(cfg.DenialBodies) == 0 && len(cfg.DenialHeaders) == 0 {
+		return nil, nil
+	}
+
+	httpStatus, ok := rpcToHTTPStatus[code]
+	if !ok {
+		httpStatus = http.StatusInternalServerError
+	}
+	data.Status = httpStatus
+
+	headers := make(map[string]string)
+	// headers for the error code take precedence over those for all error codes
+	forCode := make(map[string]string)
+	for key, value := range cfg.DenialHeaders {
+		errorCode, header := splitDenialHeaderKey(key)
+		if errorCode != "" && errorCode != data.Code {
+			continue
+		}
+
+		rendered, err := renderDenialTemplate(key, value, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if errorCode != "" {
+			forCode[http.CanonicalHeaderKey(header)] = rendered
+		} else {
+			headers[http.CanonicalHeaderKey(header)] = rendered
+		}
+	}
+
+	for header, value := range forCode {
+		headers[header] = value
+	}
+
+	body, ok := cfg.DenialBodies[data.Code]
+	if !ok {
+		body = cfg.DenialBodies[DenialDefaultKey]
+	}
+
+	rendered, err := renderDenialTemplate(data.Code, body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := headers[contentTypeHeader]; !ok && rendered != "" {
+		headers[contentTypeHeader] = cfg.DenialContentType
+		if cfg.DenialContentType == "" {
+			headers[contentTypeHeader] = DefaultDenialContentType
+		}
+	}
+
+	return types.MarshalAny(&policy.DirectHttpResponse{
+		Code:    policy.HttpStatusCode(httpStatus),
+		Body:    rendered,
+		Headers: headers,
+	})
+}
+
+// splitDenialHeaderKey splits a key of the denial_headers handler param into an optional error code and the header name
+func splitDenialHeaderKey(key string) (string, string) {
+	i := strings.LastIndex(key, denialHeaderSeparator)
+	if i < 0 {
+		return "", strings.TrimSpace(key)
+	}
+	return strings.TrimSpace(key[:i]), strings.TrimSpace(key[i+1:])
+}
+
+func renderDenialTemplate(name, text string, data denialTemplateData) (string, error) {
+	tmpl, err := parseDenialTemplate(name, text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing denial template %s - %v", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering denial template %s - %v", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,441 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	policy "istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestParseStatusOverrides(t *testing.T) {
+	inputs := []struct {
+		name         string
+		overrides    map[string]string
+		expect       statusOverrides
+		expectErrMsg string
+	}{
+		{
+			name:   "Test nil overrides",
+			expect: statusOverrides{},
+		},
+		{
+			name: "Test codes are parsed case insensitively",
+			overrides: map[string]string{
+				"application_not_active": "failed_precondition",
+				"503":                    " UNAVAILABLE ",
+			},
+			expect: statusOverrides{
+				"application_not_active": rpc.FAILED_PRECONDITION,
+				"503":                    rpc.UNAVAILABLE,
+			},
+		},
+		{
+			name: "Test invalid entries are reported",
+			overrides: map[string]string{
+				"limits_exceeded": "TOO_MANY",
+				"":                "UNAVAILABLE",
+				"409":             "ABORTED",
+			},
+			expectErrMsg: "invalid entries: =UNAVAILABLE, limits_exceeded=TOO_MANY",
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			got, err := parseStatusOverrides(input.overrides)
+			if input.expectErrMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), input.expectErrMsg) {
+					t.Errorf("expected error containing %q but got %v", input.expectErrMsg, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if len(got) != len(input.expect) {
+				t.Fatalf("expected %v but got %v", input.expect, got)
+			}
+
+			for key, code := range input.expect {
+				if got[key] != code {
+					t.Errorf("expected %s to map to %s but got %s", key, code, got[key])
+				}
+			}
+		})
+	}
+}
+
+func TestErrorCodeToRpcStatus(t *testing.T) {
+	inputs := []struct {
+		name      string
+		errorCode string
+		overrides statusOverrides
+		expect    rpc.Code
+	}{
+		{
+			name:      "Test limits exceeded",
+			errorCode: "limits_exceeded",
+			expect:    rpc.RESOURCE_EXHAUSTED,
+		},
+		{
+			name:      "Test unknown user key",
+			errorCode: "user_key_invalid",
+			expect:    rpc.UNAUTHENTICATED,
+		},
+		{
+			name:      "Test unknown application",
+			errorCode: "application_not_found",
+			expect:    rpc.UNAUTHENTICATED,
+		},
+		{
+			name:      "Test invalid application key",
+			errorCode: "application_key_invalid",
+			expect:    rpc.PERMISSION_DENIED,
+		},
+		{
+			name:      "Test unmapped error code",
+			errorCode: "not_a_3scale_error",
+			expect:    rpc.UNKNOWN,
+		},
+		{
+			name:      "Test override takes precedence",
+			errorCode: "limits_exceeded",
+			overrides: statusOverrides{"limits_exceeded": rpc.UNAVAILABLE},
+			expect:    rpc.UNAVAILABLE,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			got := errorCodeToRpcStatus(input.errorCode, input.overrides)(input.errorCode)
+			if got.Code != int32(input.expect) {
+				t.Errorf("expected %s but got %s", input.expect, rpc.Code(got.Code))
+			}
+
+			if got.Message != input.errorCode {
+				t.Errorf("expected message %s but got %s", input.errorCode, got.Message)
+			}
+		})
+	}
+}
+
+func TestBackendResponseToRpcStatusOverrides(t *testing.T) {
+	resp := &authorizer.BackendResponse{
+		RawResponse: &http.Response{StatusCode: http.StatusServiceUnavailable},
+	}
+
+	if got := backendResponseToRpcStatus(resp, nil)(""); got.Code != int32(rpc.UNAVAILABLE) {
+		t.Errorf("expected %s but got %s", rpc.UNAVAILABLE, rpc.Code(got.Code))
+	}
+
+	overrides := statusOverrides{"503": rpc.PERMISSION_DENIED}
+	if got := backendResponseToRpcStatus(resp, overrides)(""); got.Code != int32(rpc.PERMISSION_DENIED) {
+		t.Errorf("expected %s but got %s", rpc.PERMISSION_DENIED, rpc.Code(got.Code))
+	}
+}
+
+func TestDenialDetails(t *testing.T) {
+	inputs := []struct {
+		name      string
+		errorCode string
+		serviceID string
+		requestID string
+		expect    map[string]string
+	}{
+		{
+			name:      "Test known error code",
+			errorCode: "limits_exceeded",
+			serviceID: "123",
+			requestID: "abc",
+			expect: map[string]string{
+				DenialCodeField:      "limits_exceeded",
+				DenialMessageField:   errorCodeMessages["limits_exceeded"],
+				DenialServiceIDField: "123",
+				DenialRequestIDField: "abc",
+			},
+		},
+		{
+			name:      "Test unknown error code and empty fields omitted",
+			errorCode: "not_a_3scale_error",
+			serviceID: "123",
+			expect: map[string]string{
+				DenialCodeField:      "not_a_3scale_error",
+				DenialMessageField:   defaultDenialMessage,
+				DenialServiceIDField: "123",
+			},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			details, err := denialDetails(input.errorCode, input.serviceID, input.requestID)
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+			assertDenialDetails(t, details, input.expect)
+		})
+	}
+}
+
+func TestHandleAuthorizationDenialDetails(t *testing.T) {
+	params := config.Params{
+		ServiceId:   "123",
+		SystemUrl:   "https://www.fake-system.3scale.net",
+		AccessToken: "happy-path",
+		StatusOverrides: map[string]string{
+			"application_not_active": "FAILED_PRECONDITION",
+		},
+	}
+	b, _ := params.Marshal()
+
+	s := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						BackendVersion: apiKeyTypeIdentifier,
+						Proxy: client.ContentProxy{
+							ProxyRules: []client.ProxyRule{
+								{
+									HTTPMethod:       http.MethodGet,
+									Pattern:          "/",
+									MetricSystemName: "hits",
+									Delta:            1,
+								},
+							},
+						},
+					},
+				},
+				withAuthResponse: &authorizer.BackendResponse{
+					Authorized: false,
+					ErrorCode:  "application_not_active",
+				},
+			},
+		},
+	}
+
+	result, _ := s.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Subject: &authorization.SubjectMsg{
+				User: "secret",
+				Properties: map[string]*policy.Value{
+					RequestIDAttributeKey: stringValue("request-1"),
+				},
+			},
+			Action: &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/",
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	})
+
+	if result.Status.Code != int32(rpc.FAILED_PRECONDITION) {
+		t.Errorf("expected %s but got %s", rpc.FAILED_PRECONDITION, rpc.Code(result.Status.Code))
+	}
+
+	if result.Status.Message != "application_not_active" {
+		t.Errorf("expected error code as message but got %s", result.Status.Message)
+	}
+
+	if len(result.Status.Details) != 1 {
+		t.Fatalf("expected a single detail but got %v", result.Status.Details)
+	}
+
+	assertDenialDetails(t, result.Status.Details[0], map[string]string{
+		DenialCodeField:      "application_not_active",
+		DenialMessageField:   errorCodeMessages["application_not_active"],
+		DenialServiceIDField: "123",
+		DenialRequestIDField: "request-1",
+	})
+}
+
+func assertDenialDetails(t *testing.T, details *types.Any, expect map[string]string) {
+	t.Helper()
+
+	var got types.Struct
+	if err := types.UnmarshalAny(details, &got); err != nil {
+		t.Fatalf("error unmarshaling details - %v", err)
+	}
+
+	if len(got.Fields) != len(expect) {
+		t.Errorf("expected fields %v but got %v", expect, got.Fields)
+	}
+
+	for field, value := range expect {
+		if got.Fields[field].GetStringValue() != value {
+			t.Errorf("expected %s to be %q but got %q", field, value, got.Fields[field].GetStringValue())
+		}
+	}
+}
+
+func TestValidateDenialTemplates(t *testing.T) {
+	inputs := []struct {
+		name         string
+		params       config.Params
+		expectErrMsg string
+	}{
+		{
+			name: "Test valid templates",
+			params: config.Params{
+				DenialBodies:  map[string]string{DenialDefaultKey: `{"error":"{{.Code}}"}`},
+				DenialHeaders: map[string]string{"limits_exceeded:Retry-After": "30", "X-Request-Id": "{{.RequestID}}"},
+			},
+		},
+		{
+			name: "Test invalid templates are reported",
+			params: config.Params{
+				DenialBodies:  map[string]string{"limits_exceeded": `{"error":"{{.Code}"}`},
+				DenialHeaders: map[string]string{"limits_exceeded:": "30"},
+			},
+			expectErrMsg: "invalid entries: denial_bodies[limits_exceeded], denial_headers[limits_exceeded:]",
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			err := validateDenialTemplates(&input.params)
+			if input.expectErrMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error - %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), input.expectErrMsg) {
+				t.Errorf("expected error containing %q but got %v", input.expectErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestDenialResponse(t *testing.T) {
+	params := &config.Params{
+		DenialBodies: map[string]string{
+			"limits_exceeded": `{"error":"rate_limited","retry_after":30}`,
+			DenialDefaultKey:  `{"error":"{{.Code}}","message":"{{.Message}}","status":{{.Status}}}`,
+		},
+		DenialHeaders: map[string]string{
+			"X-Request-Id":                "{{.RequestID}}",
+			"retry-after":                 "60",
+			"limits_exceeded:Retry-After": "30",
+		},
+	}
+
+	inputs := []struct {
+		name   string
+		params *config.Params
+		code   rpc.Code
+		data   denialTemplateData
+		expect *policy.DirectHttpResponse
+	}{
+		{
+			name:   "Test no response when not configured",
+			params: &config.Params{},
+			code:   rpc.PERMISSION_DENIED,
+			data:   denialTemplateData{Code: "application_key_invalid"},
+		},
+		{
+			name:   "Test body and headers for error code",
+			params: params,
+			code:   rpc.RESOURCE_EXHAUSTED,
+			data:   denialTemplateData{Code: "limits_exceeded", RequestID: "abc"},
+			expect: &policy.DirectHttpResponse{
+				Code: policy.HttpStatusCode(http.StatusTooManyRequests),
+				Body: `{"error":"rate_limited","retry_after":30}`,
+				Headers: map[string]string{
+					"Content-Type": DefaultDenialContentType,
+					"Retry-After":  "30",
+					"X-Request-Id": "abc",
+				},
+			},
+		},
+		{
+			name:   "Test default body",
+			params: params,
+			code:   rpc.PERMISSION_DENIED,
+			data:   denialTemplateData{Code: "application_key_invalid", Message: "invalid key"},
+			expect: &policy.DirectHttpResponse{
+				Code: policy.HttpStatusCode(http.StatusForbidden),
+				Body: `{"error":"application_key_invalid","message":"invalid key","status":403}`,
+				Headers: map[string]string{
+					"Content-Type": DefaultDenialContentType,
+					"Retry-After":  "60",
+					"X-Request-Id": "",
+				},
+			},
+		},
+		{
+			name: "Test content type",
+			params: &config.Params{
+				DenialBodies:      map[string]string{DenialDefaultKey: "denied"},
+				DenialContentType: "text/plain",
+			},
+			code: rpc.UNAUTHENTICATED,
+			data: denialTemplateData{Code: "user_key_invalid"},
+			expect: &policy.DirectHttpResponse{
+				Code:    policy.HttpStatusCode(http.StatusUnauthorized),
+				Body:    "denied",
+				Headers: map[string]string{"Content-Type": "text/plain"},
+			},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			got, err := denialResponse(input.params, input.code, input.data)
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if input.expect == nil {
+				if got != nil {
+					t.Errorf("expected no response but got %v", got)
+				}
+				return
+			}
+
+			var response policy.DirectHttpResponse
+			if err := types.UnmarshalAny(got, &response); err != nil {
+				t.Fatalf("error unmarshaling response - %v", err)
+			}
+
+			if !reflect.DeepEqual(&response, input.expect) {
+				t.Errorf("expected %v but got %v", input.expect, &response)
+			}
+		})
+	}
+}
+
+func TestParseDenialTemplate(t *testing.T) {
+	first, err := parseDenialTemplate("limits_exceeded", `{"code": "{{.Code}}"}`)
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	second, _ := parseDenialTemplate("limits_exceeded", `{"code": "{{.Code}}"}`)
+	if first != second {
+		t.Errorf("expected template to be parsed once")
+	}
+
+	changed, _ := parseDenialTemplate("limits_exceeded", `{"error": "{{.Code}}"}`)
+	if changed == first {
+		t.Errorf("expected changed template to be parsed again")
+	}
+
+	if _, err := parseDenialTemplate("invalid", "{{.Code"); err == nil {
+		t.Errorf("expected error parsing invalid template")
+	}
+}
@@ -0,0 +1,437 @@
+package fake
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	authRepPath        = "/transactions/authrep.xml"
+	oauthAuthRepPath   = "/transactions/oauth_authrep.xml"
+	authorizePath      = "/transactions/authorize.xml"
+	oauthAuthorizePath = "/transactions/oauth_authorize.xml"
+	reportPath         = "/transactions.xml"
+
+	extensionsHeader      = "3scale-options"
+	rejectionReasonHeader = "3scale-rejection-reason"
+	limitRemainingHeader  = "3scale-limit-remaining"
+	limitResetHeader      = "3scale-limit-reset"
+
+	timeFormat = "2006-01-02 15:04:05 -0700"
+)
+
+// Error codes returned by the backend API
+const (
+	ServiceTokenInvalid   = "service_token_invalid"
+	UserKeyInvalid        = "user_key_invalid"
+	ApplicationNotFound   = "application_not_found"
+	ApplicationKeyInvalid = "application_key_invalid"
+	MetricInvalid         = "metric_invalid"
+	UsageValueInvalid     = "usage_value_invalid"
+	LimitsExceeded        = "limits_exceeded"
+)
+
+var (
+	usageParam       = regexp.MustCompile(`^usage\[([^\]]+)\]$`)
+	reportAppParam   = regexp.MustCompile(`^transactions\[(\d+)\]\[(user_key|app_id)\]$`)
+	reportUsageParam = regexp.MustCompile(`^transactions\[(\d+)\]\[usage\]\[([^\]]+)\]$`)
+)
+
+type statusXML struct {
+	XMLName      xml.Name         `xml:"status"`
+	Authorized   bool             `xml:"authorized"`
+	Reason       string           `xml:"reason,omitempty"`
+	Plan         string           `xml:"plan"`
+	UsageReports *usageReportsXML `xml:"usage_reports,omitempty"`
+	Hierarchy    *hierarchyXML    `xml:"hierarchy,omitempty"`
+}
+
+type usageReportsXML struct {
+	Reports []usageReportXML `xml:"usage_report"`
+}
+
+type usageReportXML struct {
+	Metric       string `xml:"metric,attr"`
+	Period       string `xml:"period,attr"`
+	Exceeded     bool   `xml:"exceeded,attr,omitempty"`
+	PeriodStart  string `xml:"period_start,omitempty"`
+	PeriodEnd    string `xml:"period_end,omitempty"`
+	MaxValue     int64  `xml:"max_value"`
+	CurrentValue int64  `xml:"current_value"`
+}
+
+type hierarchyXML struct {
+	Metrics []hierarchyMetricXML `xml:"metric"`
+}
+
+type hierarchyMetricXML struct {
+	Name     string `xml:"name,attr"`
+	Children string `xml:"children,attr"`
+}
+
+type errorXML struct {
+	XMLName xml.Name `xml:"error"`
+	Code    string   `xml:"code,attr"`
+	Message string   `xml:",chardata"`
+}
+
+// backendError is a failure to process a transaction, returned to the client as an error document
+type backendError struct {
+	statusCode int
+	code       string
+	message    string
+}
+
+func (ts *ThreeScale) serveBackend(w http.ResponseWriter, r *http.Request) {
+	var endpoint string
+	switch r.URL.Path {
+	case authRepPath:
+		endpoint = AuthRepEndpoint
+	case oauthAuthRepPath:
+		endpoint = OauthAuthRepEndpoint
+	case authorizePath:
+		endpoint = AuthorizeEndpoint
+	case oauthAuthorizePath:
+		endpoint = OauthAuthorizeEndpoint
+	case reportPath:
+		endpoint = ReportEndpoint
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	ts.recordCall(endpoint)
+
+	ts.mutex.RLock()
+	down := ts.backendDown
+	ts.mutex.RUnlock()
+
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if endpoint == ReportEndpoint {
+		ts.serveReport(w, r)
+		return
+	}
+
+	report := endpoint == AuthRepEndpoint || endpoint == OauthAuthRepEndpoint
+	oauth := endpoint == OauthAuthRepEndpoint || endpoint == OauthAuthorizeEndpoint
+	ts.serveAuth(w, r, report, oauth)
+}
+
+// serveAuth authorizes the application identified by the request against its limits, reporting usage if report is true
+func (ts *ThreeScale) serveAuth(w http.ResponseWriter, r *http.Request, report bool, oauth bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeBackendError(w, backendError{http.StatusBadRequest, "bad_request", err.Error()})
+		return
+	}
+	extensions, _ := url.ParseQuery(r.Header.Get(extensionsHeader))
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	svc, bErr := ts.serviceFor(r.Form)
+	if bErr != nil {
+		writeBackendError(w, *bErr)
+		return
+	}
+
+	app, bErr := ts.applicationFor(svc, r.Form.Get("user_key"), r.Form.Get("app_id"))
+	if bErr != nil {
+		writeBackendError(w, *bErr)
+		return
+	}
+
+	usage := make(map[string]int64)
+	for key, values := range r.Form {
+		matches := usageParam.FindStringSubmatch(key)
+		if matches == nil {
+			continue
+		}
+		value, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil {
+			writeBackendError(w, backendError{http.StatusBadRequest, UsageValueInvalid,
+				fmt.Sprintf("usage value %q for metric %q is invalid", values[0], matches[1])})
+			return
+		}
+		usage[matches[1]] = value
+	}
+
+	usage, bErr = expandUsage(svc, usage)
+	if bErr != nil {
+		writeBackendError(w, *bErr)
+		return
+	}
+
+	status := statusXML{Authorized: true, Plan: app.Plan}
+
+	if !oauth && len(app.Keys) > 0 {
+		appKey := r.Form.Get("app_key")
+		if appKey == "" {
+			status.Authorized, status.Reason = false, "application key is missing"
+		} else if !contains(app.Keys, appKey) {
+			status.Authorized, status.Reason = false, fmt.Sprintf("application key %q is invalid", appKey)
+		}
+		if !status.Authorized {
+			w.Header().Set(rejectionReasonHeader, ApplicationKeyInvalid)
+		}
+	}
+
+	if status.Authorized && ts.exceedsLimits(svc, app, usage) {
+		status.Authorized, status.Reason = false, "usage limits are exceeded"
+		w.Header().Set(rejectionReasonHeader, LimitsExceeded)
+	}
+
+	if status.Authorized && report {
+		for metric, value := range usage {
+			for _, period := range periodsFor(app, metric) {
+				ts.usage[ts.usageKeyFor(svc, app, metric, period)] += value
+			}
+		}
+	}
+
+	status.UsageReports = ts.usageReports(svc, app, usage)
+	if extensions.Get("hierarchy") == "1" {
+		status.Hierarchy = hierarchyFor(svc)
+	}
+	if extensions.Get("limit_headers") == "1" {
+		ts.setLimitHeaders(w, svc, app)
+	}
+
+	statusCode := http.StatusOK
+	if !status.Authorized {
+		statusCode = http.StatusConflict
+	}
+	writeXML(w, statusCode, status)
+}
+
+// serveReport counts the usage of each transaction without applying limits. Transactions for unknown
+// applications or metrics are dropped, matching the asynchronous behaviour of 3scale.
+func (ts *ThreeScale) serveReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeBackendError(w, backendError{http.StatusBadRequest, "bad_request", err.Error()})
+		return
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	svc, bErr := ts.serviceFor(r.Form)
+	if bErr != nil {
+		writeBackendError(w, *bErr)
+		return
+	}
+
+	applications := make(map[string]string)
+	usages := make(map[string]map[string]int64)
+	for key, values := range r.Form {
+		if matches := reportAppParam.FindStringSubmatch(key); matches != nil {
+			applications[matches[1]] = values[0]
+			continue
+		}
+		if matches := reportUsageParam.FindStringSubmatch(key); matches != nil {
+			value, err := strconv.ParseInt(values[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			if usages[matches[1]] == nil {
+				usages[matches[1]] = make(map[string]int64)
+			}
+			usages[matches[1]][matches[2]] = value
+		}
+	}
+
+	for index, identifier := range applications {
+		app, ok := findApplication(svc, identifier)
+		if !ok {
+			continue
+		}
+		usage, bErr := expandUsage(svc, usages[index])
+		if bErr != nil {
+			continue
+		}
+		for metric, value := range usage {
+			for _, period := range periodsFor(app, metric) {
+				ts.usage[ts.usageKeyFor(svc, app, metric, period)] += value
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// serviceFor returns the service identified by the request if the service token is valid. Must be called with the lock held.
+func (ts *ThreeScale) serviceFor(form url.Values) (Service, *backendError) {
+	token := form.Get("service_token")
+	svc, ok := ts.services[form.Get("service_id")]
+	if !ok || token == "" || svc.Token != token {
+		return Service{}, &backendError{http.StatusForbidden, ServiceTokenInvalid,
+			fmt.Sprintf("service token %q or service id %q is invalid", token, form.Get("service_id"))}
+	}
+	return svc, nil
+}
+
+// applicationFor returns the application identified by the user key or, if not provided, the application ID
+func (ts *ThreeScale) applicationFor(svc Service, userKey, appID string) (Application, *backendError) {
+	if userKey != "" || appID == "" {
+		for _, app := range svc.Applications {
+			if userKey != "" && app.UserKey == userKey {
+				return app, nil
+			}
+		}
+		return Application{}, &backendError{http.StatusForbidden, UserKeyInvalid,
+			fmt.Sprintf("user key %q is invalid", userKey)}
+	}
+
+	for _, app := range svc.Applications {
+		if app.ID == appID {
+			return app, nil
+		}
+	}
+	return Application{}, &backendError{http.StatusNotFound, ApplicationNotFound,
+		fmt.Sprintf("application with id=%q was not found", appID)}
+}
+
+// expandUsage validates the reported metrics and adds the usage of each metric to its parents
+func expandUsage(svc Service, usage map[string]int64) (map[string]int64, *backendError) {
+	parents := make(map[string]string)
+	for _, metric := range svc.Metrics {
+		parents[metric.SystemName] = metric.Parent
+	}
+
+	expanded := make(map[string]int64)
+	for metric, value := range usage {
+		if _, ok := parents[metric]; !ok {
+			return nil, &backendError{http.StatusNotFound, MetricInvalid, fmt.Sprintf("metric %q is invalid", metric)}
+		}
+		for m := metric; m != ""; m = parents[m] {
+			expanded[m] += value
+		}
+	}
+	return expanded, nil
+}
+
+// exceedsLimits returns true if adding usage to the current usage of the application exceeds any limit.
+// Must be called with the lock held.
+func (ts *ThreeScale) exceedsLimits(svc Service, app Application, usage map[string]int64) bool {
+	for _, limit := range app.Limits {
+		value, ok := usage[limit.Metric]
+		if !ok {
+			continue
+		}
+		if ts.usage[ts.usageKeyFor(svc, app, limit.Metric, limit.Period)]+value > limit.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// usageReports for each limit of the application. Must be called with the lock held.
+func (ts *ThreeScale) usageReports(svc Service, app Application, usage map[string]int64) *usageReportsXML {
+	if len(app.Limits) == 0 {
+		return nil
+	}
+
+	reports := &usageReportsXML{}
+	now := ts.now()
+	for _, limit := range app.Limits {
+		current := ts.usage[ts.usageKeyFor(svc, app, limit.Metric, limit.Period)]
+		report := usageReportXML{
+			Metric:       limit.Metric,
+			Period:       limit.Period,
+			Exceeded:     current+usage[limit.Metric] > limit.Value,
+			MaxValue:     limit.Value,
+			CurrentValue: current,
+		}
+		if limit.Period != Eternity {
+			start, end := periodBounds(now, limit.Period)
+			report.PeriodStart, report.PeriodEnd = start.Format(timeFormat), end.Format(timeFormat)
+		}
+		reports.Reports = append(reports.Reports, report)
+	}
+	return reports
+}
+
+// setLimitHeaders sets the remaining usage and seconds until reset of the most constrained limit.
+// Must be called with the lock held.
+func (ts *ThreeScale) setLimitHeaders(w http.ResponseWriter, svc Service, app Application) {
+	remaining, reset := int64(-1), int64(-1)
+	now := ts.now()
+	for _, limit := range app.Limits {
+		left := limit.Value - ts.usage[ts.usageKeyFor(svc, app, limit.Metric, limit.Period)]
+		if left < 0 {
+			left = 0
+		}
+		if remaining == -1 || left < remaining {
+			remaining = left
+			reset = -1
+			if limit.Period != Eternity {
+				_, end := periodBounds(now, limit.Period)
+				reset = int64(end.Sub(now) / time.Second)
+			}
+		}
+	}
+	w.Header().Set(limitRemainingHeader, strconv.FormatInt(remaining, 10))
+	w.Header().Set(limitResetHeader, strconv.FormatInt(reset, 10))
+}
+
+func hierarchyFor(svc Service) *hierarchyXML {
+	children := make(map[string][]string)
+	for _, metric := range svc.Metrics {
+		if metric.Parent != "" {
+			children[metric.Parent] = append(children[metric.Parent], metric.SystemName)
+		}
+	}
+
+	hierarchy := &hierarchyXML{}
+	for parent, c := range children {
+		sort.Strings(c)
+		hierarchy.Metrics = append(hierarchy.Metrics, hierarchyMetricXML{Name: parent, Children: strings.Join(c, " ")})
+	}
+	sort.Slice(hierarchy.Metrics, func(i, j int) bool {
+		return hierarchy.Metrics[i].Name < hierarchy.Metrics[j].Name
+	})
+	return hierarchy
+}
+
+// periodsFor returns the periods the application has limits on for the metric, always including eternity
+// so usage is tracked for metrics without limits
+func periodsFor(app Application, metric string) []string {
+	periods := []string{Eternity}
+	for _, limit := range app.Limits {
+		if limit.Metric == metric && limit.Period != Eternity {
+			periods = append(periods, limit.Period)
+		}
+	}
+	return periods
+}
+
+func writeBackendError(w http.ResponseWriter, err backendError) {
+	writeXML(w, err.statusCode, errorXML{Code: err.code, Message: err.message})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
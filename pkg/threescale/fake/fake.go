@@ -0,0 +1,360 @@
+// Package fake provides an in memory implementation of the subset of the 3scale system and backend APIs
+// used by the adapter. It allows tests and demos to run against the real authorizer without network access.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend versions which determine the authentication pattern of a service
+const (
+	BackendVersionAPIKey = "1"
+	BackendVersionAppID  = "2"
+	BackendVersionOIDC   = "oauth"
+)
+
+// Periods supported by limits
+const (
+	Minute   = "minute"
+	Hour     = "hour"
+	Day      = "day"
+	Week     = "week"
+	Month    = "month"
+	Year     = "year"
+	Eternity = "eternity"
+)
+
+// Endpoints which are served and whose calls are counted
+const (
+	ProxyConfigEndpoint    = "proxy_config"
+	ServicesEndpoint       = "services"
+	AuthRepEndpoint        = "authrep"
+	OauthAuthRepEndpoint   = "oauth_authrep"
+	AuthorizeEndpoint      = "authorize"
+	OauthAuthorizeEndpoint = "oauth_authorize"
+	ReportEndpoint         = "report"
+)
+
+const defaultMetric = "hits"
+
+// Config describes the 3scale account served by a ThreeScale. It can be loaded from JSON.
+type Config struct {
+	// AccessToken which must be provided to call the system API
+	AccessToken string    `json:"access_token"`
+	Services    []Service `json:"services"`
+}
+
+// Service is a 3scale service (API)
+type Service struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Token is the service token which must be provided to call the backend API for this service
+	Token string `json:"token"`
+	// BackendVersion is one of "1" (API key), "2" (application ID) or "oauth" (OpenID Connect). Defaults to "1".
+	BackendVersion string `json:"backend_version,omitempty"`
+	// BackendEndpoint is returned in the proxy config as the backend URL. Defaults to the URL the fake is served on.
+	BackendEndpoint string `json:"backend_endpoint,omitempty"`
+	// Hosts are returned in the proxy config as the hosts the service is served on
+	Hosts        []string      `json:"hosts,omitempty"`
+	Metrics      []Metric      `json:"metrics,omitempty"`
+	MappingRules []MappingRule `json:"mapping_rules,omitempty"`
+	// Backends make the service a product which routes requests to each backend under its path
+	Backends     []Backend     `json:"backends,omitempty"`
+	Applications []Application `json:"applications,omitempty"`
+}
+
+// Backend of a product. Its mapping rules are returned in the proxy config of the product after those of the product.
+type Backend struct {
+	ID           int64         `json:"id"`
+	SystemName   string        `json:"system_name"`
+	Path         string        `json:"path"`
+	MappingRules []MappingRule `json:"mapping_rules,omitempty"`
+}
+
+// Metric of a service. Usage reported against a metric which has a parent is also counted against the parent.
+// Only the "hits" metric is defined if a service does not define any metrics.
+type Metric struct {
+	SystemName string `json:"system_name"`
+	Parent     string `json:"parent,omitempty"`
+}
+
+// MappingRule maps a request to a metric. Rules are positioned in the order they are defined.
+type MappingRule struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+	Metric  string `json:"metric"`
+	Delta   int    `json:"delta"`
+	Last    bool   `json:"last,omitempty"`
+}
+
+// Application is a client of a service. Depending on the backend version of the service it is identified by
+// UserKey, or by ID and optionally one of Keys. For OpenID Connect the ID is the client ID.
+type Application struct {
+	ID      string   `json:"id,omitempty"`
+	Keys    []string `json:"keys,omitempty"`
+	UserKey string   `json:"user_key,omitempty"`
+	Plan    string   `json:"plan,omitempty"`
+	Limits  []Limit  `json:"limits,omitempty"`
+}
+
+// Limit the usage of a metric by an application within a period. A limit with a value of zero disables the metric.
+type Limit struct {
+	Metric string `json:"metric"`
+	Period string `json:"period"`
+	Value  int64  `json:"value"`
+}
+
+// ThreeScale serves the system and backend APIs of a single 3scale account from one http.Handler.
+// It is safe for concurrent use.
+type ThreeScale struct {
+	accessToken string
+	services    map[string]Service
+	// usage keyed by service, application, metric, period and start of period
+	usage        map[usageKey]int64
+	calls        map[string]int
+	systemDown   bool
+	backendDown  bool
+	latency      time.Duration
+	now          func() time.Time
+	mutex        sync.RWMutex
+	serviceOrder []string
+}
+
+type usageKey struct {
+	service     string
+	application string
+	metric      string
+	period      string
+	start       time.Time
+}
+
+// New returns a ThreeScale serving the provided services, accepting accessToken for calls to the system API
+func New(accessToken string, services ...Service) *ThreeScale {
+	ts := &ThreeScale{
+		accessToken: accessToken,
+		services:    make(map[string]Service),
+		usage:       make(map[usageKey]int64),
+		calls:       make(map[string]int),
+		now:         time.Now,
+	}
+
+	for _, svc := range services {
+		ts.SetService(svc)
+	}
+	return ts
+}
+
+// NewFromConfig returns a ThreeScale serving the account described by conf
+func NewFromConfig(conf Config) *ThreeScale {
+	return New(conf.AccessToken, conf.Services...)
+}
+
+// LoadConfig reads an account description in JSON format
+func LoadConfig(r io.Reader) (Config, error) {
+	conf := Config{}
+	if err := json.NewDecoder(r).Decode(&conf); err != nil {
+		return conf, fmt.Errorf("error decoding config - %v", err)
+	}
+
+	for _, svc := range conf.Services {
+		if svc.ID == "" {
+			return conf, fmt.Errorf("service id must be provided")
+		}
+		for _, app := range svc.Applications {
+			for _, limit := range app.Limits {
+				if !validPeriod(limit.Period) {
+					return conf, fmt.Errorf("invalid period %q for limit on metric %s of service %s", limit.Period, limit.Metric, svc.ID)
+				}
+			}
+		}
+	}
+	return conf, nil
+}
+
+// NewServer starts and returns a server serving ts. The caller should call Close when finished.
+func (ts *ThreeScale) NewServer() *httptest.Server {
+	return httptest.NewServer(ts)
+}
+
+// NewTLSServer starts and returns a server serving ts over TLS. The caller should call Close when finished.
+func (ts *ThreeScale) NewTLSServer() *httptest.Server {
+	return httptest.NewTLSServer(ts)
+}
+
+// SetService adds or replaces a service
+func (ts *ThreeScale) SetService(svc Service) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if svc.BackendVersion == "" {
+		svc.BackendVersion = BackendVersionAPIKey
+	}
+	if len(svc.Metrics) == 0 {
+		svc.Metrics = []Metric{{SystemName: defaultMetric}}
+	}
+
+	if _, ok := ts.services[svc.ID]; !ok {
+		ts.serviceOrder = append(ts.serviceOrder, svc.ID)
+	}
+	ts.services[svc.ID] = svc
+}
+
+// SetSystemDown causes the system API to respond with 503 Service Unavailable while down is true
+func (ts *ThreeScale) SetSystemDown(down bool) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.systemDown = down
+}
+
+// SetBackendDown causes the backend API to respond with 503 Service Unavailable while down is true
+func (ts *ThreeScale) SetBackendDown(down bool) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.backendDown = down
+}
+
+// SetLatency delays every response by d
+func (ts *ThreeScale) SetLatency(d time.Duration) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.latency = d
+}
+
+// SetClock replaces the function used to determine the current time when counting usage against limits
+func (ts *ThreeScale) SetClock(now func() time.Time) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.now = now
+}
+
+// Usage returns the usage of a metric by an application within the current period. The application is
+// identified by its ID or user key. Usage is tracked for eternity and for each period the application has a limit on.
+func (ts *ThreeScale) Usage(serviceID, application, metric, period string) int64 {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+
+	svc, ok := ts.services[serviceID]
+	if !ok {
+		return 0
+	}
+
+	app, ok := findApplication(svc, application)
+	if !ok {
+		return 0
+	}
+
+	return ts.usage[ts.usageKeyFor(svc, app, metric, period)]
+}
+
+// Calls returns the number of requests received by the provided endpoint
+func (ts *ThreeScale) Calls(endpoint string) int {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+	return ts.calls[endpoint]
+}
+
+// Reset clears all usage and call counters
+func (ts *ThreeScale) Reset() {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.usage = make(map[usageKey]int64)
+	ts.calls = make(map[string]int)
+}
+
+// ServeHTTP implements http.Handler
+func (ts *ThreeScale) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ts.mutex.RLock()
+	latency := ts.latency
+	ts.mutex.RUnlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/admin/api/"):
+		ts.serveSystem(w, r)
+	case r.URL.Path == "/transactions.xml" || strings.HasPrefix(r.URL.Path, "/transactions/"):
+		ts.serveBackend(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (ts *ThreeScale) recordCall(endpoint string) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ts.calls[endpoint]++
+}
+
+// findApplication by ID or user key
+func findApplication(svc Service, identifier string) (Application, bool) {
+	for _, app := range svc.Applications {
+		if identifier != "" && (app.ID == identifier || app.UserKey == identifier) {
+			return app, true
+		}
+	}
+	return Application{}, false
+}
+
+func applicationIdentifier(app Application) string {
+	if app.UserKey != "" {
+		return app.UserKey
+	}
+	return app.ID
+}
+
+func (ts *ThreeScale) usageKeyFor(svc Service, app Application, metric, period string) usageKey {
+	start, _ := periodBounds(ts.now(), period)
+	return usageKey{
+		service:     svc.ID,
+		application: applicationIdentifier(app),
+		metric:      metric,
+		period:      period,
+		start:       start,
+	}
+}
+
+// periodBounds returns the start and end of the period containing t
+func periodBounds(t time.Time, period string) (time.Time, time.Time) {
+	t = t.UTC()
+	switch period {
+	case Minute:
+		start := t.Truncate(time.Minute)
+		return start, start.Add(time.Minute)
+	case Hour:
+		start := t.Truncate(time.Hour)
+		return start, start.Add(time.Hour)
+	case Day:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	case Week:
+		// weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		start := time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 7)
+	case Month:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	case Year:
+		start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	default:
+		return time.Time{}, time.Time{}
+	}
+}
+
+func validPeriod(period string) bool {
+	switch period {
+	case Minute, Hour, Day, Week, Month, Year, Eternity:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,397 @@
+package fake
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessToken  = "secret-token"
+	testServiceToken = "service-token"
+)
+
+func newTestThreeScale() *ThreeScale {
+	return New(testAccessToken,
+		Service{
+			ID:    "1",
+			Name:  "api_key",
+			Token: testServiceToken,
+			Metrics: []Metric{
+				{SystemName: "hits"},
+				{SystemName: "orders", Parent: "hits"},
+			},
+			MappingRules: []MappingRule{
+				{Method: http.MethodGet, Pattern: "/orders", Metric: "orders", Delta: 1},
+			},
+			Applications: []Application{
+				{
+					UserKey: "VALID",
+					Plan:    "Basic",
+					Limits:  []Limit{{Metric: "hits", Period: Minute, Value: 2}},
+				},
+			},
+		},
+		Service{
+			ID:              "2",
+			Name:            "app_id",
+			Token:           testServiceToken,
+			BackendVersion:  BackendVersionAppID,
+			BackendEndpoint: "https://backend.example.com",
+			Applications: []Application{
+				{ID: "app", Keys: []string{"key"}},
+			},
+		},
+	)
+}
+
+func TestProxyConfig(t *testing.T) {
+	ts := newTestThreeScale()
+	server := ts.NewServer()
+	defer server.Close()
+
+	inputs := []struct {
+		name         string
+		path         string
+		token        string
+		expectStatus int
+		expectConfig proxyConfig
+	}{
+		{
+			name:         "Test fail with invalid access token",
+			path:         "/admin/api/services/1/proxy/configs/production/latest.json",
+			token:        "invalid",
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "Test fail with unknown service",
+			path:         "/admin/api/services/3/proxy/configs/production/latest.json",
+			token:        testAccessToken,
+			expectStatus: http.StatusNotFound,
+		},
+		{
+			name:         "Test backend defaults to server URL",
+			path:         "/admin/api/services/1/proxy/configs/production/latest.json",
+			token:        testAccessToken,
+			expectStatus: http.StatusOK,
+			expectConfig: proxyConfig{
+				Environment: "production",
+				Content: content{
+					BackendVersion:             BackendVersionAPIKey,
+					BackendAuthenticationType:  "service_token",
+					BackendAuthenticationValue: testServiceToken,
+					Proxy: contentProxy{
+						Backend: backend{Endpoint: server.URL, Host: strings.TrimPrefix(server.URL, "http://")},
+						ProxyRules: []proxyRule{
+							{HTTPMethod: http.MethodGet, Pattern: "/orders", MetricSystemName: "orders", Delta: 1, Position: 1},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:         "Test configured backend endpoint",
+			path:         "/admin/api/services/2/proxy/configs/staging/latest.json",
+			token:        testAccessToken,
+			expectStatus: http.StatusOK,
+			expectConfig: proxyConfig{
+				Environment: "staging",
+				Content: content{
+					BackendVersion:             BackendVersionAppID,
+					BackendAuthenticationType:  "service_token",
+					BackendAuthenticationValue: testServiceToken,
+					Proxy: contentProxy{
+						Backend:    backend{Endpoint: "https://backend.example.com", Host: "backend.example.com"},
+						ProxyRules: []proxyRule{},
+					},
+				},
+			},
+		},
+		{
+			name:         "Test pinned version",
+			path:         "/admin/api/services/2/proxy/configs/staging/4.json",
+			token:        testAccessToken,
+			expectStatus: http.StatusOK,
+			expectConfig: proxyConfig{
+				Version:     4,
+				Environment: "staging",
+				Content: content{
+					BackendVersion:             BackendVersionAppID,
+					BackendAuthenticationType:  "service_token",
+					BackendAuthenticationValue: testServiceToken,
+					Proxy: contentProxy{
+						Backend:    backend{Endpoint: "https://backend.example.com", Host: "backend.example.com"},
+						ProxyRules: []proxyRule{},
+					},
+				},
+			},
+		},
+	}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + input.path + "?access_token=" + input.token)
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != input.expectStatus {
+				t.Fatalf("expected status %d but got %d", input.expectStatus, resp.StatusCode)
+			}
+			if input.expectStatus != http.StatusOK {
+				return
+			}
+
+			element := proxyConfigElement{}
+			if err := json.NewDecoder(resp.Body).Decode(&element); err != nil {
+				t.Fatalf("unexpected error decoding response - %v", err)
+			}
+
+			got, _ := json.Marshal(element.ProxyConfig)
+			expect, _ := json.Marshal(input.expectConfig)
+			if string(got) != string(expect) {
+				t.Errorf("unexpected proxy config.\nWanted:\n%s\nGot:\n%s", expect, got)
+			}
+		})
+	}
+
+	if ts.Calls(ProxyConfigEndpoint) != len(inputs) {
+		t.Errorf("expected %d calls to be recorded but got %d", len(inputs), ts.Calls(ProxyConfigEndpoint))
+	}
+}
+
+func TestAuthRep(t *testing.T) {
+	inputs := []struct {
+		name            string
+		endpoint        string
+		params          url.Values
+		repeat          int
+		backendDown     bool
+		expectStatus    int
+		expectCode      string
+		expectRejection string
+		expectUsage     int64
+	}{
+		{
+			name:         "Test authorized with user key",
+			endpoint:     authRepPath,
+			params:       url.Values{"service_id": {"1"}, "user_key": {"VALID"}, "usage[orders]": {"1"}},
+			expectStatus: http.StatusOK,
+			expectUsage:  1,
+		},
+		{
+			name:            "Test limits exceeded",
+			endpoint:        authRepPath,
+			params:          url.Values{"service_id": {"1"}, "user_key": {"VALID"}, "usage[orders]": {"1"}},
+			repeat:          3,
+			expectStatus:    http.StatusConflict,
+			expectRejection: LimitsExceeded,
+			expectUsage:     2,
+		},
+		{
+			name:         "Test authorize does not report usage",
+			endpoint:     authorizePath,
+			params:       url.Values{"service_id": {"1"}, "user_key": {"VALID"}, "usage[hits]": {"1"}},
+			repeat:       3,
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Test invalid user key",
+			endpoint:     authRepPath,
+			params:       url.Values{"service_id": {"1"}, "user_key": {"INVALID"}, "usage[hits]": {"1"}},
+			expectStatus: http.StatusForbidden,
+			expectCode:   UserKeyInvalid,
+		},
+		{
+			name:         "Test invalid service token",
+			endpoint:     authRepPath,
+			params:       url.Values{"service_id": {"1"}, "service_token": {"invalid"}, "user_key": {"VALID"}},
+			expectStatus: http.StatusForbidden,
+			expectCode:   ServiceTokenInvalid,
+		},
+		{
+			name:         "Test unknown metric",
+			endpoint:     authRepPath,
+			params:       url.Values{"service_id": {"1"}, "user_key": {"VALID"}, "usage[unknown]": {"1"}},
+			expectStatus: http.StatusNotFound,
+			expectCode:   MetricInvalid,
+		},
+		{
+			name:         "Test unknown application",
+			endpoint:     authRepPath,
+			params:       url.Values{"service_id": {"2"}, "app_id": {"unknown"}, "usage[hits]": {"1"}},
+			expectStatus: http.StatusNotFound,
+			expectCode:   ApplicationNotFound,
+		},
+		{
+			name:            "Test invalid application key",
+			endpoint:        authRepPath,
+			params:          url.Values{"service_id": {"2"}, "app_id": {"app"}, "app_key": {"wrong"}, "usage[hits]": {"1"}},
+			expectStatus:    http.StatusConflict,
+			expectRejection: ApplicationKeyInvalid,
+		},
+		{
+			name:         "Test application key is not required for OpenID Connect",
+			endpoint:     oauthAuthRepPath,
+			params:       url.Values{"service_id": {"2"}, "app_id": {"app"}, "usage[hits]": {"1"}},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Test backend unavailable",
+			endpoint:     authRepPath,
+			params:       url.Values{"service_id": {"1"}, "user_key": {"VALID"}, "usage[hits]": {"1"}},
+			backendDown:  true,
+			expectStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			ts := newTestThreeScale()
+			ts.SetBackendDown(input.backendDown)
+			server := ts.NewServer()
+			defer server.Close()
+
+			if input.params.Get("service_token") == "" {
+				input.params.Set("service_token", testServiceToken)
+			}
+
+			requests := input.repeat
+			if requests == 0 {
+				requests = 1
+			}
+
+			var resp *http.Response
+			for i := 0; i < requests; i++ {
+				var err error
+				resp, err = http.Get(server.URL + input.endpoint + "?" + input.params.Encode())
+				if err != nil {
+					t.Fatalf("unexpected error - %v", err)
+				}
+				resp.Body.Close()
+			}
+
+			if resp.StatusCode != input.expectStatus {
+				t.Errorf("expected status %d but got %d", input.expectStatus, resp.StatusCode)
+			}
+
+			if rejection := resp.Header.Get(rejectionReasonHeader); rejection != input.expectRejection {
+				t.Errorf("expected rejection reason %q but got %q", input.expectRejection, rejection)
+			}
+
+			if input.expectCode != "" {
+				resp, err := http.Get(server.URL + input.endpoint + "?" + input.params.Encode())
+				if err != nil {
+					t.Fatalf("unexpected error - %v", err)
+				}
+				defer resp.Body.Close()
+				body, _ := ioutil.ReadAll(resp.Body)
+				e := errorXML{}
+				if err := xml.Unmarshal(body, &e); err != nil {
+					t.Fatalf("unexpected error decoding error response %s - %v", body, err)
+				}
+				if e.Code != input.expectCode {
+					t.Errorf("expected error code %s but got %s", input.expectCode, e.Code)
+				}
+			}
+
+			if usage := ts.Usage("1", "VALID", "hits", Minute); usage != input.expectUsage {
+				t.Errorf("expected usage %d but got %d", input.expectUsage, usage)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	ts := newTestThreeScale()
+	server := ts.NewServer()
+	defer server.Close()
+
+	resp, err := http.PostForm(server.URL+reportPath, url.Values{
+		"service_id":                     {"1"},
+		"service_token":                  {testServiceToken},
+		"transactions[0][user_key]":      {"VALID"},
+		"transactions[0][usage][orders]": {"5"},
+		"transactions[1][user_key]":      {"UNKNOWN"},
+		"transactions[1][usage][hits]":   {"1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected status %d but got %d", http.StatusAccepted, resp.StatusCode)
+	}
+
+	// reports are not subject to limits and usage is counted against parent metrics
+	if usage := ts.Usage("1", "VALID", "hits", Minute); usage != 5 {
+		t.Errorf("expected usage of parent metric to be 5 but got %d", usage)
+	}
+	if usage := ts.Usage("1", "VALID", "orders", Eternity); usage != 5 {
+		t.Errorf("expected usage of metric to be 5 but got %d", usage)
+	}
+}
+
+func TestUsageResetsEachPeriod(t *testing.T) {
+	now := time.Date(2019, time.January, 1, 10, 0, 30, 0, time.UTC)
+	ts := newTestThreeScale()
+	ts.SetClock(func() time.Time { return now })
+	server := ts.NewServer()
+	defer server.Close()
+
+	params := url.Values{
+		"service_id":    {"1"},
+		"service_token": {testServiceToken},
+		"user_key":      {"VALID"},
+		"usage[hits]":   {"2"},
+	}
+
+	authRep := func() int {
+		resp, err := http.Get(server.URL + authRepPath + "?" + params.Encode())
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := authRep(); status != http.StatusOK {
+		t.Fatalf("expected first request to be authorized but got %d", status)
+	}
+	if status := authRep(); status != http.StatusConflict {
+		t.Fatalf("expected second request within the minute to be denied but got %d", status)
+	}
+
+	now = now.Add(time.Minute)
+	if status := authRep(); status != http.StatusOK {
+		t.Errorf("expected request in the next minute to be authorized but got %d", status)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	conf, err := LoadConfig(strings.NewReader(`{
+  "access_token": "secret-token",
+  "services": [
+    {
+      "id": "1",
+      "token": "service-token",
+      "applications": [{"user_key": "VALID", "limits": [{"metric": "hits", "period": "minute", "value": 10}]}]
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	if conf.AccessToken != testAccessToken || len(conf.Services) != 1 || conf.Services[0].Applications[0].Limits[0].Value != 10 {
+		t.Errorf("unexpected config %v", conf)
+	}
+
+	if _, err := LoadConfig(strings.NewReader(`{"services": [{"id": "1", "applications": [{"limits": [{"period": "fortnight"}]}]}]}`)); err == nil {
+		t.Errorf("expected error for invalid period")
+	}
+}
@@ -0,0 +1,238 @@
+package fake
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// proxyConfigPath matches the latest proxy config of a service, or a version of it
+var proxyConfigPath = regexp.MustCompile(`^/admin/api/services/([^/]+)/proxy/configs/([^/]+)/(latest|\d+)\.json$`)
+
+const servicesPath = "/admin/api/services.xml"
+
+// proxyConfigElement is the JSON representation of a proxy config of a service
+type proxyConfigElement struct {
+	ProxyConfig proxyConfig `json:"proxy_config"`
+}
+
+type proxyConfig struct {
+	// Version is only set when a specific version is requested
+	Version     int     `json:"version,omitempty"`
+	Environment string  `json:"environment"`
+	Content     content `json:"content"`
+}
+
+type content struct {
+	BackendVersion             string             `json:"backend_version"`
+	BackendAuthenticationType  string             `json:"backend_authentication_type"`
+	BackendAuthenticationValue string             `json:"backend_authentication_value"`
+	BackendAPIConfigs          []backendAPIConfig `json:"backend_api_configs,omitempty"`
+	Proxy                      contentProxy       `json:"proxy"`
+}
+
+type backendAPIConfig struct {
+	Path       string     `json:"path"`
+	BackendAPI backendAPI `json:"backend_api"`
+}
+
+type backendAPI struct {
+	ID         int64  `json:"id"`
+	SystemName string `json:"system_name"`
+}
+
+type contentProxy struct {
+	Hosts      []string    `json:"hosts,omitempty"`
+	Backend    backend     `json:"backend"`
+	ProxyRules []proxyRule `json:"proxy_rules"`
+}
+
+type backend struct {
+	Endpoint string `json:"endpoint"`
+	Host     string `json:"host"`
+}
+
+type proxyRule struct {
+	HTTPMethod       string `json:"http_method"`
+	Pattern          string `json:"pattern"`
+	MetricSystemName string `json:"metric_system_name"`
+	Delta            int    `json:"delta"`
+	Position         int    `json:"position"`
+	Last             bool   `json:"last"`
+	OwnerType        string `json:"owner_type,omitempty"`
+	OwnerID          int64  `json:"owner_id,omitempty"`
+}
+
+type servicesXML struct {
+	XMLName  xml.Name     `xml:"services"`
+	Services []serviceXML `xml:"service"`
+}
+
+type serviceXML struct {
+	ID             string `xml:"id"`
+	Name           string `xml:"name"`
+	SystemName     string `xml:"system_name"`
+	BackendVersion string `xml:"backend_version"`
+}
+
+func (ts *ThreeScale) serveSystem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ts.mutex.RLock()
+	down := ts.systemDown
+	ts.mutex.RUnlock()
+
+	if r.URL.Path == servicesPath {
+		ts.recordCall(ServicesEndpoint)
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if !ts.authenticated(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "Access Denied"})
+			return
+		}
+		ts.serveServices(w)
+		return
+	}
+
+	matches := proxyConfigPath.FindStringSubmatch(r.URL.Path)
+	if matches == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ts.recordCall(ProxyConfigEndpoint)
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if !ts.authenticated(r) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Access Denied"})
+		return
+	}
+
+	ts.mutex.RLock()
+	svc, ok := ts.services[matches[1]]
+	ts.mutex.RUnlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "Not found"})
+		return
+	}
+
+	conf := proxyConfigFor(svc, matches[2], baseURL(r))
+	if matches[3] != "latest" {
+		conf.Version, _ = strconv.Atoi(matches[3])
+	}
+	writeJSON(w, http.StatusOK, proxyConfigElement{ProxyConfig: conf})
+}
+
+func (ts *ThreeScale) serveServices(w http.ResponseWriter) {
+	ts.mutex.RLock()
+	services := servicesXML{}
+	for _, id := range ts.serviceOrder {
+		svc := ts.services[id]
+		services.Services = append(services.Services, serviceXML{
+			ID:             svc.ID,
+			Name:           svc.Name,
+			SystemName:     svc.Name,
+			BackendVersion: svc.BackendVersion,
+		})
+	}
+	ts.mutex.RUnlock()
+
+	writeXML(w, http.StatusOK, services)
+}
+
+// authenticated returns true if the request provides the access token, either as a query parameter or basic auth password
+func (ts *ThreeScale) authenticated(r *http.Request) bool {
+	if r.URL.Query().Get("access_token") == ts.accessToken {
+		return true
+	}
+	_, password, ok := r.BasicAuth()
+	return ok && password == ts.accessToken
+}
+
+func proxyConfigFor(svc Service, environment string, defaultBackend string) proxyConfig {
+	endpoint := svc.BackendEndpoint
+	if endpoint == "" {
+		endpoint = defaultBackend
+	}
+
+	rules := make([]proxyRule, 0, len(svc.MappingRules))
+	addRules := func(mappingRules []MappingRule, ownerType string, ownerID int64) {
+		for _, rule := range mappingRules {
+			rules = append(rules, proxyRule{
+				HTTPMethod:       rule.Method,
+				Pattern:          rule.Pattern,
+				MetricSystemName: rule.Metric,
+				Delta:            rule.Delta,
+				Position:         len(rules) + 1,
+				Last:             rule.Last,
+				OwnerType:        ownerType,
+				OwnerID:          ownerID,
+			})
+		}
+	}
+
+	addRules(svc.MappingRules, "", 0)
+
+	var backendAPIConfigs []backendAPIConfig
+	for _, b := range svc.Backends {
+		addRules(b.MappingRules, "BackendApi", b.ID)
+		backendAPIConfigs = append(backendAPIConfigs, backendAPIConfig{
+			Path:       b.Path,
+			BackendAPI: backendAPI{ID: b.ID, SystemName: b.SystemName},
+		})
+	}
+
+	return proxyConfig{
+		Environment: environment,
+		Content: content{
+			BackendVersion:             svc.BackendVersion,
+			BackendAuthenticationType:  "service_token",
+			BackendAuthenticationValue: svc.Token,
+			BackendAPIConfigs:          backendAPIConfigs,
+			Proxy: contentProxy{
+				Hosts:      svc.Hosts,
+				Backend:    backend{Endpoint: endpoint, Host: hostOf(endpoint)},
+				ProxyRules: rules,
+			},
+		},
+	}
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func hostOf(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil {
+		return u.Host
+	}
+	return endpoint
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeXML(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
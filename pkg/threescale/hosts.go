@@ -0,0 +1,230 @@
+package threescale
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+
+	"istio.io/istio/mixer/template/authorization"
+	"istio.io/istio/pkg/log"
+)
+
+// defaultHostIndexRefreshInterval matches the default refresh interval of the system cache
+const defaultHostIndexRefreshInterval = time.Second * 180
+
+var (
+	errHostNotFound  = errors.New("no service found for request host")
+	errHostAmbiguous = errors.New("request host matches more than one service")
+)
+
+// serviceLister is implemented by config sources which hold the configuration of a known set of services,
+// in which case the services are not listed from 3scale system
+type serviceLister interface {
+	ServiceIDs(environment string) []string
+}
+
+// hostIndexKey identifies the services which can be resolved by a handler
+type hostIndexKey struct {
+	systemURL   string
+	accessToken string
+	opts        AuthorizerOptions
+}
+
+// hostIndex maps the hosts of the proxy configs of an account's services to their service ID.
+// Hosts which match more than one service map to an empty ID.
+type hostIndex struct {
+	services map[string]string
+	expires  time.Time
+	// built is set while the index is being built, and closed once it has been
+	built chan struct{}
+	// err is the error of the last build, if it failed
+	err   error
+	mutex sync.Mutex
+}
+
+// serviceIDForHost resolves the service whose proxy config matches the host of the request.
+// The index of hosts is built on first use and rebuilt once the system cache refresh interval of the handler has passed,
+// so that it is built from proxy configs refreshed by the system cache. The previous index is used while it is rebuilt
+// in the background, and if rebuilding fails. Requests made before the index is first built wait for a single build.
+func (s *Threescale) serviceIDForHost(host string, source ConfigSource, cfg *config.Params) (string, error) {
+	key := hostIndexKey{
+		systemURL:   cfg.SystemUrl,
+		accessToken: cfg.AccessToken,
+		opts:        AuthorizerOptionsFromConfig(cfg),
+	}
+
+	s.hostIndexesMutex.Lock()
+	if s.hostIndexes == nil {
+		s.hostIndexes = make(map[hostIndexKey]*hostIndex)
+	}
+	index, ok := s.hostIndexes[key]
+	if !ok {
+		index = &hostIndex{}
+		s.hostIndexes[key] = index
+	}
+	s.hostIndexesMutex.Unlock()
+
+	refresh := time.Duration(cfg.CacheRefreshSeconds) * time.Second
+	if refresh == 0 {
+		refresh = s.conf.HostIndexRefreshInterval
+	}
+	if refresh <= 0 {
+		refresh = defaultHostIndexRefreshInterval
+	}
+
+	index.mutex.Lock()
+	if time.Now().After(index.expires) && index.built == nil {
+		index.built = make(chan struct{})
+		// the params of the request are modified once its service is resolved, so the index is built from a copy
+		params := *cfg
+		if index.services == nil {
+			index.mutex.Unlock()
+			s.rebuildHostIndex(index, source, &params, refresh)
+			index.mutex.Lock()
+		} else {
+			go s.rebuildHostIndex(index, source, &params, refresh)
+		}
+	}
+
+	if index.services == nil && index.built != nil {
+		built := index.built
+		index.mutex.Unlock()
+		<-built
+		index.mutex.Lock()
+	}
+
+	services, err := index.services, index.err
+	index.mutex.Unlock()
+
+	if services == nil {
+		return "", err
+	}
+
+	serviceID, ok := services[normalizeHost(host)]
+	if !ok {
+		return "", errHostNotFound
+	}
+
+	if serviceID == "" {
+		return "", errHostAmbiguous
+	}
+	return serviceID, nil
+}
+
+// rebuildHostIndex builds the index, which must be marked as being built, without holding its mutex
+func (s *Threescale) rebuildHostIndex(index *hostIndex, source ConfigSource, cfg *config.Params, refresh time.Duration) {
+	services, err := s.buildHostIndex(source, cfg)
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.err = err
+	if err != nil {
+		if index.services != nil {
+			log.Warnf("%s", s.conf.Redactor.String(fmt.Sprintf("failed to rebuild host index, using previous index - %v", err)))
+		}
+	} else {
+		index.services = services
+	}
+
+	// a failed first build is retried on the next request
+	if index.services != nil {
+		index.expires = time.Now().Add(refresh)
+	}
+	close(index.built)
+	index.built = nil
+}
+
+// buildHostIndex fetches the proxy config of every service of the account from the source and indexes their hosts
+func (s *Threescale) buildHostIndex(source ConfigSource, cfg *config.Params) (map[string]string, error) {
+	request := s.systemRequestFromHandlerConfig(cfg)
+
+	var serviceIDs []string
+	if lister, ok := source.(serviceLister); ok {
+		serviceIDs = lister.ServiceIDs(request.Environment)
+	} else {
+		httpClient := s.conf.HTTPClient
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+
+		c, err := NewSystemClient(cfg.SystemUrl, cfg.AccessToken, httpClient)
+		if err != nil {
+			return nil, err
+		}
+
+		list, err := c.ListServices()
+		if err != nil {
+			return nil, fmt.Errorf("error listing services from 3scale - %v", err)
+		}
+
+		for _, svc := range list.Services {
+			serviceIDs = append(serviceIDs, svc.ID)
+		}
+	}
+
+	services := make(map[string]string)
+	for _, serviceID := range serviceIDs {
+		request.ServiceID = serviceID
+		conf, err := source.GetSystemConfiguration(cfg.SystemUrl, request)
+		if err != nil {
+			log.Warnf("%s", s.conf.Redactor.String(fmt.Sprintf("skipping service %s when indexing hosts - %v", serviceID, err)))
+			continue
+		}
+
+		for _, host := range proxyConfigHosts(conf) {
+			if existing, ok := services[host]; ok && existing != serviceID {
+				log.Warnf("host %s matches services %s and %s and cannot be resolved", host, existing, serviceID)
+				services[host] = ""
+				continue
+			}
+			services[host] = serviceID
+		}
+	}
+
+	return services, nil
+}
+
+// proxyConfigHosts returns the hosts of the proxy config and the host of its endpoint for the environment
+func proxyConfigHosts(conf client.ProxyConfig) []string {
+	endpoint := conf.Content.Proxy.Endpoint
+	if conf.Environment == EnvironmentStaging {
+		endpoint = conf.Content.Proxy.SandboxEndpoint
+	}
+
+	var hosts []string
+	for _, host := range conf.Content.Proxy.Hosts {
+		if host = normalizeHost(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	if u, err := url.Parse(endpoint); err == nil && u.Hostname() != "" {
+		hosts = append(hosts, normalizeHost(u.Hostname()))
+	}
+	return hosts
+}
+
+// normalizeHost strips any port from the host and lowercases it
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// hostFromInstance returns the host of the request passed in the action properties, if any
+func hostFromInstance(instance *authorization.InstanceMsg) string {
+	if instance == nil || instance.Action == nil {
+		return ""
+	}
+	return instance.Action.Properties[HostAttributeKey].GetStringValue()
+}
@@ -0,0 +1,246 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale/fake"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	policy "istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// systemSource fetches the latest proxy config of services from 3scale system without caching
+type systemSource struct {
+	httpClient *http.Client
+}
+
+func (s systemSource) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	c, err := NewSystemClient(systemURL, request.AccessToken, s.httpClient)
+	if err != nil {
+		return client.ProxyConfig{}, err
+	}
+
+	element, err := c.GetLatestProxyConfig(request.ServiceID, request.Environment)
+	return element.ProxyConfig, err
+}
+
+func TestServiceIDForHost(t *testing.T) {
+	const accessToken = "secret"
+
+	ts := fake.New(accessToken,
+		fake.Service{ID: "123", Token: "token-123", Hosts: []string{"api.example.com"}},
+		fake.Service{ID: "456", Token: "token-456", Hosts: []string{"other.example.com", "shared.example.com"}},
+		fake.Service{ID: "789", Token: "token-789", Hosts: []string{"shared.example.com"}},
+	)
+	server := ts.NewServer()
+	defer server.Close()
+
+	s := &Threescale{conf: &AdapterConfig{HTTPClient: server.Client()}}
+	source := systemSource{httpClient: server.Client()}
+	cfg := &config.Params{SystemUrl: server.URL, AccessToken: accessToken}
+
+	inputs := []struct {
+		name      string
+		host      string
+		expectID  string
+		expectErr error
+	}{
+		{
+			name:     "Test resolve host",
+			host:     "api.example.com",
+			expectID: "123",
+		},
+		{
+			name:     "Test resolve host ignores port and case",
+			host:     "Other.Example.com:8443",
+			expectID: "456",
+		},
+		{
+			name:      "Test fail - host matches more than one service",
+			host:      "shared.example.com",
+			expectErr: errHostAmbiguous,
+		},
+		{
+			name:      "Test fail - unknown host",
+			host:      "unknown.example.com",
+			expectErr: errHostNotFound,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			serviceID, err := s.serviceIDForHost(input.host, source, cfg)
+			if err != input.expectErr {
+				t.Fatalf("expected error %v but got %v", input.expectErr, err)
+			}
+
+			if serviceID != input.expectID {
+				t.Errorf("expected service %q but got %q", input.expectID, serviceID)
+			}
+		})
+	}
+
+	if calls := ts.Calls(fake.ServicesEndpoint); calls != 1 {
+		t.Errorf("expected services to be listed once while the index is fresh but got %d calls", calls)
+	}
+
+	ts.SetSystemDown(true)
+	s.hostIndexes[hostIndexKey{systemURL: server.URL, accessToken: accessToken}].expires = time.Time{}
+
+	if serviceID, err := s.serviceIDForHost("api.example.com", source, cfg); err != nil || serviceID != "123" {
+		t.Errorf("expected previous index to be used when rebuilding fails but got %q - %v", serviceID, err)
+	}
+}
+
+// blockingSource lists a single service whose proxy config is fetched once released
+type blockingSource struct {
+	conf    client.ProxyConfig
+	release chan struct{}
+	calls   chan struct{}
+}
+
+func (b blockingSource) ServiceIDs(environment string) []string {
+	return []string{"123"}
+}
+
+func (b blockingSource) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	b.calls <- struct{}{}
+	<-b.release
+	return b.conf, nil
+}
+
+func TestServiceIDForHostSingleBuild(t *testing.T) {
+	conf := client.ProxyConfig{Content: client.Content{Proxy: client.ContentProxy{Hosts: []string{"api.example.com"}}}}
+	source := blockingSource{conf: conf, release: make(chan struct{}), calls: make(chan struct{}, 10)}
+	s := &Threescale{conf: &AdapterConfig{}}
+	cfg := &config.Params{ServiceId: "unresolved"}
+
+	const requests = 5
+	results := make(chan string, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			serviceID, _ := s.serviceIDForHost("api.example.com", source, cfg)
+			results <- serviceID
+		}()
+	}
+
+	<-source.calls
+	close(source.release)
+	for i := 0; i < requests; i++ {
+		if serviceID := <-results; serviceID != "123" {
+			t.Errorf("expected host to be resolved once the index is built but got %q", serviceID)
+		}
+	}
+
+	if len(source.calls) != 0 {
+		t.Errorf("expected the index to be built once for concurrent requests but got %d builds", len(source.calls)+1)
+	}
+
+	// the previous index is served while the expired index is rebuilt
+	source.release = make(chan struct{})
+	defer close(source.release)
+	index := s.hostIndexes[hostIndexKey{}]
+	index.mutex.Lock()
+	index.expires = time.Time{}
+	index.mutex.Unlock()
+
+	done := make(chan string, 1)
+	go func() {
+		serviceID, _ := s.serviceIDForHost("api.example.com", source, cfg)
+		done <- serviceID
+	}()
+
+	select {
+	case serviceID := <-done:
+		if serviceID != "123" {
+			t.Errorf("expected previous index to be used while rebuilding but got %q", serviceID)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected request not to wait for the index to be rebuilt")
+	}
+}
+
+func TestHandleAuthorizationServiceFromHost(t *testing.T) {
+	const hostProxyConfig = `{"proxy_config": {"environment": "production", "content": {"backend_version": "1", "proxy": {
+  "hosts": ["api.example.com"],
+  "proxy_rules": [{"http_method": "GET", "pattern": "/", "metric_system_name": "hits", "delta": 1}]
+}}}}`
+
+	source := NewStaticConfigSource()
+	if err := source.Load(map[string][]byte{"123.json": []byte(hostProxyConfig)}); err != nil {
+		t.Fatalf("unexpected error loading proxy configs - %v", err)
+	}
+
+	params := config.Params{BackendUrl: internalBackend}
+	b, _ := params.Marshal()
+
+	inputs := []struct {
+		name       string
+		host       string
+		expectCode rpc.Code
+	}{
+		{
+			name:       "Test service resolved from host",
+			host:       "api.example.com",
+			expectCode: rpc.OK,
+		},
+		{
+			name:       "Test fail - no service for host",
+			host:       "unknown.example.com",
+			expectCode: rpc.NOT_FOUND,
+		},
+		{
+			name:       "Test fail - no service ID or host",
+			expectCode: rpc.FAILED_PRECONDITION,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			s := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							if request.Service != "123" {
+								t.Errorf("expected request for service 123 but got %q", request.Service)
+							}
+						},
+						withAuthResponse: &authorizer.BackendResponse{},
+						t:                t,
+					},
+					ConfigSource: source,
+				},
+			}
+
+			action := &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/",
+			}
+			if input.host != "" {
+				action.Properties = map[string]*policy.Value{HostAttributeKey: stringValue(input.host)}
+			}
+
+			result, _ := s.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Subject: &authorization.SubjectMsg{
+						User: "VALID",
+					},
+					Action: action,
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != int32(input.expectCode) {
+				t.Errorf("expected status code %v but got %v", input.expectCode, result.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package threescale
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+	"istio.io/istio/mixer/pkg/status"
+	"istio.io/istio/pkg/log"
+)
+
+// ShadowDenial describes a request which would have been denied by a handler which does not enforce authorization decisions
+type ShadowDenial struct {
+	ServiceID string
+	// Mode of the handler, one of ModeShadow or ModeReportOnly
+	Mode string
+	// Reason is the 3scale error code for requests denied by 3scale backend, or the name of the status code otherwise
+	Reason string
+}
+
+// modeFromConfig returns the mode set in the handler config, defaulting to ModeEnforce
+func modeFromConfig(cfg *config.Params) string {
+	if cfg.Mode == "" {
+		return ModeEnforce
+	}
+	return strings.ToLower(cfg.Mode)
+}
+
+// sampleEnforcement returns true for the given percentage of calls
+func sampleEnforcement(percentage int64) bool {
+	return percentage > 0 && rand.Int63n(100) < percentage
+}
+
+// withoutUsage sets the delta of every metric of the request to zero
+func withoutUsage(request authorizer.BackendRequest) {
+	for _, transaction := range request.Transactions {
+		for metric := range transaction.Metrics {
+			transaction.Metrics[metric] = 0
+		}
+	}
+}
+
+// shadowResult allows a request whose authorization decision is not enforced.
+// A would-be denial is logged and passed to the OnShadowDenial callback of the adapter, if any.
+func (s *Threescale) shadowResult(result *v1beta1.CheckResult, err error, mode string, serviceID string) *v1beta1.CheckResult {
+	if err == nil && result.Status.Code == int32(rpc.OK) {
+		return result
+	}
+
+	denial := ShadowDenial{
+		ServiceID: serviceID,
+		Mode:      mode,
+		Reason:    shadowDenialReason(result.Status),
+	}
+
+	log.Infof("%s", s.conf.Redactor.String(fmt.Sprintf("%s mode: request to service %s would have been denied with %s - %s",
+		mode, serviceID, rpc.Code(result.Status.Code), result.Status.Message)))
+
+	if s.conf.OnShadowDenial != nil {
+		s.conf.OnShadowDenial(denial)
+	}
+
+	result.Status = status.OK
+	return result
+}
+
+// shadowDenialReason returns the 3scale error code of a denial, or the name of its status code if it was not denied by 3scale backend
+func shadowDenialReason(st rpc.Status) string {
+	if _, ok := errorCodeMessages[st.Message]; ok {
+		return st.Message
+	}
+	return rpc.Code(st.Code).String()
+}
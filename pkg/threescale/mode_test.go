@@ -0,0 +1,161 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestHandleAuthorizationMode(t *testing.T) {
+	source := NewStaticConfigSource()
+	if err := source.Load(map[string][]byte{"123.json": proxyConfigFile(EnvironmentProduction)}); err != nil {
+		t.Fatalf("unexpected error loading proxy configs - %v", err)
+	}
+
+	inputs := []struct {
+		name            string
+		params          config.Params
+		user            string
+		backendErr      error
+		expectCode      rpc.Code
+		expectDelta     int
+		expectDenial    *ShadowDenial
+		expectNoBackend bool
+	}{
+		{
+			name:        "Test enforce mode denies request",
+			params:      config.Params{Mode: ModeEnforce},
+			user:        "INVALID",
+			expectCode:  rpc.UNAUTHENTICATED,
+			expectDelta: 1,
+		},
+		{
+			name:         "Test shadow mode allows denied request without reporting usage",
+			params:       config.Params{Mode: ModeShadow},
+			user:         "INVALID",
+			expectCode:   rpc.OK,
+			expectDelta:  0,
+			expectDenial: &ShadowDenial{ServiceID: "123", Mode: ModeShadow, Reason: "user_key_invalid"},
+		},
+		{
+			name:         "Test report-only mode allows denied request and reports usage",
+			params:       config.Params{Mode: "Report-Only"},
+			user:         "INVALID",
+			expectCode:   rpc.OK,
+			expectDelta:  1,
+			expectDenial: &ShadowDenial{ServiceID: "123", Mode: ModeReportOnly, Reason: "user_key_invalid"},
+		},
+		{
+			name:        "Test shadow mode records no denial for authorized request",
+			params:      config.Params{Mode: ModeShadow},
+			user:        "VALID",
+			expectCode:  rpc.OK,
+			expectDelta: 0,
+		},
+		{
+			name:         "Test shadow mode allows request when 3scale fails",
+			params:       config.Params{Mode: ModeShadow},
+			user:         "VALID",
+			backendErr:   errors.New("unavailable"),
+			expectCode:   rpc.OK,
+			expectDelta:  0,
+			expectDenial: &ShadowDenial{ServiceID: "123", Mode: ModeShadow, Reason: rpc.UNKNOWN.String()},
+		},
+		{
+			name:        "Test shadow mode enforces sampled request",
+			params:      config.Params{Mode: ModeShadow, EnforcePercentage: 100},
+			user:        "INVALID",
+			expectCode:  rpc.UNAUTHENTICATED,
+			expectDelta: 1,
+		},
+		{
+			name:            "Test fail - invalid mode",
+			params:          config.Params{Mode: "dry-run"},
+			expectCode:      rpc.FAILED_PRECONDITION,
+			expectNoBackend: true,
+		},
+		{
+			name:            "Test fail - invalid enforce percentage",
+			params:          config.Params{Mode: ModeShadow, EnforcePercentage: 101},
+			expectCode:      rpc.FAILED_PRECONDITION,
+			expectNoBackend: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var denials []ShadowDenial
+			calledBackend := false
+
+			input.params.ServiceId = "123"
+			input.params.BackendUrl = internalBackend
+			b, _ := input.params.Marshal()
+
+			s := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							calledBackend = true
+							if delta := request.Transactions[0].Metrics["hits"]; delta != input.expectDelta {
+								t.Errorf("expected hits delta %d but got %d", input.expectDelta, delta)
+							}
+						},
+						withBackendErr: input.backendErr,
+						withAuthResponse: &authorizer.BackendResponse{
+							ErrorCode: "user_key_invalid",
+						},
+						t: t,
+					},
+					ConfigSource: source,
+					OnShadowDenial: func(denial ShadowDenial) {
+						denials = append(denials, denial)
+					},
+				},
+			}
+
+			result, err := s.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Subject: &authorization.SubjectMsg{
+						User: input.user,
+					},
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if err != nil {
+				t.Errorf("unexpected error - %v", err)
+			}
+
+			if result.Status.Code != int32(input.expectCode) {
+				t.Errorf("expected status code %v but got %v", input.expectCode, result.Status)
+			}
+
+			if calledBackend == input.expectNoBackend {
+				t.Errorf("unexpected call to 3scale backend state %v", calledBackend)
+			}
+
+			if input.expectDenial == nil {
+				if len(denials) != 0 {
+					t.Errorf("expected no shadow denials but got %v", denials)
+				}
+				return
+			}
+
+			if len(denials) != 1 || denials[0] != *input.expectDenial {
+				t.Errorf("expected shadow denial %v but got %v", *input.expectDenial, denials)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package threescale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// PinnedAuthorizer fetches the proxy configuration of services at a pinned version and delegates all other calls.
+// A version of a proxy configuration never changes, so fetched configurations are cached for the lifetime of the PinnedAuthorizer.
+type PinnedAuthorizer struct {
+	Authorizer
+	version    int64
+	httpClient *http.Client
+
+	cache map[pinnedKey]client.ProxyConfig
+	mutex sync.RWMutex
+}
+
+type pinnedKey struct {
+	systemURL   string
+	accessToken string
+	serviceID   string
+	environment string
+}
+
+// NewPinnedAuthorizer returns an Authorizer which pins the proxy configuration of services to version
+func NewPinnedAuthorizer(authz Authorizer, version int64, httpClient *http.Client) *PinnedAuthorizer {
+	return &PinnedAuthorizer{
+		Authorizer: authz,
+		version:    version,
+		httpClient: httpClient,
+		cache:      make(map[pinnedKey]client.ProxyConfig),
+	}
+}
+
+// GetSystemConfiguration returns the pinned version of the proxy configuration of the requested service
+func (p *PinnedAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	return p.GetSystemConfigurationContext(context.Background(), systemURL, request)
+}
+
+// GetSystemConfigurationContext returns the pinned version of the proxy configuration of the requested service,
+// cancelling the request to 3scale system once the context is done
+func (p *PinnedAuthorizer) GetSystemConfigurationContext(ctx context.Context, systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	key := pinnedKey{
+		systemURL:   systemURL,
+		accessToken: request.AccessToken,
+		serviceID:   request.ServiceID,
+		environment: request.Environment,
+	}
+
+	p.mutex.RLock()
+	conf, ok := p.cache[key]
+	p.mutex.RUnlock()
+	if ok {
+		return conf, nil
+	}
+
+	c, err := NewSystemClient(systemURL, request.AccessToken, clientWithContext(ctx, p.httpClient))
+	if err != nil {
+		return client.ProxyConfig{}, err
+	}
+
+	element, err := c.GetProxyConfig(request.ServiceID, request.Environment, strconv.FormatInt(p.version, 10))
+	if err != nil {
+		return client.ProxyConfig{}, err
+	}
+
+	p.mutex.Lock()
+	p.cache[key] = element.ProxyConfig
+	p.mutex.Unlock()
+
+	return element.ProxyConfig, nil
+}
+
+// Invalidate removes the pinned proxy configurations of a service, or of all services if serviceID is empty,
+// so that they are fetched on their next request. It returns the number of configurations removed.
+func (p *PinnedAuthorizer) Invalidate(serviceID string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	removed := 0
+	for key := range p.cache {
+		if serviceID == "" || key.serviceID == serviceID {
+			delete(p.cache, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// AuthRepContext delegates to the wrapped Authorizer
+func (p *PinnedAuthorizer) AuthRepContext(ctx context.Context, backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return WithContext(p.Authorizer).AuthRepContext(ctx, backendURL, request)
+}
+
+// OauthAuthRepContext delegates to the wrapped Authorizer
+func (p *PinnedAuthorizer) OauthAuthRepContext(ctx context.Context, backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return WithContext(p.Authorizer).OauthAuthRepContext(ctx, backendURL, request)
+}
+
+// NewSystemClient creates a client for the 3scale Account Management API of the system URL
+func NewSystemClient(systemURL, accessToken string, httpClient *http.Client) (*client.ThreeScaleClient, error) {
+	u, err := url.ParseRequestURI(systemURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing system url - %v", err)
+	}
+
+	port, err := portForURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing system url port - %v", err)
+	}
+
+	ap, err := client.NewAdminPortal(u.Scheme, u.Hostname(), port)
+	if err != nil {
+		return nil, fmt.Errorf("error creating admin portal from system url - %v", err)
+	}
+
+	return client.NewThreeScale(ap, accessToken, httpClient), nil
+}
+
+// portForURL returns the port for the provided URL, falling back to the default port for the scheme
+func portForURL(u *url.URL) (int, error) {
+	if u.Port() != "" {
+		return strconv.Atoi(u.Port())
+	}
+
+	switch u.Scheme {
+	case "http":
+		return 80, nil
+	case "https":
+		return 443, nil
+	default:
+		return 0, fmt.Errorf("unable to determine port for scheme %q", u.Scheme)
+	}
+}
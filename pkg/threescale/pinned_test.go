@@ -0,0 +1,59 @@
+package threescale
+
+import (
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale/fake"
+)
+
+func TestPinnedAuthorizer(t *testing.T) {
+	const accessToken = "secret"
+
+	ts := fake.New(accessToken, fake.Service{
+		ID:             "123",
+		Token:          "service-token",
+		BackendVersion: fake.BackendVersionAppID,
+	})
+	server := ts.NewServer()
+	defer server.Close()
+
+	p := NewPinnedAuthorizer(&mockAuthorizer{}, 4, server.Client())
+	request := authorizer.SystemRequest{
+		AccessToken: accessToken,
+		ServiceID:   "123",
+		Environment: EnvironmentStaging,
+	}
+
+	for i := 0; i < 2; i++ {
+		conf, err := p.GetSystemConfiguration(server.URL, request)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+
+		if conf.Environment != EnvironmentStaging || conf.Content.BackendVersion != fake.BackendVersionAppID {
+			t.Errorf("unexpected proxy config %+v", conf)
+		}
+	}
+
+	if calls := ts.Calls(fake.ProxyConfigEndpoint); calls != 1 {
+		t.Errorf("expected pinned proxy config to be fetched once but got %d calls", calls)
+	}
+
+	if removed := p.Invalidate("123"); removed != 1 {
+		t.Errorf("expected pinned proxy config to be invalidated but got %d removed", removed)
+	}
+
+	if _, err := p.GetSystemConfiguration(server.URL, request); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if calls := ts.Calls(fake.ProxyConfigEndpoint); calls != 2 {
+		t.Errorf("expected invalidated proxy config to be fetched again but got %d calls", calls)
+	}
+
+	request.AccessToken = "invalid"
+	if _, err := p.GetSystemConfiguration(server.URL, request); err == nil {
+		t.Errorf("expected error fetching proxy config with invalid access token")
+	}
+}
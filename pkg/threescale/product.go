@@ -0,0 +1,350 @@
+package threescale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/protobuf/types"
+)
+
+// Fields of the google.protobuf.Struct returned in the details of the status of a request authorized against a product
+const (
+	BackendSystemNameField = "backend"
+	BackendPathField       = "backend_path"
+)
+
+const (
+	// backendOwnerType is the owner type of the mapping rules of a backend in the proxy config of a product
+	backendOwnerType = "BackendApi"
+	// latestProxyConfigVersion identifies the latest version of a proxy config when fetching it from 3scale system
+	latestProxyConfigVersion = "latest"
+)
+
+// Product describes how a 3scale product routes requests to its backends.
+// A service which uses no backends is not a product and its mapping rules are matched as a whole.
+type Product struct {
+	// ProxyRules are the mapping rules of the product itself, matched against the full path of requests
+	ProxyRules []client.ProxyRule
+	Backends   []BackendUsage
+}
+
+// BackendUsage is a backend which serves the requests to a product under a path prefix
+type BackendUsage struct {
+	ID         int64
+	SystemName string
+	Path       string
+	// ProxyRules are the mapping rules of the backend, matched against the path of requests with the prefix stripped
+	ProxyRules []client.ProxyRule
+}
+
+// ProductSource is implemented by config sources which describe the backends of products
+type ProductSource interface {
+	GetProduct(systemURL string, request authorizer.SystemRequest) (Product, error)
+}
+
+// proxyConfigProduct is the subset of a proxy config in the JSON format exported by 3scale system which describes a product
+type proxyConfigProduct struct {
+	Content struct {
+		BackendAPIConfigs []struct {
+			Path       string `json:"path"`
+			BackendAPI struct {
+				ID         int64  `json:"id"`
+				SystemName string `json:"system_name"`
+			} `json:"backend_api"`
+		} `json:"backend_api_configs"`
+		Proxy struct {
+			ProxyRules []json.RawMessage `json:"proxy_rules"`
+		} `json:"proxy"`
+	} `json:"content"`
+}
+
+// proxyRuleOwner is the owner of a mapping rule in the proxy config of a product
+type proxyRuleOwner struct {
+	OwnerType string `json:"owner_type"`
+	OwnerID   int64  `json:"owner_id"`
+}
+
+// ParseProduct parses the backends of a product from a proxy config in the JSON format exported by 3scale system,
+// either wrapped in a "proxy_config" element or unwrapped. Mapping rules owned by a backend are assigned to it.
+func ParseProduct(data []byte) (Product, error) {
+	element := struct {
+		ProxyConfig *proxyConfigProduct `json:"proxy_config"`
+	}{}
+
+	if err := json.Unmarshal(data, &element); err != nil {
+		return Product{}, fmt.Errorf("error parsing product - %v", err)
+	}
+
+	conf := element.ProxyConfig
+	if conf == nil {
+		conf = &proxyConfigProduct{}
+		if err := json.Unmarshal(data, conf); err != nil {
+			return Product{}, fmt.Errorf("error parsing product - %v", err)
+		}
+	}
+
+	product := Product{}
+	backends := make(map[int64]int)
+	for _, backendConf := range conf.Content.BackendAPIConfigs {
+		backends[backendConf.BackendAPI.ID] = len(product.Backends)
+		product.Backends = append(product.Backends, BackendUsage{
+			ID:         backendConf.BackendAPI.ID,
+			SystemName: backendConf.BackendAPI.SystemName,
+			Path:       backendConf.Path,
+		})
+	}
+
+	for _, raw := range conf.Content.Proxy.ProxyRules {
+		rule := client.ProxyRule{}
+		owner := proxyRuleOwner{}
+		if err := json.Unmarshal(raw, &rule); err != nil {
+			return Product{}, fmt.Errorf("error parsing product mapping rule - %v", err)
+		}
+		if err := json.Unmarshal(raw, &owner); err != nil {
+			return Product{}, fmt.Errorf("error parsing product mapping rule - %v", err)
+		}
+
+		i, ok := backends[owner.OwnerID]
+		if owner.OwnerType != backendOwnerType || !ok {
+			product.ProxyRules = append(product.ProxyRules, rule)
+			continue
+		}
+		product.Backends[i].ProxyRules = append(product.Backends[i].ProxyRules, rule)
+	}
+
+	return product, nil
+}
+
+// productFromSource returns the product described by the source, if it describes products,
+// bound to the context if the source supports it
+func productFromSource(ctx context.Context, source ConfigSource, systemURL string, request authorizer.SystemRequest) (Product, error) {
+	if products, ok := source.(ContextProductSource); ok {
+		return products.GetProductContext(ctx, systemURL, request)
+	}
+
+	products, ok := source.(ProductSource)
+	if !ok {
+		return Product{}, nil
+	}
+	return products.GetProduct(systemURL, request)
+}
+
+// IsProduct returns true if the service routes requests to backends
+func (p Product) IsProduct() bool {
+	return len(p.Backends) > 0
+}
+
+// BackendFor returns the backend whose path is the longest prefix of the request path, or nil if none match
+func (p Product) BackendFor(path string) *BackendUsage {
+	var match *BackendUsage
+	for i, backend := range p.Backends {
+		if !hasPathPrefix(path, backend.Path) {
+			continue
+		}
+
+		if match == nil || len(backend.Path) > len(match.Path) {
+			match = &p.Backends[i]
+		}
+	}
+	return match
+}
+
+// generateProductMetrics matches the request against the mapping rules of the product and, with the prefix stripped,
+// against those of the backend serving the path. Metrics of both are merged.
+func generateProductMetrics(path string, method string, conf client.ProxyConfig, product Product) api.Metrics {
+	metrics := evaluateMappingRules(path, method, withProxyRules(conf, product.ProxyRules), nil)
+
+	if backend := product.BackendFor(path); backend != nil {
+		stripped := strings.TrimPrefix(path, strings.TrimSuffix(backend.Path, "/"))
+		if !strings.HasPrefix(stripped, "/") {
+			stripped = "/" + stripped
+		}
+
+		for metric, delta := range evaluateMappingRules(stripped, method, withProxyRules(conf, backend.ProxyRules), nil) {
+			metrics.Add(metric, delta)
+		}
+	}
+	return metrics
+}
+
+// withProxyRules returns a copy of the proxy config with its mapping rules replaced by a copy of rules,
+// since rules are sorted in place when evaluated
+func withProxyRules(conf client.ProxyConfig, rules []client.ProxyRule) client.ProxyConfig {
+	conf.Content.Proxy.ProxyRules = append([]client.ProxyRule(nil), rules...)
+	return conf
+}
+
+// hasPathPrefix returns true if the path is equal to the prefix or continues it with a new segment
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// backendDetails describe the backend a request was routed to in the status details
+func backendDetails(backend *BackendUsage) (*types.Any, error) {
+	return types.MarshalAny(&types.Struct{Fields: map[string]*types.Value{
+		BackendSystemNameField: {Kind: &types.Value_StringValue{StringValue: backend.SystemName}},
+		BackendPathField:       {Kind: &types.Value_StringValue{StringValue: backend.Path}},
+	}})
+}
+
+// ProductAuthorizer fetches the backends of products from 3scale system and delegates all other calls.
+// Products are cached for the ttl, or for the lifetime of the ProductAuthorizer if the ttl is zero.
+// If fetching a product fails, an expired product is used until it can be fetched again.
+type ProductAuthorizer struct {
+	Authorizer
+	version    string
+	ttl        time.Duration
+	httpClient *http.Client
+
+	cache map[pinnedKey]cachedProduct
+	mutex sync.RWMutex
+}
+
+type cachedProduct struct {
+	product Product
+	expires time.Time
+}
+
+// NewProductAuthorizer returns an Authorizer which describes products from the proxy configuration at version,
+// or from the latest proxy configuration if version is zero
+func NewProductAuthorizer(authz Authorizer, version int64, ttl time.Duration, httpClient *http.Client) *ProductAuthorizer {
+	v := latestProxyConfigVersion
+	if version > 0 {
+		v = strconv.FormatInt(version, 10)
+	}
+
+	return &ProductAuthorizer{
+		Authorizer: authz,
+		version:    v,
+		ttl:        ttl,
+		httpClient: httpClient,
+		cache:      make(map[pinnedKey]cachedProduct),
+	}
+}
+
+// GetProduct returns the backends of the product described by the proxy configuration of the requested service
+func (p *ProductAuthorizer) GetProduct(systemURL string, request authorizer.SystemRequest) (Product, error) {
+	return p.GetProductContext(context.Background(), systemURL, request)
+}
+
+// GetProductContext returns the backends of the product described by the proxy configuration of the requested service,
+// cancelling the request to 3scale system once the context is done
+func (p *ProductAuthorizer) GetProductContext(ctx context.Context, systemURL string, request authorizer.SystemRequest) (Product, error) {
+	key := pinnedKey{
+		systemURL:   systemURL,
+		accessToken: request.AccessToken,
+		serviceID:   request.ServiceID,
+		environment: request.Environment,
+	}
+
+	p.mutex.RLock()
+	cached, ok := p.cache[key]
+	p.mutex.RUnlock()
+	if ok && (cached.expires.IsZero() || time.Now().Before(cached.expires)) {
+		return cached.product, nil
+	}
+
+	data, err := fetchProxyConfig(ctx, p.httpClient, systemURL, request, p.version)
+	if err != nil {
+		if ok {
+			return cached.product, nil
+		}
+		return Product{}, err
+	}
+
+	product, err := ParseProduct(data)
+	if err != nil {
+		return Product{}, err
+	}
+
+	cached = cachedProduct{product: product}
+	if p.ttl > 0 {
+		cached.expires = time.Now().Add(p.ttl)
+	}
+
+	p.mutex.Lock()
+	p.cache[key] = cached
+	p.mutex.Unlock()
+
+	return product, nil
+}
+
+// Invalidate removes the cached products of a service, or of all services if serviceID is empty,
+// so that they are fetched on their next request. It returns the number of products removed.
+func (p *ProductAuthorizer) Invalidate(serviceID string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	removed := 0
+	for key := range p.cache {
+		if serviceID == "" || key.serviceID == serviceID {
+			delete(p.cache, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// GetSystemConfigurationContext delegates to the wrapped Authorizer
+func (p *ProductAuthorizer) GetSystemConfigurationContext(ctx context.Context, systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	return WithContext(p.Authorizer).GetSystemConfigurationContext(ctx, systemURL, request)
+}
+
+// AuthRepContext delegates to the wrapped Authorizer
+func (p *ProductAuthorizer) AuthRepContext(ctx context.Context, backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return WithContext(p.Authorizer).AuthRepContext(ctx, backendURL, request)
+}
+
+// OauthAuthRepContext delegates to the wrapped Authorizer
+func (p *ProductAuthorizer) OauthAuthRepContext(ctx context.Context, backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return WithContext(p.Authorizer).OauthAuthRepContext(ctx, backendURL, request)
+}
+
+// fetchProxyConfig fetches a version of the proxy configuration of a service from 3scale system in its JSON format
+func fetchProxyConfig(ctx context.Context, httpClient *http.Client, systemURL string, request authorizer.SystemRequest, version string) ([]byte, error) {
+	u, err := url.Parse(systemURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing system url - %v", err)
+	}
+
+	environment := request.Environment
+	if environment == "" {
+		environment = EnvironmentProduction
+	}
+
+	u.Path = fmt.Sprintf("/admin/api/services/%s/proxy/configs/%s/%s.json", url.PathEscape(request.ServiceID), url.PathEscape(environment), version)
+	u.RawQuery = url.Values{"access_token": []string{request.AccessToken}}.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to 3scale - %v", err)
+	}
+
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching proxy config from 3scale - %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching proxy config from 3scale - unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading proxy config from 3scale - %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,238 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale/fake"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// productProxyConfig models a product with a books backend under /books and an authors backend under /authors
+const productProxyConfig = `{
+  "proxy_config": {
+    "environment": "production",
+    "content": {
+      "backend_version": "1",
+      "backend_api_configs": [
+        {"path": "/books", "backend_api": {"id": 1, "system_name": "books"}},
+        {"path": "/authors/", "backend_api": {"id": 2, "system_name": "authors"}}
+      ],
+      "proxy": {
+        "proxy_rules": [
+          {"http_method": "GET", "pattern": "/", "metric_system_name": "hits", "delta": 1, "position": 1},
+          {"http_method": "GET", "pattern": "/", "metric_system_name": "books", "delta": 1, "position": 2, "owner_type": "BackendApi", "owner_id": 1},
+          {"http_method": "GET", "pattern": "/[0-9]+$", "metric_system_name": "book", "delta": 2, "position": 3, "owner_type": "BackendApi", "owner_id": 1},
+          {"http_method": "GET", "pattern": "/", "metric_system_name": "authors", "delta": 1, "position": 4, "owner_type": "BackendApi", "owner_id": 2}
+        ]
+      }
+    }
+  }
+}`
+
+func TestParseProduct(t *testing.T) {
+	product, err := ParseProduct([]byte(productProxyConfig))
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if !product.IsProduct() || len(product.Backends) != 2 {
+		t.Fatalf("expected product with two backends but got %+v", product)
+	}
+
+	if len(product.ProxyRules) != 1 || product.ProxyRules[0].MetricSystemName != "hits" {
+		t.Errorf("expected product to own only the hits mapping rule but got %+v", product.ProxyRules)
+	}
+
+	books, authors := product.Backends[0], product.Backends[1]
+	if books.SystemName != "books" || books.Path != "/books" || len(books.ProxyRules) != 2 {
+		t.Errorf("unexpected books backend %+v", books)
+	}
+
+	if authors.SystemName != "authors" || authors.Path != "/authors/" || len(authors.ProxyRules) != 1 {
+		t.Errorf("unexpected authors backend %+v", authors)
+	}
+
+	notProduct, err := ParseProduct([]byte(staticProxyConfig))
+	if err != nil || notProduct.IsProduct() {
+		t.Errorf("expected service without backends not to be a product - %v", err)
+	}
+}
+
+func TestGenerateProductMetrics(t *testing.T) {
+	conf, _ := ParseProxyConfig([]byte(productProxyConfig))
+	product, _ := ParseProduct([]byte(productProxyConfig))
+
+	inputs := []struct {
+		name          string
+		path          string
+		expectMetrics api.Metrics
+		expectBackend string
+	}{
+		{
+			name:          "Test backend rules match path with prefix stripped",
+			path:          "/books/123",
+			expectMetrics: api.Metrics{"hits": 1, "books": 1, "book": 2},
+			expectBackend: "books",
+		},
+		{
+			name:          "Test path equal to backend prefix",
+			path:          "/books",
+			expectMetrics: api.Metrics{"hits": 1, "books": 1},
+			expectBackend: "books",
+		},
+		{
+			name:          "Test backend with trailing slash in path",
+			path:          "/authors/1",
+			expectMetrics: api.Metrics{"hits": 1, "authors": 1},
+			expectBackend: "authors",
+		},
+		{
+			name:          "Test prefix must match a whole segment",
+			path:          "/booksellers",
+			expectMetrics: api.Metrics{"hits": 1},
+		},
+		{
+			name:          "Test no backend matches",
+			path:          "/other",
+			expectMetrics: api.Metrics{"hits": 1},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			metrics := generateProductMetrics(input.path, http.MethodGet, conf, product)
+			if !reflect.DeepEqual(metrics, input.expectMetrics) {
+				t.Errorf("expected metrics %v but got %v", input.expectMetrics, metrics)
+			}
+
+			backend := product.BackendFor(input.path)
+			if input.expectBackend == "" {
+				if backend != nil {
+					t.Errorf("expected no backend but got %s", backend.SystemName)
+				}
+				return
+			}
+
+			if backend == nil || backend.SystemName != input.expectBackend {
+				t.Errorf("expected backend %s but got %+v", input.expectBackend, backend)
+			}
+		})
+	}
+}
+
+func TestProductAuthorizer(t *testing.T) {
+	const accessToken = "secret"
+
+	ts := fake.New(accessToken, fake.Service{
+		ID:           "123",
+		Token:        "service-token",
+		MappingRules: []fake.MappingRule{{Method: http.MethodGet, Pattern: "/", Metric: "hits", Delta: 1}},
+		Backends: []fake.Backend{
+			{ID: 1, SystemName: "books", Path: "/books", MappingRules: []fake.MappingRule{{Method: http.MethodGet, Pattern: "/", Metric: "books", Delta: 1}}},
+			{ID: 2, SystemName: "authors", Path: "/authors", MappingRules: []fake.MappingRule{{Method: http.MethodGet, Pattern: "/", Metric: "authors", Delta: 1}}},
+		},
+	})
+	server := ts.NewServer()
+	defer server.Close()
+
+	p := NewProductAuthorizer(&mockAuthorizer{}, 0, time.Minute, server.Client())
+	request := authorizer.SystemRequest{AccessToken: accessToken, ServiceID: "123"}
+
+	for i := 0; i < 2; i++ {
+		product, err := p.GetProduct(server.URL, request)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+
+		if len(product.ProxyRules) != 1 || len(product.Backends) != 2 || product.Backends[1].ProxyRules[0].MetricSystemName != "authors" {
+			t.Errorf("unexpected product %+v", product)
+		}
+	}
+
+	if calls := ts.Calls(fake.ProxyConfigEndpoint); calls != 1 {
+		t.Errorf("expected product to be fetched once within the ttl but got %d calls", calls)
+	}
+
+	if removed := p.Invalidate("456"); removed != 0 {
+		t.Errorf("expected products of other services not to be invalidated but got %d removed", removed)
+	}
+
+	if removed := p.Invalidate("123"); removed != 1 {
+		t.Errorf("expected product to be invalidated but got %d removed", removed)
+	}
+
+	if _, err := p.GetProduct(server.URL, request); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if calls := ts.Calls(fake.ProxyConfigEndpoint); calls != 2 {
+		t.Errorf("expected invalidated product to be fetched again but got %d calls", calls)
+	}
+
+	request.AccessToken = "invalid"
+	if _, err := p.GetProduct(server.URL, request); err == nil {
+		t.Errorf("expected error fetching product with invalid access token")
+	}
+}
+
+func TestHandleAuthorizationProduct(t *testing.T) {
+	source := NewStaticConfigSource()
+	if err := source.Load(map[string][]byte{"123.json": []byte(productProxyConfig)}); err != nil {
+		t.Fatalf("unexpected error loading proxy configs - %v", err)
+	}
+
+	params := config.Params{ServiceId: "123", BackendUrl: internalBackend}
+	b, _ := params.Marshal()
+
+	s := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: mockAuthorizer{
+				withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+					expect := api.Metrics{"hits": 1, "books": 1, "book": 2}
+					if !reflect.DeepEqual(request.Transactions[0].Metrics, expect) {
+						t.Errorf("expected metrics %v but got %v", expect, request.Transactions[0].Metrics)
+					}
+				},
+				withAuthResponse: &authorizer.BackendResponse{Authorized: true},
+				t:                t,
+			},
+			ConfigSource: source,
+		},
+	}
+
+	result, _ := s.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Subject: &authorization.SubjectMsg{
+				User: "VALID",
+			},
+			Action: &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/books/42",
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	})
+
+	if result.Status.Code != int32(rpc.OK) || len(result.Status.Details) != 1 {
+		t.Fatalf("expected request to be authorized with backend details but got %v", result.Status)
+	}
+
+	details := &types.Struct{}
+	if err := types.UnmarshalAny(result.Status.Details[0], details); err != nil {
+		t.Fatalf("unexpected error unmarshalling details - %v", err)
+	}
+
+	if details.Fields[BackendSystemNameField].GetStringValue() != "books" || details.Fields[BackendPathField].GetStringValue() != "/books" {
+		t.Errorf("unexpected backend details %v", details)
+	}
+}
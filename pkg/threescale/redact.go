@@ -0,0 +1,137 @@
+package threescale
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	policy "istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// Redaction modes which determine how credentials and tokens are written to logs and errors
+const (
+	// RedactFingerprint replaces secrets with a short, stable fingerprint which allows requests to be correlated
+	RedactFingerprint = "fingerprint"
+	// RedactMask replaces secrets with a fixed placeholder
+	RedactMask = "mask"
+	// RedactNone disables redaction. Not recommended outside of development
+	RedactNone = "none"
+
+	redactedPlaceholder = "[REDACTED]"
+	fingerprintPrefix   = "fp:"
+	// fingerprintBytes of the SHA-256 digest of a secret are used, enough for correlation but not for recovering the secret
+	fingerprintBytes = 4
+)
+
+// secretParams are the names of query parameters and fields which hold a secret
+var secretParams = []string{
+	"access_token",
+	"app_id",
+	"app_key",
+	"client_id",
+	"client_secret",
+	"provider_key",
+	"service_token",
+	"user_key",
+}
+
+var (
+	secretParamRegex = regexp.MustCompile(`(?i)\b(` + strings.Join(secretParams, "|") + `)=([^&\s"',;]+)`)
+	// userInfoRegex matches the password of user info embedded in a URL
+	userInfoRegex = regexp.MustCompile(`(://[^/:@\s]*):[^/@\s]+@`)
+)
+
+// secretProperties are the instance subject properties which hold credentials
+var secretProperties = map[string]bool{
+	AppIDAttributeKey:   true,
+	AppKeyAttributeKey:  true,
+	OIDCAttributeKey:    true,
+	UserKeyAttributeKey: true,
+	CookieAttributeKey:  true,
+}
+
+// Redactor scrubs credentials and tokens from log messages and errors.
+// A nil Redactor redacts using fingerprints.
+type Redactor struct {
+	mode string
+}
+
+// NewRedactor returns a Redactor for the provided mode. Defaults to RedactFingerprint if mode is empty.
+func NewRedactor(mode string) (*Redactor, error) {
+	mode = strings.ToLower(mode)
+	switch mode {
+	case "":
+		mode = RedactFingerprint
+	case RedactFingerprint, RedactMask, RedactNone:
+	default:
+		return nil, fmt.Errorf("unsupported redaction mode %q - must be one of %s, %s or %s", mode, RedactFingerprint, RedactMask, RedactNone)
+	}
+	return &Redactor{mode: mode}, nil
+}
+
+// Secret returns a representation of a secret which is safe to log
+func (r *Redactor) Secret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	switch r.getMode() {
+	case RedactNone:
+		return secret
+	case RedactMask:
+		return redactedPlaceholder
+	default:
+		sum := sha256.Sum256([]byte(secret))
+		return fingerprintPrefix + hex.EncodeToString(sum[:fingerprintBytes])
+	}
+}
+
+// String scrubs secrets passed as query parameters, or as passwords embedded in URLs, from s
+func (r *Redactor) String(s string) string {
+	if r.getMode() == RedactNone {
+		return s
+	}
+
+	s = secretParamRegex.ReplaceAllStringFunc(s, func(match string) string {
+		i := strings.Index(match, "=")
+		value := match[i+1:]
+		// fingerprint the decoded value so that it matches the fingerprint of the same credential elsewhere
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		return match[:i+1] + r.Secret(value)
+	})
+	return userInfoRegex.ReplaceAllString(s, "${1}:"+redactedPlaceholder+"@")
+}
+
+// Instance formats an instance for logging with the credentials it holds redacted
+func (r *Redactor) Instance(instance *authorization.InstanceMsg) string {
+	if instance == nil || instance.Subject == nil || r.getMode() == RedactNone {
+		return fmt.Sprintf("%+v", instance)
+	}
+
+	subject := *instance.Subject
+	subject.User = r.Secret(subject.User)
+	subject.Properties = make(map[string]*policy.Value, len(instance.Subject.Properties))
+	for key, value := range instance.Subject.Properties {
+		if secretProperties[key] {
+			value = &policy.Value{Value: &policy.Value_StringValue{StringValue: r.Secret(value.GetStringValue())}}
+		}
+		subject.Properties[key] = value
+	}
+
+	redacted := *instance
+	redacted.Subject = &subject
+	return fmt.Sprintf("%+v", &redacted)
+}
+
+func (r *Redactor) getMode() string {
+	if r == nil {
+		return RedactFingerprint
+	}
+	return r.mode
+}
@@ -0,0 +1,242 @@
+package threescale
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/3scale/3scale-porta-go-client/client"
+
+	"istio.io/istio/pkg/log"
+)
+
+// snapshotVersion is the version of the format of snapshots, snapshots of any other version are ignored
+const snapshotVersion = 2
+
+// snapshotFileMode restricts snapshots to the adapter, as they hold proxy configs
+const snapshotFileMode os.FileMode = 0600
+
+// snapshotWriteDelay is how long changes are collected for before the snapshot is written
+var snapshotWriteDelay = time.Second
+
+type snapshot struct {
+	Version int             `json:"version"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+// snapshotEntry is a cached proxy config. The access token it was fetched with is only held as a digest.
+type snapshotEntry struct {
+	SystemURL         string             `json:"system_url"`
+	AccessTokenSHA256 string             `json:"access_token_sha256"`
+	ServiceID         string             `json:"service_id"`
+	Environment       string             `json:"environment"`
+	FetchedAt         time.Time          `json:"fetched_at"`
+	Config            client.ProxyConfig `json:"config"`
+}
+
+// UseSnapshot restores the proxy configurations persisted to the snapshot at path, if any, and persists the cached
+// configurations to it whenever they change from then on. Restored configurations are served until they are older
+// than maxStaleness, and are fetched again in the background on their first use.
+// Changes are written in the background, at most once every second and on Shutdown. Access tokens are not written,
+// but proxy configs may hold secrets, so the snapshot is only readable by its owner and a snapshot readable by others
+// is not restored. It returns the number of configurations restored. If the snapshot cannot be restored, it is
+// overwritten on the next change.
+func (c *CachingAuthorizer) UseSnapshot(path string, maxStaleness time.Duration) (int, error) {
+	c.snapshotMutex.Lock()
+	if c.snapshotChanged == nil {
+		c.snapshotChanged = make(chan struct{}, 1)
+		c.snapshotStop = make(chan struct{})
+		c.snapshotDone = make(chan struct{})
+		go c.writeSnapshots(path, c.snapshotChanged, c.snapshotStop, c.snapshotDone)
+	}
+	c.snapshotMutex.Unlock()
+
+	c.mutex.Lock()
+	c.maxStaleness = maxStaleness
+	c.mutex.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error reading snapshot - %v", err)
+	}
+
+	if info.Mode().Perm()&^snapshotFileMode != 0 {
+		return 0, fmt.Errorf("snapshot %s must only be accessible by its owner but has mode %v", path, info.Mode().Perm())
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading snapshot - %v", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("error parsing snapshot - %v", err)
+	}
+
+	if snap.Version != snapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	now := time.Now()
+	restored := 0
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, entry := range snap.Entries {
+		if now.Sub(entry.FetchedAt) >= maxStaleness {
+			continue
+		}
+
+		key := pinnedKey{
+			systemURL:   entry.SystemURL,
+			accessToken: entry.AccessTokenSHA256,
+			serviceID:   entry.ServiceID,
+			environment: entry.Environment,
+		}
+		if _, ok := c.cache[key]; ok {
+			continue
+		}
+
+		if c.maxSize > 0 && len(c.cache) >= c.maxSize {
+			break
+		}
+		c.cache[key] = &cachedConfig{conf: entry.Config, fetched: entry.FetchedAt, restored: true}
+		restored++
+	}
+	return restored, nil
+}
+
+// Shutdown writes pending changes to the snapshot, if enabled, and shuts down the wrapped Authorizer
+func (c *CachingAuthorizer) Shutdown() {
+	c.snapshotMutex.Lock()
+	stop, done := c.snapshotStop, c.snapshotDone
+	c.snapshotChanged, c.snapshotStop, c.snapshotDone = nil, nil, nil
+	c.snapshotMutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	c.Authorizer.Shutdown()
+}
+
+// scheduleSnapshot schedules a write of the snapshot, if enabled, without waiting for it
+func (c *CachingAuthorizer) scheduleSnapshot() {
+	c.snapshotMutex.Lock()
+	changed := c.snapshotChanged
+	c.snapshotMutex.Unlock()
+
+	if changed == nil {
+		return
+	}
+
+	select {
+	case changed <- struct{}{}:
+	default:
+		// a write is already scheduled
+	}
+}
+
+// writeSnapshots writes the snapshot once changes have been collected for the snapshotWriteDelay, until stopped.
+// Pending changes are written when stopped.
+func (c *CachingAuthorizer) writeSnapshots(path string, changed <-chan struct{}, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-changed:
+		case <-stop:
+			select {
+			case <-changed:
+				c.writeSnapshot(path)
+			default:
+			}
+			return
+		}
+
+		select {
+		case <-time.After(snapshotWriteDelay):
+		case <-stop:
+		}
+		c.writeSnapshot(path)
+	}
+}
+
+// writeSnapshot persists the cached configurations to the snapshot.
+// The snapshot is replaced atomically so that a partially written snapshot is never restored.
+func (c *CachingAuthorizer) writeSnapshot(path string) {
+	snap := snapshot{Version: snapshotVersion}
+	c.mutex.RLock()
+	for key, cached := range c.cache {
+		snap.Entries = append(snap.Entries, snapshotEntry{
+			SystemURL:         key.systemURL,
+			AccessTokenSHA256: key.accessToken,
+			ServiceID:         key.serviceID,
+			Environment:       key.environment,
+			FetchedAt:         cached.fetched,
+			Config:            cached.conf,
+		})
+	}
+	c.mutex.RUnlock()
+
+	if err := writeFileAtomic(path, snap); err != nil {
+		log.Warnf("%s", c.Redactor.String(fmt.Sprintf("failed to write snapshot of proxy configs - %v", err)))
+	}
+}
+
+// tokenDigest returns the hex encoded SHA-256 digest of an access token, or an empty string if there is none
+func tokenDigest(token string) string {
+	if token == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic writes v as JSON to a temporary file only accessible by its owner and renames it to path
+func writeFileAtomic(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot - %v", err)
+	}
+
+	// the temporary file is created in the same directory so that it can be renamed
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("error creating snapshot - %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(snapshotFileMode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error restricting snapshot - %v", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing snapshot - %v", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing snapshot - %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing snapshot - %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing snapshot - %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package threescale
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+)
+
+func TestCachingAuthorizerSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "threescale-snapshot")
+	if err != nil {
+		t.Fatalf("error creating temp dir - %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+
+	// changes are only written on shutdown
+	defer func(delay time.Duration) { snapshotWriteDelay = delay }(snapshotWriteDelay)
+	snapshotWriteDelay = time.Hour
+
+	conf := proxyConfigWithRules(2)
+	conf.Version = 3
+	authz := &countingAuthorizer{mockAuthorizer: mockAuthorizer{withConfig: conf}, calls: make(map[string]int)}
+	c := NewCachingAuthorizer(authz, time.Minute, time.Minute, 0)
+	if restored, err := c.UseSnapshot(path, time.Hour); err != nil || restored != 0 {
+		t.Fatalf("expected missing snapshot to restore nothing but got %d - %v", restored, err)
+	}
+
+	for _, serviceID := range []string{"123", "456"} {
+		c.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: serviceID, AccessToken: "secret-token"})
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected snapshot to be written in the background - %v", err)
+	}
+
+	c.Shutdown()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected snapshot to be written on shutdown - %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected snapshot to only be accessible by its owner but got mode %v", info.Mode().Perm())
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading snapshot - %v", err)
+	}
+	if bytes.Contains(data, []byte("secret-token")) {
+		t.Errorf("expected access token not to be written to snapshot")
+	}
+
+	inputs := []struct {
+		name          string
+		maxStaleness  time.Duration
+		expectRestore int
+		expectErr     bool
+	}{
+		{
+			name:          "Test stale config is served while 3scale is down",
+			maxStaleness:  time.Hour,
+			expectRestore: 2,
+		},
+		{
+			name:          "Test config older than max staleness is not restored",
+			maxStaleness:  time.Nanosecond,
+			expectRestore: 0,
+			expectErr:     true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			down := &countingAuthorizer{calls: make(map[string]int), err: errors.New("unavailable")}
+			restarted := NewCachingAuthorizer(down, time.Minute, time.Minute, 0)
+			defer restarted.Shutdown()
+
+			restored, err := restarted.UseSnapshot(path, input.maxStaleness)
+			if err != nil {
+				t.Fatalf("unexpected error restoring snapshot - %v", err)
+			}
+			if restored != input.expectRestore {
+				t.Errorf("expected %d configs to be restored but got %d", input.expectRestore, restored)
+			}
+
+			got, err := restarted.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: "123", AccessToken: "secret-token"})
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error when config cannot be fetched")
+				}
+				return
+			}
+
+			if err != nil || got.Version != 3 || len(got.Content.Proxy.ProxyRules) != 2 {
+				t.Errorf("expected restored config to be served but got %+v - %v", got, err)
+			}
+
+			if services := restarted.Service("123"); len(services) != 1 || !services[0].Restored {
+				t.Errorf("expected restored config to be described as restored but got %+v", services)
+			}
+		})
+	}
+
+	t.Run("Test restored config is refreshed", func(t *testing.T) {
+		restarted := NewCachingAuthorizer(authz, time.Minute, time.Minute, 0)
+		defer restarted.Shutdown()
+		if _, err := restarted.UseSnapshot(path, time.Hour); err != nil {
+			t.Fatalf("unexpected error restoring snapshot - %v", err)
+		}
+
+		calls := authz.callsFor("123")
+		restarted.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: "123", AccessToken: "secret-token"})
+		for i := 0; i < 100 && authz.callsFor("123") == calls; i++ {
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		if authz.callsFor("123") != calls+1 {
+			t.Errorf("expected restored config to be fetched in the background")
+		}
+	})
+
+	t.Run("Test other access token is not served restored config", func(t *testing.T) {
+		down := &countingAuthorizer{calls: make(map[string]int), err: errors.New("unavailable")}
+		restarted := NewCachingAuthorizer(down, time.Minute, time.Minute, 0)
+		defer restarted.Shutdown()
+		if _, err := restarted.UseSnapshot(path, time.Hour); err != nil {
+			t.Fatalf("unexpected error restoring snapshot - %v", err)
+		}
+
+		if _, err := restarted.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: "123", AccessToken: "other"}); err == nil {
+			t.Errorf("expected restored config not to be served for another access token")
+		}
+	})
+
+	t.Run("Test fail - snapshot readable by others", func(t *testing.T) {
+		readable := filepath.Join(dir, "readable.json")
+		if err := ioutil.WriteFile(readable, data, 0644); err != nil {
+			t.Fatalf("error writing snapshot - %v", err)
+		}
+		// the umask may have restricted the mode of the file
+		if err := os.Chmod(readable, 0644); err != nil {
+			t.Fatalf("error changing mode of snapshot - %v", err)
+		}
+
+		restarted := NewCachingAuthorizer(authz, time.Minute, time.Minute, 0)
+		if restored, err := restarted.UseSnapshot(readable, time.Hour); err == nil || restored != 0 {
+			t.Errorf("expected snapshot readable by others not to be restored but got %d", restored)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package threescale
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// ConfigSource provides the proxy configuration of 3scale services.
+// Every Authorizer is a ConfigSource which fetches the configuration from 3scale system.
+type ConfigSource interface {
+	GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error)
+}
+
+// StaticConfigSource serves proxy configurations held in memory, in place of 3scale system.
+// The system URL and access token of requests are ignored. It is safe for concurrent use.
+type StaticConfigSource struct {
+	configs  map[staticKey]client.ProxyConfig
+	products map[staticKey]Product
+	mutex    sync.RWMutex
+}
+
+type staticKey struct {
+	serviceID   string
+	environment string
+}
+
+// NewStaticConfigSource returns a StaticConfigSource serving no configurations until loaded
+func NewStaticConfigSource() *StaticConfigSource {
+	return &StaticConfigSource{
+		configs:  make(map[staticKey]client.ProxyConfig),
+		products: make(map[staticKey]Product),
+	}
+}
+
+// GetSystemConfiguration returns the loaded proxy configuration for the service and environment of the request
+func (s *StaticConfigSource) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	key := staticKeyFor(request)
+
+	s.mutex.RLock()
+	conf, ok := s.configs[key]
+	s.mutex.RUnlock()
+
+	if !ok {
+		return client.ProxyConfig{}, fmt.Errorf("no proxy config loaded for service %s in environment %s", key.serviceID, key.environment)
+	}
+	return conf, nil
+}
+
+// GetProduct returns the backends of the product described by the loaded proxy configuration of the request
+func (s *StaticConfigSource) GetProduct(systemURL string, request authorizer.SystemRequest) (Product, error) {
+	key := staticKeyFor(request)
+
+	s.mutex.RLock()
+	product, ok := s.products[key]
+	s.mutex.RUnlock()
+
+	if !ok {
+		return Product{}, fmt.Errorf("no proxy config loaded for service %s in environment %s", key.serviceID, key.environment)
+	}
+	return product, nil
+}
+
+func staticKeyFor(request authorizer.SystemRequest) staticKey {
+	environment := request.Environment
+	if environment == "" {
+		environment = EnvironmentProduction
+	}
+	return staticKey{serviceID: request.ServiceID, environment: environment}
+}
+
+// ServiceIDs returns the sorted IDs of the services with a proxy configuration loaded for the environment
+func (s *StaticConfigSource) ServiceIDs(environment string) []string {
+	if environment == "" {
+		environment = EnvironmentProduction
+	}
+
+	s.mutex.RLock()
+	var serviceIDs []string
+	for key := range s.configs {
+		if key.environment == environment {
+			serviceIDs = append(serviceIDs, key.serviceID)
+		}
+	}
+	s.mutex.RUnlock()
+
+	sort.Strings(serviceIDs)
+	return serviceIDs
+}
+
+// Load replaces the served configurations with the proxy configs in files, keyed by file name.
+// Files are named after the service ID, optionally followed by a dot and any suffix, for example 123.json or 123.staging.json,
+// and hold a proxy config in the JSON format exported by 3scale. The environment is read from the proxy config.
+// If any file is invalid, an error is returned and the served configurations are left unchanged.
+func (s *StaticConfigSource) Load(files map[string][]byte) error {
+	configs := make(map[staticKey]client.ProxyConfig, len(files))
+	products := make(map[staticKey]Product, len(files))
+
+	var invalid []string
+	for name, data := range files {
+		serviceID := strings.SplitN(filepath.Base(name), ".", 2)[0]
+		conf, err := ParseProxyConfig(data)
+		if serviceID == "" || err != nil {
+			invalid = append(invalid, name)
+			continue
+		}
+
+		product, err := ParseProduct(data)
+		if err != nil {
+			invalid = append(invalid, name)
+			continue
+		}
+
+		environment := conf.Environment
+		if environment == "" {
+			environment = EnvironmentProduction
+		}
+
+		key := staticKey{serviceID: serviceID, environment: environment}
+		if _, ok := configs[key]; ok {
+			invalid = append(invalid, name)
+			continue
+		}
+		configs[key] = conf
+		products[key] = product
+	}
+
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("proxy configs must be valid and unique per service and environment, invalid entries: %s", strings.Join(invalid, ", "))
+	}
+
+	s.mutex.Lock()
+	s.configs = configs
+	s.products = products
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// LoadDir replaces the served configurations with the proxy configs of the .json files in dir. See Load.
+func (s *StaticConfigSource) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("error listing proxy configs in %s - %v", dir, err)
+	}
+
+	files := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading proxy config %s - %v", path, err)
+		}
+		files[filepath.Base(path)] = data
+	}
+
+	return s.Load(files)
+}
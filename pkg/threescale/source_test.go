@@ -0,0 +1,179 @@
+package threescale
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+const staticProxyConfig = `{
+  "proxy_config": {
+    "environment": "%s",
+    "content": {
+      "backend_version": "1",
+      "proxy": {
+        "backend": {"endpoint": "https://su1.3scale.net"},
+        "proxy_rules": [{"http_method": "GET", "pattern": "/", "metric_system_name": "hits", "delta": 1}]
+      }
+    }
+  }
+}`
+
+func proxyConfigFile(environment string) []byte {
+	return []byte(strings.Replace(staticProxyConfig, "%s", environment, 1))
+}
+
+func TestStaticConfigSource(t *testing.T) {
+	source := NewStaticConfigSource()
+
+	err := source.Load(map[string][]byte{
+		"123.json":         proxyConfigFile(EnvironmentProduction),
+		"123.staging.json": proxyConfigFile(EnvironmentStaging),
+		"456.json":         proxyConfigFile(""),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error loading proxy configs - %v", err)
+	}
+
+	inputs := []struct {
+		name      string
+		request   authorizer.SystemRequest
+		expectErr bool
+	}{
+		{
+			name:    "Test production config",
+			request: authorizer.SystemRequest{ServiceID: "123", Environment: EnvironmentProduction},
+		},
+		{
+			name:    "Test staging config",
+			request: authorizer.SystemRequest{ServiceID: "123", Environment: EnvironmentStaging},
+		},
+		{
+			name:    "Test environment defaults to production",
+			request: authorizer.SystemRequest{ServiceID: "456"},
+		},
+		{
+			name:      "Test fail - unknown service",
+			request:   authorizer.SystemRequest{ServiceID: "789", Environment: EnvironmentProduction},
+			expectErr: true,
+		},
+		{
+			name:      "Test fail - environment not loaded",
+			request:   authorizer.SystemRequest{ServiceID: "456", Environment: EnvironmentStaging},
+			expectErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			conf, err := source.GetSystemConfiguration("", input.request)
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error for request %v", input.request)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if conf.Content.Proxy.Backend.Endpoint != "https://su1.3scale.net" || len(conf.Content.Proxy.ProxyRules) != 1 {
+				t.Errorf("unexpected proxy config %+v", conf)
+			}
+		})
+	}
+}
+
+func TestStaticConfigSourceLoadInvalid(t *testing.T) {
+	source := NewStaticConfigSource()
+	if err := source.Load(map[string][]byte{"123.json": proxyConfigFile(EnvironmentProduction)}); err != nil {
+		t.Fatalf("unexpected error loading proxy configs - %v", err)
+	}
+
+	err := source.Load(map[string][]byte{
+		"456.json":        proxyConfigFile(EnvironmentProduction),
+		"789.json":        []byte("not json"),
+		"456.backup.json": proxyConfigFile(EnvironmentProduction),
+	})
+	if err == nil || !strings.Contains(err.Error(), "789.json") || !strings.Contains(err.Error(), "456") {
+		t.Errorf("expected invalid and duplicate entries to be reported but got %v", err)
+	}
+
+	if _, err := source.GetSystemConfiguration("", authorizer.SystemRequest{ServiceID: "123"}); err != nil {
+		t.Errorf("expected previously loaded configs to be served after failed load - %v", err)
+	}
+}
+
+func TestStaticConfigSourceLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "threescale-configs")
+	if err != nil {
+		t.Fatalf("error creating temporary directory - %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "123.json"), proxyConfigFile(EnvironmentProduction), 0644); err != nil {
+		t.Fatalf("error writing proxy config - %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0644); err != nil {
+		t.Fatalf("error writing file - %v", err)
+	}
+
+	source := NewStaticConfigSource()
+	if err := source.LoadDir(dir); err != nil {
+		t.Fatalf("unexpected error loading proxy configs - %v", err)
+	}
+
+	if _, err := source.GetSystemConfiguration("", authorizer.SystemRequest{ServiceID: "123"}); err != nil {
+		t.Errorf("expected proxy config to be loaded from directory - %v", err)
+	}
+}
+
+func TestHandleAuthorizationStaticConfigSource(t *testing.T) {
+	source := NewStaticConfigSource()
+	if err := source.Load(map[string][]byte{"123.json": proxyConfigFile(EnvironmentProduction)}); err != nil {
+		t.Fatalf("unexpected error loading proxy configs - %v", err)
+	}
+
+	params := config.Params{ServiceId: "123", BackendUrl: internalBackend}
+	b, _ := params.Marshal()
+
+	s := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: mockAuthorizer{
+				withSystemErr:    errSystemURL,
+				withAuthResponse: &authorizer.BackendResponse{},
+			},
+			ConfigSource: source,
+		},
+	}
+
+	result, _ := s.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Subject: &authorization.SubjectMsg{
+				User: "VALID",
+			},
+			Action: &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/",
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	})
+
+	if result.Status.Code != int32(rpc.OK) {
+		t.Errorf("expected request to be authorized with static config but got %v", result.Status)
+	}
+}
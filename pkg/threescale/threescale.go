@@ -8,12 +8,15 @@ package threescale
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,11 +26,13 @@ import (
 	"github.com/3scale/3scale-istio-adapter/config"
 	system "github.com/3scale/3scale-porta-go-client/client"
 	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 
 	"istio.io/api/mixer/adapter/model/v1beta1"
+	policy "istio.io/api/policy/v1beta1"
 	"istio.io/istio/mixer/pkg/status"
 	"istio.io/istio/mixer/template/authorization"
 	"istio.io/istio/pkg/log"
@@ -38,20 +43,59 @@ var _ authorization.HandleAuthorizationServiceServer = &Threescale{}
 
 const (
 	// consts reflect key values in instance config - exported as required for yaml generation by cli
-	AppIDAttributeKey  = "app_id"
-	AppKeyAttributeKey = "app_key"
-	OIDCAttributeKey   = "client_id"
+	AppIDAttributeKey   = "app_id"
+	AppKeyAttributeKey  = "app_key"
+	OIDCAttributeKey    = "client_id"
+	UserKeyAttributeKey = "user_key"
+
+	// CookieAttributeKey holds the raw cookie header for credentials which are configured to be read from cookies
+	CookieAttributeKey = "cookie"
+	// CookieSourceSuffix is appended to a credential key to name the property holding the cookie name for that credential
+	CookieSourceSuffix = "_cookie"
+
+	// UsageAttributePrefix is prepended to the system name of a metric to name the property holding a delta
+	// which should be reported against that metric, in addition to the deltas of the matching mapping rules
+	UsageAttributePrefix = "usage."
+
+	// RequestIDAttributeKey holds an identifier of the request which is returned in the details of a denial
+	RequestIDAttributeKey = "request_id"
+
+	// HostAttributeKey holds the host of the request, used to resolve the service when no service ID is provided
+	HostAttributeKey = "host"
 
 	// oauthTypeIdentifier refers to the name by which 3scale config described oauth OpenID connect authentication pattern
 	openIDTypeIdentifier = "oauth"
-
-	environment = "production"
+	// backend versions by which 3scale config describes the API key and application ID authentication patterns
+	apiKeyTypeIdentifier = "1"
+	appIDTypeIdentifier  = "2"
+
+	// defaultMetric is defined for every 3scale service
+	defaultMetric = "hits"
+
+	// values accepted by the environment handler param
+	EnvironmentProduction = "production"
+	EnvironmentStaging    = "staging"
+
+	// values accepted by the backend_cache and failure_policy handler params
+	BackendCacheEnabled  = "enabled"
+	BackendCacheDisabled = "disabled"
+	FailurePolicyOpen    = "open"
+	FailurePolicyClosed  = "closed"
+
+	// values accepted by the credential_precedence handler param
+	CredentialPrecedenceUserKey = UserKeyAttributeKey
+	CredentialPrecedenceAppID   = AppIDAttributeKey
+
+	// values accepted by the mode handler param
+	ModeEnforce    = "enforce"
+	ModeShadow     = "shadow"
+	ModeReportOnly = "report-only"
 )
 
 // HandleAuthorization takes care of the authorization request from mixer
 func (s *Threescale) HandleAuthorization(ctx context.Context, r *authorization.HandleAuthorizationRequest) (*v1beta1.CheckResult, error) {
 
-	log.Debugf("Got instance %+v", r.Instance)
+	log.Debugf("Got instance %s", s.conf.Redactor.Instance(r.Instance))
 	result := &v1beta1.CheckResult{
 		// Caching at Mixer/Envoy layer needs to be disabled currently since we would miss reporting
 		// cached requests. We can determine caching values going forward by splitting the check
@@ -78,13 +122,71 @@ func (s *Threescale) HandleAuthorization(ctx context.Context, r *authorization.H
 		return result, nil
 	}
 
-	proxyConf, err := s.conf.Authorizer.GetSystemConfiguration(cfg.SystemUrl, s.systemRequestFromHandlerConfig(cfg))
+	// the access list is applied regardless of the mode of the handler, since it is used to respond to incidents
+	if s.conf.AccessList != nil {
+		if entry, ok := s.conf.AccessList.decide(credentialsFromInstance(r.Instance)); ok {
+			return s.accessListResult(entry, result, cfg, requestIDFromInstance(r.Instance)), nil
+		}
+	}
+
+	mode := modeFromConfig(cfg)
+	enforce := mode == ModeEnforce || sampleEnforcement(cfg.EnforcePercentage)
+
+	result, err = s.authorize(ctx, r, cfg, result, enforce || mode == ModeReportOnly)
+	if enforce {
+		return result, err
+	}
+	return s.shadowResult(result, err, mode, cfg.ServiceId), nil
+}
+
+// authorize authorizes the request against 3scale. Unless reportUsage is set, the request is authorized
+// with zero deltas for the metrics it matches, so that 3scale applies its checks without recording usage.
+// Calls to 3scale are given up on once ctx is done.
+func (s *Threescale) authorize(ctx context.Context, r *authorization.HandleAuthorizationRequest, cfg *config.Params, result *v1beta1.CheckResult, reportUsage bool) (*v1beta1.CheckResult, error) {
+	authz, err := s.authorizerFor(cfg)
+	if err != nil {
+		result.Status, err = rpcStatusErrorHandler(s.conf.Redactor, "error selecting authorizer", status.WithInternal, err)
+		return result, err
+	}
+
+	var source ConfigSource = authz
+	if s.conf.ConfigSource != nil {
+		source = s.conf.ConfigSource
+	}
+
+	if cfg.ServiceId == "" {
+		cfg.ServiceId, err = s.serviceIDForHost(hostFromInstance(r.Instance), source, cfg)
+		if err == errHostNotFound || err == errHostAmbiguous {
+			result.Status = status.WithNotFound(err.Error())
+			// intentionally return nil as error here as failed rpc.Status is sufficient
+			return result, nil
+		}
+
+		if err != nil {
+			result.Status, err = rpcStatusErrorHandler(s.conf.Redactor, "error resolving service from request host", systemErrorToRpcStatus(err, statusOverridesFor(cfg)), err)
+			return result, err
+		}
+	}
+
+	proxyConf, err := configFromSource(ctx, source, cfg.SystemUrl, s.systemRequestFromHandlerConfig(cfg))
 	if err != nil {
-		result.Status, err = rpcStatusErrorHandler("error fetching config from 3scale", systemErrorToRpcStatus(err), err)
+		result.Status, err = rpcStatusErrorHandler(s.conf.Redactor, "error fetching config from 3scale", contextErrorToRpcStatus(ctx, systemErrorToRpcStatus(err, statusOverridesFor(cfg))), err)
 		return result, err
 	}
 
-	backendReq := s.requestFromConfig(proxyConf, *r.Instance, *cfg)
+	product, err := productFromSource(ctx, source, cfg.SystemUrl, s.systemRequestFromHandlerConfig(cfg))
+	if err != nil {
+		result.Status, err = rpcStatusErrorHandler(s.conf.Redactor, "error fetching product from 3scale", contextErrorToRpcStatus(ctx, systemErrorToRpcStatus(err, statusOverridesFor(cfg))), err)
+		return result, err
+	}
+
+	backendReq, err := s.requestFromConfig(proxyConf, product, *r.Instance, *cfg)
+	if err != nil {
+		result.Status = requestErrorToRpcStatus(err)(err.Error())
+		// intentionally return nil as error here as failed rpc.Status is sufficient
+		return result, nil
+	}
+
 	rpcFN, err := s.validateBackendRequest(backendReq)
 	if err != nil {
 		result.Status = rpcFN(err.Error())
@@ -92,6 +194,10 @@ func (s *Threescale) HandleAuthorization(ctx context.Context, r *authorization.H
 		return result, nil
 	}
 
+	if !reportUsage {
+		withoutUsage(backendReq)
+	}
+
 	if cfg.BackendUrl == "" {
 		//if not set in the handler, take it from 3scale config
 		cfg.BackendUrl = proxyConf.Content.Proxy.Backend.Endpoint
@@ -101,12 +207,30 @@ func (s *Threescale) HandleAuthorization(ctx context.Context, r *authorization.H
 
 	if proxyConf.Content.BackendVersion == openIDTypeIdentifier {
 	        log.Debugf("HandleAuthorization: backend_version is %#v, calling OauthAuthRep\n", proxyConf.Content.BackendVersion)
-		authResult, err = s.conf.Authorizer.OauthAuthRep(cfg.BackendUrl, backendReq)
+		authResult, err = WithContext(authz).OauthAuthRepContext(ctx, cfg.BackendUrl, backendReq)
 	} else {
 	        log.Debugf("HandleAuthorization: backend_version is %#v, calling AuthRep\n", proxyConf.Content.BackendVersion)
-		authResult, err = s.conf.Authorizer.AuthRep(cfg.BackendUrl, backendReq)
+		authResult, err = WithContext(authz).AuthRepContext(ctx, cfg.BackendUrl, backendReq)
 	}
-	return s.convertAuthResponse(authResult, result, err)
+
+	if err != nil && ctx.Err() != nil {
+		result.Status, _ = rpcStatusErrorHandler(s.conf.Redactor, "request authorization abandoned", contextErrorToRpcStatus(ctx, status.WithUnknown), err)
+		// intentionally return nil as error here as failed rpc.Status is sufficient
+		return result, nil
+	}
+
+	result, err = s.convertAuthResponse(authResult, result, err, cfg, requestIDFromInstance(r.Instance))
+
+	// the backend serving an authorized request to a product is described in the status details
+	if backend := product.BackendFor(r.Instance.Action.Path); backend != nil && result.Status.Code == int32(rpc.OK) {
+		details, detailsErr := backendDetails(backend)
+		if detailsErr != nil {
+			log.Errorf("error describing backend - %v", detailsErr)
+		} else {
+			result.Status.Details = append(result.Status.Details, details)
+		}
+	}
+	return result, err
 }
 
 // parseConfigParams - parses the configuration passed to the adapter from mixer
@@ -132,15 +256,19 @@ func (s *Threescale) parseConfigParams(r *authorization.HandleAuthorizationReque
 
 func (s *Threescale) validateRequestAndConfigParams(r *authorization.HandleAuthorizationRequest, config *config.Params) error {
 	var errMsgs []string
-	if config.AccessToken == "" {
-		errMsgs = append(errMsgs, errAccessToken.Error())
-	}
+	// a static config source replaces 3scale system, so there is no need to connect to it
+	if s.conf.ConfigSource == nil {
+		if config.AccessToken == "" {
+			errMsgs = append(errMsgs, errAccessToken.Error())
+		}
 
-	if config.SystemUrl == "" {
-		errMsgs = append(errMsgs, errSystemURL.Error())
+		if config.SystemUrl == "" {
+			errMsgs = append(errMsgs, errSystemURL.Error())
+		}
 	}
 
-	if config.ServiceId == "" {
+	// the service is resolved from the request host when no service ID is provided
+	if config.ServiceId == "" && hostFromInstance(r.Instance) == "" {
 		errMsgs = append(errMsgs, errServiceID.Error())
 	}
 
@@ -148,6 +276,56 @@ func (s *Threescale) validateRequestAndConfigParams(r *authorization.HandleAutho
 		errMsgs = append(errMsgs, errRequestPath.Error())
 	}
 
+	if config.CacheTtlSeconds < 0 || config.CacheRefreshSeconds < 0 {
+		errMsgs = append(errMsgs, errCacheSeconds.Error())
+	}
+
+	switch strings.ToLower(config.BackendCache) {
+	case "", BackendCacheEnabled, BackendCacheDisabled:
+	default:
+		errMsgs = append(errMsgs, errBackendCache.Error())
+	}
+
+	switch strings.ToLower(config.FailurePolicy) {
+	case "", FailurePolicyOpen, FailurePolicyClosed:
+	default:
+		errMsgs = append(errMsgs, errFailurePolicy.Error())
+	}
+
+	switch config.CredentialPrecedence {
+	case "", CredentialPrecedenceUserKey, CredentialPrecedenceAppID:
+	default:
+		errMsgs = append(errMsgs, errCredentialPrecedence.Error())
+	}
+
+	switch strings.ToLower(config.Environment) {
+	case "", EnvironmentProduction, EnvironmentStaging:
+	default:
+		errMsgs = append(errMsgs, errEnvironment.Error())
+	}
+
+	if config.ProxyConfigVersion < 0 {
+		errMsgs = append(errMsgs, errProxyConfigVersion.Error())
+	}
+
+	switch strings.ToLower(config.Mode) {
+	case "", ModeEnforce, ModeShadow, ModeReportOnly:
+	default:
+		errMsgs = append(errMsgs, errMode.Error())
+	}
+
+	if config.EnforcePercentage < 0 || config.EnforcePercentage > 100 {
+		errMsgs = append(errMsgs, errEnforcePercentage.Error())
+	}
+
+	if _, err := parseStatusOverrides(config.StatusOverrides); err != nil {
+		errMsgs = append(errMsgs, err.Error())
+	}
+
+	if err := validateDenialTemplates(config); err != nil {
+		errMsgs = append(errMsgs, err.Error())
+	}
+
 	if len(errMsgs) > 0 {
 		var errMsg string
 		for _, msg := range errMsgs {
@@ -158,15 +336,76 @@ func (s *Threescale) validateRequestAndConfigParams(r *authorization.HandleAutho
 	return nil
 }
 
+// authorizerFor returns the Authorizer which applies the overrides set in the handler config.
+// Authorizers are created on first use and shared by all handlers which set the same overrides.
+func (s *Threescale) authorizerFor(cfg *config.Params) (Authorizer, error) {
+	opts := AuthorizerOptionsFromConfig(cfg)
+	if opts == (AuthorizerOptions{}) {
+		return s.conf.Authorizer, nil
+	}
+
+	if s.conf.AuthorizerFactory == nil {
+		return nil, errNoAuthorizerFactory
+	}
+
+	s.authorizersMutex.Lock()
+	defer s.authorizersMutex.Unlock()
+
+	if authz, ok := s.authorizers[opts]; ok {
+		return authz, nil
+	}
+
+	authz, err := s.conf.AuthorizerFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.authorizers == nil {
+		s.authorizers = make(map[AuthorizerOptions]Authorizer)
+	}
+	s.authorizers[opts] = authz
+	log.Infof("created authorizer for handler overrides %+v", opts)
+
+	return authz, nil
+}
+
+// AuthorizerOptionsFromConfig returns the overrides set in a handler config, which expects the config to have been validated
+func AuthorizerOptionsFromConfig(cfg *config.Params) AuthorizerOptions {
+	opts := AuthorizerOptions{
+		SystemCacheTTL:             time.Duration(cfg.CacheTtlSeconds) * time.Second,
+		SystemCacheRefreshInterval: time.Duration(cfg.CacheRefreshSeconds) * time.Second,
+		BackendCache:               strings.ToLower(cfg.BackendCache),
+		FailurePolicy:              strings.ToLower(cfg.FailurePolicy),
+		ProxyConfigVersion:         cfg.ProxyConfigVersion,
+	}
+
+	// production is the default environment so is left unset to share the default authorizer
+	if env := environmentFromConfig(cfg); env != EnvironmentProduction {
+		opts.Environment = env
+	}
+	return opts
+}
+
 func (s *Threescale) systemRequestFromHandlerConfig(cfg *config.Params) authorizer.SystemRequest {
 	return authorizer.SystemRequest{
 		AccessToken: cfg.AccessToken,
 		ServiceID:   cfg.ServiceId,
-		Environment: environment,
+		Environment: environmentFromConfig(cfg),
+	}
+}
+
+// environmentFromConfig expects the config to have been validated
+func environmentFromConfig(cfg *config.Params) string {
+	if cfg.Environment == "" {
+		return EnvironmentProduction
 	}
+	return strings.ToLower(cfg.Environment)
 }
 
-func (s *Threescale) requestFromConfig(systemConf system.ProxyConfig, istioConf authorization.InstanceMsg, cfg config.Params) authorizer.BackendRequest {
+// requestFromConfig builds the request to 3scale backend from the service configuration and the instance.
+// An error is returned if the instance provides usage which cannot be reported.
+// Requests to a product are matched against the mapping rules of the product and of the backend serving the path.
+func (s *Threescale) requestFromConfig(systemConf system.ProxyConfig, product Product, istioConf authorization.InstanceMsg, cfg config.Params) (authorizer.BackendRequest, error) {
 	var (
 		// Application ID/OpenID Connect authentication pattern - App Key is optional when using this authn
 		appID, appKey string
@@ -187,8 +426,40 @@ func (s *Threescale) requestFromConfig(systemConf system.ProxyConfig, istioConf
 		appID = istioConf.Subject.Properties[appIdentifierKey].GetStringValue()
 		appKey = istioConf.Subject.Properties[AppKeyAttributeKey].GetStringValue()
 		userKey = istioConf.Subject.User
+
+		// cookies are evaluated after all other sources
+		if appID == "" {
+			appID = credentialFromCookie(istioConf.Subject, appIdentifierKey)
+		}
+		if appKey == "" {
+			appKey = credentialFromCookie(istioConf.Subject, AppKeyAttributeKey)
+		}
+		if userKey == "" {
+			userKey = credentialFromCookie(istioConf.Subject, UserKeyAttributeKey)
+		}
+	}
+	params, err := selectCredentials(systemConf.Content.BackendVersion, cfg.CredentialPrecedence, authorizer.BackendParams{
+		AppID:   appID,
+		AppKey:  appKey,
+		UserKey: userKey,
+	})
+	if err != nil {
+		return authorizer.BackendRequest{}, err
+	}
+
+	var metrics api.Metrics
+	if product.IsProduct() {
+		metrics = generateProductMetrics(istioConf.Action.Path, istioConf.Action.Method, systemConf, product)
+	} else {
+		metrics = generateMetrics(istioConf.Action.Path, istioConf.Action.Method, systemConf)
+	}
+
+	// usage provided by the instance is only reported for requests which match a mapping rule
+	if len(metrics) > 0 && istioConf.Subject != nil {
+		if err := addUsageFromProperties(metrics, istioConf.Subject.Properties, systemConf); err != nil {
+			return authorizer.BackendRequest{}, err
+		}
 	}
-	metrics := generateMetrics(istioConf.Action.Path, istioConf.Action.Method, systemConf)
 
 	request := authorizer.BackendRequest{
 		Auth: authorizer.BackendAuth{
@@ -199,16 +470,149 @@ func (s *Threescale) requestFromConfig(systemConf system.ProxyConfig, istioConf
 		Transactions: []authorizer.BackendTransaction{
 			{
 				Metrics: metrics,
-				Params: authorizer.BackendParams{
-					AppID:   appID,
-					AppKey:  appKey,
-					UserKey: userKey,
-				},
+				Params:  params,
 			},
 		},
 	}
 
-	return request
+	return request, nil
+}
+
+// credentialError is returned when the credentials provided by a request do not match the authentication
+// pattern of the service
+type credentialError struct {
+	msg string
+}
+
+func (e credentialError) Error() string {
+	return e.msg
+}
+
+// selectCredentials picks the credentials which are sent to 3scale backend based on the authentication pattern
+// configured for the service via its backend version. Unless the handler sets a precedence, only the credential
+// required by the pattern is accepted. With a precedence set, a service using either the API key or application ID
+// pattern accepts both and the preferred credential is sent when a request provides both.
+func selectCredentials(backendVersion, precedence string, provided authorizer.BackendParams) (authorizer.BackendParams, error) {
+	hasUserKey := provided.UserKey != ""
+	hasAppID := provided.AppID != ""
+	if !hasUserKey && !hasAppID {
+		// missing credentials are reported when the request is validated
+		return provided, nil
+	}
+
+	userKeyOnly := authorizer.BackendParams{UserKey: provided.UserKey}
+	appIDOnly := authorizer.BackendParams{AppID: provided.AppID, AppKey: provided.AppKey}
+
+	switch {
+	case backendVersion == openIDTypeIdentifier:
+		if !hasAppID {
+			return provided, credentialError{fmt.Sprintf("service requires a %s for backend version %q but a %s was provided", OIDCAttributeKey, backendVersion, UserKeyAttributeKey)}
+		}
+		return appIDOnly, nil
+
+	case backendVersion != apiKeyTypeIdentifier && backendVersion != appIDTypeIdentifier:
+		// unknown authentication pattern so leave 3scale backend to decide
+		return provided, nil
+
+	case precedence == CredentialPrecedenceUserKey:
+		if hasUserKey {
+			return userKeyOnly, nil
+		}
+		return appIDOnly, nil
+
+	case precedence == CredentialPrecedenceAppID:
+		if hasAppID {
+			return appIDOnly, nil
+		}
+		return userKeyOnly, nil
+
+	case backendVersion == apiKeyTypeIdentifier:
+		if !hasUserKey {
+			return provided, credentialError{fmt.Sprintf("service requires a %s for backend version %q but an %s was provided", UserKeyAttributeKey, backendVersion, AppIDAttributeKey)}
+		}
+		return userKeyOnly, nil
+
+	default:
+		if !hasAppID {
+			return provided, credentialError{fmt.Sprintf("service requires an %s for backend version %q but a %s was provided", AppIDAttributeKey, backendVersion, UserKeyAttributeKey)}
+		}
+		return appIDOnly, nil
+	}
+}
+
+// addUsageFromProperties adds the deltas held in properties named with UsageAttributePrefix to metrics.
+// Deltas may be integers or strings holding integers, and empty or zero deltas are ignored. Since the system
+// configuration does not describe the metrics of a service, a metric is known if it is used by a mapping rule.
+func addUsageFromProperties(metrics api.Metrics, properties map[string]*policy.Value, conf system.ProxyConfig) error {
+	known := map[string]bool{defaultMetric: true}
+	for _, pr := range conf.Content.Proxy.ProxyRules {
+		known[pr.MetricSystemName] = true
+	}
+
+	for key, value := range properties {
+		if !strings.HasPrefix(key, UsageAttributePrefix) {
+			continue
+		}
+
+		metric := strings.TrimPrefix(key, UsageAttributePrefix)
+		delta, err := usageDelta(value)
+		if err != nil {
+			return fmt.Errorf("invalid usage for metric %s - %v", metric, err)
+		}
+
+		if delta == 0 {
+			continue
+		}
+
+		if !known[metric] {
+			return fmt.Errorf("invalid usage for metric %s - metric is not used by any mapping rule", metric)
+		}
+		metrics.Add(metric, delta)
+	}
+	return nil
+}
+
+func usageDelta(value *policy.Value) (int, error) {
+	var delta int64
+	switch v := value.GetValue().(type) {
+	case nil:
+		return 0, nil
+	case *policy.Value_Int64Value:
+		delta = v.Int64Value
+	case *policy.Value_StringValue:
+		if v.StringValue == "" {
+			return 0, nil
+		}
+
+		var err error
+		if delta, err = strconv.ParseInt(v.StringValue, 10, 64); err != nil {
+			return 0, fmt.Errorf("%q is not an integer", v.StringValue)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported value type %T", v)
+	}
+
+	if delta < 0 || delta > math.MaxInt32 {
+		return 0, fmt.Errorf("%d is out of range", delta)
+	}
+	return int(delta), nil
+}
+
+// credentialFromCookie reads the credential identified by key from the cookie header passed in the subject properties.
+// Returns an empty string if the instance does not configure a cookie for this credential or the cookie is not present.
+func credentialFromCookie(subject *authorization.SubjectMsg, key string) string {
+	cookieName := subject.Properties[key+CookieSourceSuffix].GetStringValue()
+	rawCookies := subject.Properties[CookieAttributeKey].GetStringValue()
+	if cookieName == "" || rawCookies == "" {
+		return ""
+	}
+
+	req := &http.Request{Header: http.Header{"Cookie": []string{rawCookies}}}
+	cookie, err := req.Cookie(cookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
 }
 
 // validateBackendRequest will help us reduce network calls by verifying that required auth credentials have been set
@@ -225,16 +629,38 @@ func (s *Threescale) validateBackendRequest(request authorizer.BackendRequest) (
 	return nil, nil
 }
 
-func (s *Threescale) convertAuthResponse(resp *authorizer.BackendResponse, result *v1beta1.CheckResult, err error) (*v1beta1.CheckResult, error) {
+// convertAuthResponse sets the status of the result from the response of 3scale backend.
+// Where the request is denied, the status details describe the denial.
+func (s *Threescale) convertAuthResponse(resp *authorizer.BackendResponse, result *v1beta1.CheckResult, err error, cfg *config.Params, requestID string) (*v1beta1.CheckResult, error) {
+	overrides := statusOverridesFor(cfg)
 	if err != nil {
 		// Try to obtain a correct mapping for the cause of failure. This will occur in events of 500+ status codes from
 		// upstream where we have not managed to get an actual response from Apisonator.
-		result.Status, _ = rpcStatusErrorHandler("request authorization failed", backendResponseToRpcStatus(resp), err)
+		result.Status, _ = rpcStatusErrorHandler(s.conf.Redactor, "request authorization failed", backendResponseToRpcStatus(resp, overrides), err)
 		return result, nil
 
 	}
 	if !resp.Authorized {
-		result.Status = errorCodeToRpcStatus(resp.ErrorCode)(resp.ErrorCode)
+		result.Status = errorCodeToRpcStatus(resp.ErrorCode, overrides)(resp.ErrorCode)
+
+		details, err := denialDetails(resp.ErrorCode, cfg.ServiceId, requestID)
+		if err != nil {
+			log.Errorf("error describing denial - %v", err)
+		} else {
+			result.Status.Details = []*types.Any{details}
+		}
+
+		directResponse, err := denialResponse(cfg, rpc.Code(result.Status.Code), denialTemplateData{
+			Code:      resp.ErrorCode,
+			Message:   denialMessage(resp.ErrorCode),
+			ServiceID: cfg.ServiceId,
+			RequestID: requestID,
+		})
+		if err != nil {
+			log.Errorf("error rendering denial response - %v", err)
+		} else if directResponse != nil {
+			result.Status.Details = append(result.Status.Details, directResponse)
+		}
 	} else {
 		result.Status = status.OK
 	}
@@ -243,6 +669,12 @@ func (s *Threescale) convertAuthResponse(resp *authorizer.BackendResponse, resul
 }
 
 func generateMetrics(path string, method string, conf system.ProxyConfig) api.Metrics {
+	return evaluateMappingRules(path, method, conf, nil)
+}
+
+// evaluateMappingRules matches the request against the proxy rules in order of priority and returns the resulting metrics.
+// If provided, onEvaluate is called for each rule which is evaluated.
+func evaluateMappingRules(path string, method string, conf system.ProxyConfig, onEvaluate func(RuleEvaluation)) api.Metrics {
 	metrics := make(api.Metrics)
 
 	// sort proxy rules based on Position field to establish priority
@@ -251,22 +683,68 @@ func generateMetrics(path string, method string, conf system.ProxyConfig) api.Me
 	})
 
 	for _, pr := range conf.Content.Proxy.ProxyRules {
-		if match, err := regexp.MatchString(pr.Pattern, path); err == nil {
-			if match && strings.ToUpper(pr.HTTPMethod) == strings.ToUpper(method) {
-				metrics.Add(pr.MetricSystemName, int(pr.Delta))
-				// stop matching if this rule has been marked as Last
-				if pr.Last {
-					break
-				}
+		match, err := regexp.MatchString(pr.Pattern, path)
+		matched := err == nil && match && strings.ToUpper(pr.HTTPMethod) == strings.ToUpper(method)
+
+		if onEvaluate != nil {
+			onEvaluate(RuleEvaluation{Rule: pr, Matched: matched, Err: err})
+		}
+
+		if matched {
+			metrics.Add(pr.MetricSystemName, int(pr.Delta))
+			// stop matching if this rule has been marked as Last
+			if pr.Last {
+				break
 			}
 		}
 	}
 	return metrics
 }
 
+// SimulateMappingRules evaluates a request against the mapping rules of the provided proxy config in the same
+// manner as the adapter, recording the outcome for each rule
+func SimulateMappingRules(path string, method string, conf system.ProxyConfig) Simulation {
+	sim := Simulation{}
+	sim.Metrics = evaluateMappingRules(path, method, conf, func(eval RuleEvaluation) {
+		sim.Evaluated = append(sim.Evaluated, eval)
+	})
+
+	if n := len(sim.Evaluated); n > 0 {
+		last := sim.Evaluated[n-1]
+		if last.Matched && last.Rule.Last {
+			sim.StoppedBy = &last.Rule
+			sim.Skipped = conf.Content.Proxy.ProxyRules[n:]
+		}
+	}
+	return sim
+}
+
+// ParseProxyConfig parses a proxy config in the JSON format exported by 3scale system,
+// either wrapped in a "proxy_config" element or unwrapped
+func ParseProxyConfig(data []byte) (system.ProxyConfig, error) {
+	element := struct {
+		ProxyConfig *system.ProxyConfig `json:"proxy_config"`
+	}{}
+
+	if err := json.Unmarshal(data, &element); err != nil {
+		return system.ProxyConfig{}, fmt.Errorf("error parsing proxy config - %v", err)
+	}
+
+	if element.ProxyConfig != nil {
+		return *element.ProxyConfig, nil
+	}
+
+	conf := system.ProxyConfig{}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return conf, fmt.Errorf("error parsing proxy config - %v", err)
+	}
+	return conf, nil
+}
+
 // rpcStatusErrorHandler provides a uniform way to log and format error messages and status which should be
 // returned to the user in cases where the authorization request is rejected.
-func rpcStatusErrorHandler(userFacingErrMsg string, fn func(string) rpc.Status, err error) (rpc.Status, error) {
+// Errors from 3scale can embed credentials and tokens so the message is redacted.
+func rpcStatusErrorHandler(redactor *Redactor, userFacingErrMsg string, fn func(string) rpc.Status, err error) (rpc.Status, error) {
 	if userFacingErrMsg != "" {
 		var errMsg string
 		if err != nil {
@@ -275,13 +753,32 @@ func rpcStatusErrorHandler(userFacingErrMsg string, fn func(string) rpc.Status,
 		err = fmt.Errorf("%s %s", userFacingErrMsg, errMsg)
 	}
 
+	err = errors.New(redactor.String(err.Error()))
 	log.Error(err.Error())
 	return fn(err.Error()), err
 }
 
-func systemErrorToRpcStatus(err error) func(string) rpc.Status {
+// statusOverridesFor returns the status overrides of the handler, which are expected to have been validated
+func statusOverridesFor(cfg *config.Params) statusOverrides {
+	overrides, _ := parseStatusOverrides(cfg.StatusOverrides)
+	return overrides
+}
+
+// requestIDFromInstance returns the request ID passed in the subject properties, if any
+func requestIDFromInstance(instance *authorization.InstanceMsg) string {
+	if instance == nil || instance.Subject == nil {
+		return ""
+	}
+	return instance.Subject.Properties[RequestIDAttributeKey].GetStringValue()
+}
+
+func systemErrorToRpcStatus(err error, overrides statusOverrides) func(string) rpc.Status {
 	switch e := err.(type) {
 	case system.ApiErr:
+		if code, ok := overrides.lookup(strconv.Itoa(e.Code())); ok {
+			return code
+		}
+
 		code, ok := httpStatusToRpcStatus[e.Code()]
 		if !ok {
 			return status.WithUnknown
@@ -292,11 +789,21 @@ func systemErrorToRpcStatus(err error) func(string) rpc.Status {
 	}
 }
 
-func backendResponseToRpcStatus(result *authorizer.BackendResponse) func(string) rpc.Status {
+// requestErrorToRpcStatus maps errors which occur when building the request to 3scale backend
+func requestErrorToRpcStatus(err error) func(string) rpc.Status {
+	if _, ok := err.(credentialError); ok {
+		return status.WithUnauthenticated
+	}
+	return status.WithInvalidArgument
+}
+
+func backendResponseToRpcStatus(result *authorizer.BackendResponse, overrides statusOverrides) func(string) rpc.Status {
 	respondWith := status.WithUnknown
 	if result != nil && result.RawResponse != nil {
 		if val, ok := result.RawResponse.(*http.Response); ok {
-			if code, ok := httpStatusToRpcStatus[val.StatusCode]; ok {
+			if code, ok := overrides.lookup(strconv.Itoa(val.StatusCode)); ok {
+				respondWith = code
+			} else if code, ok := httpStatusToRpcStatus[val.StatusCode]; ok {
 				respondWith = code
 			}
 		}
@@ -304,10 +811,13 @@ func backendResponseToRpcStatus(result *authorizer.BackendResponse) func(string)
 	return respondWith
 }
 
-func errorCodeToRpcStatus(threescaleErrorCode string) func(string) rpc.Status {
-	if threescaleErrorCode == "limits_exceeded" {
-		// return equiv of 429
-		return status.WithResourceExhausted
+func errorCodeToRpcStatus(threescaleErrorCode string, overrides statusOverrides) func(string) rpc.Status {
+	if code, ok := overrides.lookup(threescaleErrorCode); ok {
+		return code
+	}
+
+	if code, ok := errorCodeStatus[threescaleErrorCode]; ok {
+		return withCode(code)
 	}
 
 	switch convert.CodeToStatusCode(threescaleErrorCode) {
@@ -341,6 +851,16 @@ var (
 	errRequestPath   = errors.New("request path must be provided")
 	errNoMappingRule = errors.New("no matching mapping rule for request")
 	errNoCredentials = errors.New("no auth credentials provided or provided in invalid location")
+
+	errCacheSeconds         = errors.New("cache_ttl_seconds and cache_refresh_seconds must not be negative")
+	errBackendCache         = fmt.Errorf("backend_cache must be one of %q or %q", BackendCacheEnabled, BackendCacheDisabled)
+	errFailurePolicy        = fmt.Errorf("failure_policy must be one of %q or %q", FailurePolicyOpen, FailurePolicyClosed)
+	errCredentialPrecedence = fmt.Errorf("credential_precedence must be one of %q or %q", CredentialPrecedenceUserKey, CredentialPrecedenceAppID)
+	errEnvironment          = fmt.Errorf("environment must be one of %q or %q", EnvironmentProduction, EnvironmentStaging)
+	errProxyConfigVersion   = errors.New("proxy_config_version must not be negative")
+	errMode                 = fmt.Errorf("mode must be one of %q, %q or %q", ModeEnforce, ModeShadow, ModeReportOnly)
+	errEnforcePercentage    = errors.New("enforce_percentage must be between 0 and 100")
+	errNoAuthorizerFactory  = errors.New("handler overrides authorizer behaviour but the adapter does not support overrides")
 )
 
 // NewThreescale returns a Server interface
@@ -384,5 +904,13 @@ func (s *Threescale) Close() error {
 		_ = s.listener.Close()
 	}
 
+	// the default authorizer is owned by the caller but those created for handler overrides are owned by the server
+	s.authorizersMutex.Lock()
+	for _, authz := range s.authorizers {
+		authz.Shutdown()
+	}
+	s.authorizers = nil
+	s.authorizersMutex.Unlock()
+
 	return nil
 }
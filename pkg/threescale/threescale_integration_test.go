@@ -668,7 +668,7 @@ spec:
 	for _, input := range inputs {
 		s := integration.Scenario{
 			Setup: func() (ctx interface{}, err error) {
-				config := &AdapterConfig{input.authorizer, time.Second}
+				config := &AdapterConfig{Authorizer: input.authorizer, KeepAliveMaxAge: time.Second}
 
 				pServer, err := NewThreescale("3333", config)
 				if err != nil {
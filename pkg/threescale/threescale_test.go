@@ -3,17 +3,21 @@ package threescale
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-go-client/threescale/api"
 	"github.com/3scale/3scale-istio-adapter/config"
 	"github.com/3scale/3scale-porta-go-client/client"
 	"github.com/gogo/googleapis/google/rpc"
 	"github.com/gogo/protobuf/types"
 
+	policy "istio.io/api/policy/v1beta1"
 	"istio.io/istio/mixer/template/authorization"
 )
 
@@ -220,6 +224,133 @@ func TestHandleAuthorization(t *testing.T) {
 			},
 			expectStatus: int32(rpc.OK),
 		},
+		{
+			name: "Test credentials are read from cookies when configured",
+			params: config.Params{
+				ServiceId:   "123",
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "any",
+			},
+			request: &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: "get",
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						Properties: map[string]*policy.Value{
+							CookieAttributeKey:                       stringValue("theme=dark; session=VALID"),
+							UserKeyAttributeKey + CookieSourceSuffix: stringValue("session"),
+						},
+					},
+				},
+				AdapterConfig: &types.Any{},
+			},
+			authorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						Proxy: client.ContentProxy{
+							ProxyRules: []client.ProxyRule{
+								{
+									HTTPMethod:       http.MethodGet,
+									Pattern:          "/test",
+									MetricSystemName: "hits",
+									Delta:            1,
+								},
+							},
+						},
+					},
+				},
+				withAuthResponse: &authorizer.BackendResponse{
+					Authorized: false,
+				},
+			},
+			expectStatus: int32(rpc.OK),
+		},
+		{
+			name: "Test fail - credential does not match backend version",
+			params: config.Params{
+				ServiceId:   "123",
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "expect14",
+			},
+			request: &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: "get",
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						Properties: map[string]*policy.Value{
+							AppIDAttributeKey: stringValue("VALID"),
+						},
+					},
+				},
+				AdapterConfig: &types.Any{},
+			},
+			authorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						BackendVersion: apiKeyTypeIdentifier,
+						Proxy: client.ContentProxy{
+							ProxyRules: []client.ProxyRule{
+								{
+									HTTPMethod:       http.MethodGet,
+									Pattern:          "/test",
+									MetricSystemName: "hits",
+									Delta:            1,
+								},
+							},
+						},
+					},
+				},
+				withAuthResponse: &authorizer.BackendResponse{},
+			},
+			expectStatus:         int32(rpc.UNAUTHENTICATED),
+			expectErrMsgContains: `service requires a user_key for backend version "1"`,
+		},
+		{
+			name: "Test fail - invalid handler overrides",
+			params: config.Params{
+				ServiceId:     "123",
+				SystemUrl:     "https://www.fake-system.3scale.net",
+				AccessToken:   "expect14",
+				BackendCache:  "sometimes",
+				FailurePolicy: "ajar",
+			},
+			request: &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: "get",
+						Path:   "/test",
+					},
+				},
+				AdapterConfig: &types.Any{},
+			},
+			expectStatus:         int32(rpc.FAILED_PRECONDITION),
+			expectErrMsgContains: "backend_cache must be one of",
+		},
+		{
+			name: "Test fail - invalid environment",
+			params: config.Params{
+				ServiceId:          "123",
+				SystemUrl:          "https://www.fake-system.3scale.net",
+				AccessToken:        "expect14",
+				Environment:        "preprod",
+				ProxyConfigVersion: -1,
+			},
+			request: &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: "get",
+						Path:   "/test",
+					},
+				},
+				AdapterConfig: &types.Any{},
+			},
+			expectStatus:         int32(rpc.FAILED_PRECONDITION),
+			expectErrMsgContains: "environment must be one of",
+		},
 	}
 	for _, input := range inputs {
 		t.Run(input.name, func(t *testing.T) {
@@ -250,6 +381,485 @@ func TestHandleAuthorization(t *testing.T) {
 	}
 }
 
+func TestAuthorizerFor(t *testing.T) {
+	inputs := []struct {
+		name          string
+		params        []config.Params
+		withFactory   bool
+		expectDefault bool
+		expectErr     bool
+		// expectCreated is the options each created authorizer was created with, in order
+		expectCreated []AuthorizerOptions
+	}{
+		{
+			name:          "Test default authorizer used without overrides",
+			params:        []config.Params{{ServiceId: "123"}},
+			withFactory:   true,
+			expectDefault: true,
+		},
+		{
+			name: "Test authorizer created for overrides",
+			params: []config.Params{{
+				CacheTtlSeconds:     60,
+				CacheRefreshSeconds: 30,
+				BackendCache:        "Enabled",
+				FailurePolicy:       FailurePolicyOpen,
+			}},
+			withFactory: true,
+			expectCreated: []AuthorizerOptions{{
+				SystemCacheTTL:             time.Minute,
+				SystemCacheRefreshInterval: time.Second * 30,
+				BackendCache:               BackendCacheEnabled,
+				FailurePolicy:              FailurePolicyOpen,
+			}},
+		},
+		{
+			name: "Test authorizer shared by handlers with the same overrides",
+			params: []config.Params{
+				{ServiceId: "123", BackendCache: BackendCacheDisabled},
+				{ServiceId: "456", BackendCache: BackendCacheDisabled},
+				{ServiceId: "789", BackendCache: BackendCacheEnabled},
+			},
+			withFactory: true,
+			expectCreated: []AuthorizerOptions{
+				{BackendCache: BackendCacheDisabled},
+				{BackendCache: BackendCacheEnabled},
+			},
+		},
+		{
+			name:          "Test default authorizer used for production environment",
+			params:        []config.Params{{ServiceId: "123", Environment: "Production"}},
+			withFactory:   true,
+			expectDefault: true,
+		},
+		{
+			name: "Test authorizers created per environment and pinned version",
+			params: []config.Params{
+				{ServiceId: "123", Environment: EnvironmentStaging},
+				{ServiceId: "123", Environment: EnvironmentStaging, ProxyConfigVersion: 4},
+				{ServiceId: "456", Environment: "STAGING"},
+			},
+			withFactory: true,
+			expectCreated: []AuthorizerOptions{
+				{Environment: EnvironmentStaging},
+				{Environment: EnvironmentStaging, ProxyConfigVersion: 4},
+			},
+		},
+		{
+			name:        "Test fail - overrides without a factory",
+			params:      []config.Params{{BackendCache: BackendCacheEnabled}},
+			withFactory: false,
+			expectErr:   true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var created []AuthorizerOptions
+			defaultAuthorizer := &mockAuthorizer{}
+
+			conf := &AdapterConfig{Authorizer: defaultAuthorizer}
+			if input.withFactory {
+				conf.AuthorizerFactory = func(opts AuthorizerOptions) (Authorizer, error) {
+					created = append(created, opts)
+					return &mockAuthorizer{}, nil
+				}
+			}
+			s := &Threescale{conf: conf}
+
+			for _, params := range input.params {
+				authz, err := s.authorizerFor(&params)
+				if input.expectErr {
+					if err == nil {
+						t.Errorf("expected error for params %v", params)
+					}
+					continue
+				}
+
+				if err != nil {
+					t.Fatalf("unexpected error - %v", err)
+				}
+
+				if isDefault := authz == defaultAuthorizer; isDefault != input.expectDefault {
+					t.Errorf("expected default authorizer to be used %t but got %t", input.expectDefault, isDefault)
+				}
+			}
+
+			if !reflect.DeepEqual(created, input.expectCreated) {
+				t.Errorf("unexpected authorizers created, wanted %v but got %v", input.expectCreated, created)
+			}
+		})
+	}
+}
+
+func TestSelectCredentials(t *testing.T) {
+	userKey := authorizer.BackendParams{UserKey: "user"}
+	appID := authorizer.BackendParams{AppID: "app", AppKey: "key"}
+	both := authorizer.BackendParams{UserKey: "user", AppID: "app", AppKey: "key"}
+
+	inputs := []struct {
+		name           string
+		backendVersion string
+		precedence     string
+		provided       authorizer.BackendParams
+		expect         authorizer.BackendParams
+		expectErr      bool
+	}{
+		{
+			name:           "Test no credentials left for validation",
+			backendVersion: apiKeyTypeIdentifier,
+			expect:         authorizer.BackendParams{},
+		},
+		{
+			name:           "Test API key service sends user key",
+			backendVersion: apiKeyTypeIdentifier,
+			provided:       both,
+			expect:         userKey,
+		},
+		{
+			name:           "Test fail - API key service with application ID",
+			backendVersion: apiKeyTypeIdentifier,
+			provided:       appID,
+			expectErr:      true,
+		},
+		{
+			name:           "Test application ID service sends application ID and key",
+			backendVersion: appIDTypeIdentifier,
+			provided:       both,
+			expect:         appID,
+		},
+		{
+			name:           "Test fail - application ID service with user key",
+			backendVersion: appIDTypeIdentifier,
+			provided:       userKey,
+			expectErr:      true,
+		},
+		{
+			name:           "Test fail - OpenID Connect service with user key",
+			backendVersion: openIDTypeIdentifier,
+			precedence:     CredentialPrecedenceUserKey,
+			provided:       userKey,
+			expectErr:      true,
+		},
+		{
+			name:           "Test OpenID Connect service ignores precedence",
+			backendVersion: openIDTypeIdentifier,
+			precedence:     CredentialPrecedenceUserKey,
+			provided:       both,
+			expect:         appID,
+		},
+		{
+			name:           "Test hybrid prefers user key",
+			backendVersion: appIDTypeIdentifier,
+			precedence:     CredentialPrecedenceUserKey,
+			provided:       both,
+			expect:         userKey,
+		},
+		{
+			name:           "Test hybrid falls back to application ID",
+			backendVersion: apiKeyTypeIdentifier,
+			precedence:     CredentialPrecedenceUserKey,
+			provided:       appID,
+			expect:         appID,
+		},
+		{
+			name:           "Test hybrid prefers application ID",
+			backendVersion: apiKeyTypeIdentifier,
+			precedence:     CredentialPrecedenceAppID,
+			provided:       both,
+			expect:         appID,
+		},
+		{
+			name:           "Test hybrid falls back to user key",
+			backendVersion: appIDTypeIdentifier,
+			precedence:     CredentialPrecedenceAppID,
+			provided:       userKey,
+			expect:         userKey,
+		},
+		{
+			name:     "Test unknown backend version sends all credentials",
+			provided: both,
+			expect:   both,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params, err := selectCredentials(input.backendVersion, input.precedence, input.provided)
+			if input.expectErr {
+				if _, ok := err.(credentialError); !ok {
+					t.Errorf("expected credential error but got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if !reflect.DeepEqual(params, input.expect) {
+				t.Errorf("unexpected credentials, wanted %+v but got %+v", input.expect, params)
+			}
+		})
+	}
+}
+
+func TestRequestFromConfigUsage(t *testing.T) {
+	conf := client.ProxyConfig{
+		Content: client.Content{
+			Proxy: client.ContentProxy{
+				ProxyRules: []client.ProxyRule{
+					{
+						HTTPMethod:       http.MethodPost,
+						Pattern:          "/upload",
+						MetricSystemName: "uploads",
+						Delta:            1,
+						Position:         1,
+					},
+					{
+						HTTPMethod:       http.MethodPost,
+						Pattern:          "/batch",
+						MetricSystemName: "items",
+						Delta:            1,
+						Position:         2,
+					},
+				},
+			},
+		},
+	}
+
+	inputs := []struct {
+		name          string
+		path          string
+		properties    map[string]*policy.Value
+		expectMetrics api.Metrics
+		expectErr     string
+	}{
+		{
+			name:          "Test no usage properties",
+			path:          "/upload",
+			expectMetrics: api.Metrics{"uploads": 1},
+		},
+		{
+			name: "Test usage added to mapping rule deltas",
+			path: "/upload",
+			properties: map[string]*policy.Value{
+				UsageAttributePrefix + "uploads": {Value: &policy.Value_Int64Value{Int64Value: 2048}},
+				UsageAttributePrefix + "hits":    stringValue("3"),
+			},
+			expectMetrics: api.Metrics{"uploads": 2049, "hits": 3},
+		},
+		{
+			name: "Test usage reported against metric of another mapping rule",
+			path: "/batch",
+			properties: map[string]*policy.Value{
+				UsageAttributePrefix + "uploads": stringValue("10"),
+			},
+			expectMetrics: api.Metrics{"items": 1, "uploads": 10},
+		},
+		{
+			name: "Test empty and zero usage ignored",
+			path: "/upload",
+			properties: map[string]*policy.Value{
+				UsageAttributePrefix + "uploads": stringValue(""),
+				UsageAttributePrefix + "unknown": stringValue("0"),
+			},
+			expectMetrics: api.Metrics{"uploads": 1},
+		},
+		{
+			name: "Test usage ignored when no mapping rule matches",
+			path: "/unmatched",
+			properties: map[string]*policy.Value{
+				UsageAttributePrefix + "uploads": stringValue("10"),
+			},
+			expectMetrics: api.Metrics{},
+		},
+		{
+			name: "Test fail - unknown metric",
+			path: "/upload",
+			properties: map[string]*policy.Value{
+				UsageAttributePrefix + "downloads": stringValue("10"),
+			},
+			expectErr: "metric is not used by any mapping rule",
+		},
+		{
+			name: "Test fail - non integer usage",
+			path: "/upload",
+			properties: map[string]*policy.Value{
+				UsageAttributePrefix + "uploads": stringValue("ten"),
+			},
+			expectErr: "is not an integer",
+		},
+		{
+			name: "Test fail - negative usage",
+			path: "/upload",
+			properties: map[string]*policy.Value{
+				UsageAttributePrefix + "uploads": {Value: &policy.Value_Int64Value{Int64Value: -1}},
+			},
+			expectErr: "out of range",
+		},
+		{
+			name: "Test fail - unsupported value type",
+			path: "/upload",
+			properties: map[string]*policy.Value{
+				UsageAttributePrefix + "uploads": {Value: &policy.Value_BoolValue{BoolValue: true}},
+			},
+			expectErr: "unsupported value type",
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			s := &Threescale{}
+			instance := authorization.InstanceMsg{
+				Action: &authorization.ActionMsg{
+					Method: http.MethodPost,
+					Path:   input.path,
+				},
+				Subject: &authorization.SubjectMsg{
+					User:       "VALID",
+					Properties: input.properties,
+				},
+			}
+
+			req, err := s.requestFromConfig(conf, Product{}, instance, config.Params{ServiceId: "123"})
+			if input.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), input.expectErr) {
+					t.Errorf("expected error containing %q but got %v", input.expectErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if metrics := req.Transactions[0].Metrics; !reflect.DeepEqual(metrics, input.expectMetrics) {
+				t.Errorf("unexpected metrics, wanted %v but got %v", input.expectMetrics, metrics)
+			}
+		})
+	}
+}
+
+func TestSimulateMappingRules(t *testing.T) {
+	conf := client.ProxyConfig{
+		Content: client.Content{
+			Proxy: client.ContentProxy{
+				ProxyRules: []client.ProxyRule{
+					{
+						HTTPMethod:       http.MethodGet,
+						Pattern:          "/anything/bar/",
+						Position:         3,
+						MetricSystemName: "skipped",
+						Delta:            1,
+					},
+					{
+						HTTPMethod:       http.MethodPost,
+						Pattern:          "/anything",
+						Position:         1,
+						MetricSystemName: "wrong_method",
+						Delta:            1,
+					},
+					{
+						HTTPMethod:       http.MethodGet,
+						Pattern:          "/anything/(",
+						Position:         0,
+						MetricSystemName: "invalid",
+						Delta:            1,
+					},
+					{
+						HTTPMethod:       http.MethodGet,
+						Pattern:          "/anything/bar/123",
+						Position:         2,
+						Last:             true,
+						MetricSystemName: "hits",
+						Delta:            2,
+					},
+				},
+			},
+		},
+	}
+
+	sim := SimulateMappingRules("/anything/bar/123", "get", conf)
+
+	if len(sim.Evaluated) != 3 {
+		t.Fatalf("expected 3 rules to be evaluated but got %d", len(sim.Evaluated))
+	}
+
+	expectOrder := []string{"invalid", "wrong_method", "hits"}
+	for i, eval := range sim.Evaluated {
+		if eval.Rule.MetricSystemName != expectOrder[i] {
+			t.Errorf("expected rule %s at index %d but got %s", expectOrder[i], i, eval.Rule.MetricSystemName)
+		}
+	}
+
+	if sim.Evaluated[0].Err == nil || sim.Evaluated[0].Matched {
+		t.Errorf("expected invalid pattern to produce an error and not match")
+	}
+
+	if sim.Evaluated[1].Matched {
+		t.Errorf("expected rule with different method not to match")
+	}
+
+	if !sim.Evaluated[2].Matched {
+		t.Errorf("expected rule to match")
+	}
+
+	if sim.StoppedBy == nil || sim.StoppedBy.MetricSystemName != "hits" {
+		t.Errorf("expected evaluation to be stopped by rule marked as last")
+	}
+
+	if len(sim.Skipped) != 1 || sim.Skipped[0].MetricSystemName != "skipped" {
+		t.Errorf("expected a single skipped rule")
+	}
+
+	if len(sim.Metrics) != 1 || sim.Metrics["hits"] != 2 {
+		t.Errorf("unexpected metrics %v", sim.Metrics)
+	}
+
+	if !reflect.DeepEqual(sim.Metrics, generateMetrics("/anything/bar/123", "get", conf)) {
+		t.Errorf("expected simulated metrics to match metrics generated by the adapter")
+	}
+}
+
+func TestParseProxyConfig(t *testing.T) {
+	const unwrapped = `{
+  "environment": "production",
+  "content": {
+    "backend_version": "1",
+    "proxy": {
+      "proxy_rules": [
+        {"http_method": "GET", "pattern": "/", "metric_system_name": "hits", "delta": 1, "position": 1, "last": false}
+      ]
+    }
+  }
+}`
+
+	for name, input := range map[string]string{
+		"Test unwrapped proxy config":    unwrapped,
+		"Test wrapped proxy config":      `{"proxy_config": ` + unwrapped + `}`,
+		"Test fail with invalid content": `{`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			conf, err := ParseProxyConfig([]byte(input))
+			if input == "{" {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			rules := conf.Content.Proxy.ProxyRules
+			if conf.Content.BackendVersion != "1" || len(rules) != 1 || rules[0].MetricSystemName != "hits" {
+				t.Errorf("unexpected proxy config parsed %v", conf)
+			}
+		})
+	}
+}
+
 func Test_NewThreescale(t *testing.T) {
 	addr := "0"
 	threescaleConf := &AdapterConfig{
@@ -267,6 +877,10 @@ func Test_NewThreescale(t *testing.T) {
 	s.Close()
 }
 
+func stringValue(s string) *policy.Value {
+	return &policy.Value{Value: &policy.Value_StringValue{StringValue: s}}
+}
+
 type mockAuthorizer struct {
 	withSystemErr       error
 	withBackendErr      error
@@ -306,3 +920,113 @@ func (m mockAuthorizer) AuthRep(backendURL string, request authorizer.BackendReq
 }
 
 func (m mockAuthorizer) Shutdown() {}
+
+func BenchmarkGenerateMetrics(b *testing.B) {
+	for _, rules := range []int{10, 100, 1000} {
+		conf := proxyConfigWithRules(rules)
+		path := fmt.Sprintf("/resource/%d/items", rules/2)
+
+		b.Run(fmt.Sprintf("%d rules", rules), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// rules are sorted in place, so each iteration is given the unsorted rules
+				b.StopTimer()
+				unsorted := withProxyRules(conf, conf.Content.Proxy.ProxyRules)
+				b.StartTimer()
+
+				generateMetrics(path, http.MethodGet, unsorted)
+			}
+		})
+	}
+}
+
+func BenchmarkRequestFromConfig(b *testing.B) {
+	s := &Threescale{}
+	conf := proxyConfigWithRules(100)
+	instance := authorization.InstanceMsg{
+		Action: &authorization.ActionMsg{
+			Method: http.MethodGet,
+			Path:   "/resource/50/items",
+		},
+		Subject: &authorization.SubjectMsg{
+			Properties: map[string]*policy.Value{
+				AppIDAttributeKey:  stringValue("VALID"),
+				AppKeyAttributeKey: stringValue("secret"),
+			},
+		},
+	}
+	params := config.Params{ServiceId: "123"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		unsorted := withProxyRules(conf, conf.Content.Proxy.ProxyRules)
+		b.StartTimer()
+
+		s.requestFromConfig(unsorted, Product{}, instance, params)
+	}
+}
+
+func BenchmarkHandleAuthorization(b *testing.B) {
+	params := config.Params{
+		ServiceId:   "123",
+		SystemUrl:   "https://www.fake-system.3scale.net",
+		AccessToken: "expect14",
+	}
+	adapterConfig, _ := params.Marshal()
+
+	conf := proxyConfigWithRules(100)
+	authz := mockAuthorizer{
+		withConfig:       conf,
+		withAuthResponse: &authorizer.BackendResponse{},
+	}
+
+	// the mock authorizer returns the system config immediately, as the authorizer does for cached config
+	s := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: authz,
+		},
+	}
+	request := &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Action: &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/resource/50/items",
+			},
+			Subject: &authorization.SubjectMsg{
+				User: "VALID",
+			},
+		},
+		AdapterConfig: &types.Any{Value: adapterConfig},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		authz.withConfig = withProxyRules(conf, conf.Content.Proxy.ProxyRules)
+		s.conf.Authorizer = authz
+		b.StartTimer()
+
+		result, _ := s.HandleAuthorization(context.TODO(), request)
+		if result.Status.Code != int32(rpc.OK) {
+			b.Fatalf("unexpected status %v", result.Status)
+		}
+	}
+}
+
+// proxyConfigWithRules returns a proxy config with the provided number of mapping rules,
+// each matching a single resource and positioned in reverse order
+func proxyConfigWithRules(rules int) client.ProxyConfig {
+	conf := client.ProxyConfig{}
+	for i := 0; i < rules; i++ {
+		conf.Content.Proxy.ProxyRules = append(conf.Content.Proxy.ProxyRules, client.ProxyRule{
+			HTTPMethod:       http.MethodGet,
+			Pattern:          fmt.Sprintf("^/resource/%d/", i),
+			MetricSystemName: fmt.Sprintf("metric_%d", i),
+			Delta:            1,
+			Position:         rules - i,
+		})
+	}
+	return conf
+}
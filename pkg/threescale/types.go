@@ -2,8 +2,11 @@ package threescale
 
 import (
 	"net"
+	"net/http"
+	"sync"
 	"time"
 
+	"github.com/3scale/3scale-go-client/threescale/api"
 	"github.com/3scale/3scale-porta-go-client/client"
 
 	"github.com/3scale/3scale-authorizer/pkg/authorizer"
@@ -22,18 +25,82 @@ type Threescale struct {
 	listener net.Listener
 	server   *grpc.Server
 	conf     *AdapterConfig
+	// authorizers created for handlers which override the default behaviour, keyed by the overrides
+	authorizers      map[AuthorizerOptions]Authorizer
+	authorizersMutex sync.Mutex
+	// indexes of hosts used to resolve the service of requests which provide no service ID
+	hostIndexes      map[hostIndexKey]*hostIndex
+	hostIndexesMutex sync.Mutex
 }
 
 type Authorizer interface {
-	GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error)
+	ConfigSource
 	AuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error)
 	OauthAuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error)
 	Shutdown()
 }
 
+// AuthorizerOptions describe the behaviour of an Authorizer which can be overridden by a handler.
+// Zero values indicate that the adapter default should be used.
+type AuthorizerOptions struct {
+	SystemCacheTTL             time.Duration
+	SystemCacheRefreshInterval time.Duration
+	// BackendCache is one of BackendCacheEnabled or BackendCacheDisabled
+	BackendCache string
+	// FailurePolicy is one of FailurePolicyOpen or FailurePolicyClosed
+	FailurePolicy string
+	// Environment is EnvironmentStaging if set, since the system cache of an Authorizer is not keyed by environment
+	Environment string
+	// ProxyConfigVersion pins the proxy configuration of services to a version if set
+	ProxyConfigVersion int64
+}
+
+// AuthorizerFactory creates an Authorizer with the provided overrides applied to the adapter defaults
+type AuthorizerFactory func(opts AuthorizerOptions) (Authorizer, error)
+
 // AdapterConfig wraps optional configuration for the 3scale adapter
 type AdapterConfig struct {
+	// Authorizer used for handlers which do not override its behaviour
 	Authorizer Authorizer
+	// AuthorizerFactory creates the Authorizer for handlers which override its behaviour.
+	// If nil, such handlers are rejected.
+	AuthorizerFactory AuthorizerFactory
+	// ConfigSource provides the proxy configuration of services in place of 3scale system.
+	// If nil, the configuration is fetched from 3scale system by the Authorizer of the handler.
+	ConfigSource ConfigSource
+	// HTTPClient lists services from 3scale system to resolve the service of requests by host. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// HostIndexRefreshInterval is the time period before the index of hosts used to resolve services is rebuilt,
+	// unless overridden by a handler. If zero, it matches the default refresh interval of the system cache.
+	HostIndexRefreshInterval time.Duration
+	// AccessList allows or denies credentials before requests are authorized against 3scale. If nil, all requests are authorized against 3scale.
+	AccessList *AccessList
+	// OnAccessListDecision is called for each request allowed or denied by the AccessList
+	OnAccessListDecision func(AccessListDecision)
+	// OnShadowDenial is called for each request which would have been denied by a handler in ModeShadow or ModeReportOnly
+	OnShadowDenial func(ShadowDenial)
+	// Redactor scrubs credentials and tokens from logs and errors. If nil, secrets are replaced by fingerprints.
+	Redactor *Redactor
 	//gRPC connection keepalive duration
 	KeepAliveMaxAge time.Duration
 }
+
+// RuleEvaluation is the outcome of matching a single mapping rule against a request
+type RuleEvaluation struct {
+	Rule    client.ProxyRule
+	Matched bool
+	// Err is set if the rule's pattern is not a valid regular expression
+	Err error
+}
+
+// Simulation describes how the mapping rules of a service apply to a request
+type Simulation struct {
+	// Evaluated rules, in order of priority
+	Evaluated []RuleEvaluation
+	// StoppedBy is the matching rule marked as last which stopped evaluation, if any
+	StoppedBy *client.ProxyRule
+	// Skipped rules which were not evaluated as a result of StoppedBy
+	Skipped []client.ProxyRule
+	// Metrics which would be reported for the request
+	Metrics api.Metrics
+}
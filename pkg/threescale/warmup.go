@@ -0,0 +1,111 @@
+package threescale
+
+import (
+	"context"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+)
+
+// warmupConcurrency limits the number of system configurations fetched in parallel during warm up
+const warmupConcurrency = 10
+
+// WarmupTarget identifies the 3scale services whose system configuration should be cached on startup
+type WarmupTarget struct {
+	// SystemURL must match the system_url of the handlers for the cached configuration to be used
+	SystemURL   string   `json:"system_url"`
+	AccessToken string   `json:"access_token"`
+	ServiceIDs  []string `json:"service_ids"`
+	// Options are the overrides set by the handlers of the services, which are warmed through the Authorizer for them
+	Options AuthorizerOptions `json:"-"`
+}
+
+// WarmupProgress is called once the system configuration of each service has been fetched, or failed to be.
+// It may be called concurrently.
+type WarmupProgress func(systemURL string, serviceID string, err error)
+
+// WarmSystemCache fetches the system configuration of each target service through the Authorizer returned by authorizerFor
+// for the options of the target, so that it is cached before the first request for the service.
+// Returns the number of services which were fetched and which failed.
+// Services still being fetched when ctx is done are neither counted nor waited for.
+func WarmSystemCache(ctx context.Context, authorizerFor AuthorizerFactory, targets []WarmupTarget, progress WarmupProgress) (int, int) {
+	type job struct {
+		authz     Authorizer
+		err       error
+		systemURL string
+		request   authorizer.SystemRequest
+	}
+
+	jobs := make(chan job)
+	results := make(chan error)
+
+	for i := 0; i < warmupConcurrency; i++ {
+		go func() {
+			for j := range jobs {
+				err := j.err
+				if err == nil {
+					_, err = j.authz.GetSystemConfiguration(j.systemURL, j.request)
+				}
+				if progress != nil {
+					progress(j.systemURL, j.request.ServiceID, err)
+				}
+
+				select {
+				case results <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	var pending []job
+	for _, target := range targets {
+		authz, err := authorizerFor(target.Options)
+
+		// the environment is only overridden for staging, see AuthorizerOptions
+		environment := target.Options.Environment
+		if environment == "" {
+			environment = EnvironmentProduction
+		}
+
+		for _, serviceID := range target.ServiceIDs {
+			pending = append(pending, job{
+				authz:     authz,
+				err:       err,
+				systemURL: target.SystemURL,
+				request: authorizer.SystemRequest{
+					AccessToken: target.AccessToken,
+					ServiceID:   serviceID,
+					Environment: environment,
+				},
+			})
+		}
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, j := range pending {
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var warmed, failed int
+	for range pending {
+		select {
+		case err := <-results:
+			if err != nil {
+				failed++
+			} else {
+				warmed++
+			}
+		case <-ctx.Done():
+			return warmed, failed
+		}
+	}
+
+	return warmed, failed
+}
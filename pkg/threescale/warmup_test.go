@@ -0,0 +1,137 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+type warmupAuthorizer struct {
+	mockAuthorizer
+	failFor map[string]bool
+	block   chan struct{}
+
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (w *warmupAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	if w.block != nil {
+		<-w.block
+	}
+
+	w.mutex.Lock()
+	w.requests = append(w.requests, systemURL+"/"+request.ServiceID+"/"+request.AccessToken+"/"+request.Environment)
+	w.mutex.Unlock()
+
+	if w.failFor[request.ServiceID] {
+		return client.ProxyConfig{}, errors.New("unavailable")
+	}
+	return client.ProxyConfig{}, nil
+}
+
+// warmupFactory returns authz for the default options and staging for staging, and fails for any other options
+func warmupFactory(authz, staging Authorizer) AuthorizerFactory {
+	return func(opts AuthorizerOptions) (Authorizer, error) {
+		switch opts {
+		case AuthorizerOptions{}:
+			return authz, nil
+		case AuthorizerOptions{Environment: EnvironmentStaging}:
+			return staging, nil
+		}
+		return nil, errors.New("unsupported options")
+	}
+}
+
+func TestWarmSystemCache(t *testing.T) {
+	targets := []WarmupTarget{
+		{
+			SystemURL:   "https://a.3scale.net",
+			AccessToken: "token-a",
+			ServiceIDs:  []string{"1", "2"},
+		},
+		{
+			SystemURL:   "https://b.3scale.net",
+			AccessToken: "token-b",
+			ServiceIDs:  []string{"3"},
+		},
+		{
+			SystemURL:   "https://b.3scale.net",
+			AccessToken: "token-b",
+			ServiceIDs:  []string{"4"},
+			Options:     AuthorizerOptions{Environment: EnvironmentStaging},
+		},
+		{
+			SystemURL:   "https://b.3scale.net",
+			AccessToken: "token-b",
+			ServiceIDs:  []string{"5"},
+			Options:     AuthorizerOptions{FailurePolicy: FailurePolicyClosed},
+		},
+	}
+
+	authz := &warmupAuthorizer{failFor: map[string]bool{"2": true}}
+	staging := &warmupAuthorizer{}
+
+	var mutex sync.Mutex
+	progressed := make(map[string]error)
+	warmed, failed := WarmSystemCache(context.TODO(), warmupFactory(authz, staging), targets, func(systemURL string, serviceID string, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		progressed[serviceID] = err
+	})
+
+	if warmed != 3 || failed != 2 {
+		t.Errorf("expected 3 warmed and 2 failed but got %d and %d", warmed, failed)
+	}
+
+	expect := []string{"https://a.3scale.net/1/token-a/production", "https://a.3scale.net/2/token-a/production", "https://b.3scale.net/3/token-b/production"}
+	sort.Strings(authz.requests)
+	if len(authz.requests) != len(expect) {
+		t.Fatalf("expected requests %v but got %v", expect, authz.requests)
+	}
+	for i := range expect {
+		if authz.requests[i] != expect[i] {
+			t.Errorf("expected request %s but got %s", expect[i], authz.requests[i])
+		}
+	}
+
+	if len(staging.requests) != 1 || staging.requests[0] != "https://b.3scale.net/4/token-b/staging" {
+		t.Errorf("expected staging service to be warmed through the authorizer for its options but got %v", staging.requests)
+	}
+
+	if len(progressed) != 5 || progressed["1"] != nil || progressed["2"] == nil || progressed["5"] == nil {
+		t.Errorf("unexpected progress %v", progressed)
+	}
+}
+
+func TestWarmSystemCacheTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Millisecond*50)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		warmed, failed := WarmSystemCache(ctx, warmupFactory(&warmupAuthorizer{block: block}, nil), []WarmupTarget{
+			{SystemURL: "https://a.3scale.net", ServiceIDs: []string{"1"}},
+		}, nil)
+
+		if warmed != 0 || failed != 0 {
+			t.Errorf("expected no services to be counted but got %d and %d", warmed, failed)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("expected warm up to give up when context is done")
+	}
+}
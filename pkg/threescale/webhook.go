@@ -0,0 +1,85 @@
+package threescale
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// Types of the objects notified by 3scale webhooks whose changes affect what is cached by the adapter
+const (
+	WebhookTypeService     = "service"
+	WebhookTypeProxyConfig = "proxy_config"
+	WebhookTypeApplication = "application"
+)
+
+// WebhookEvent is a change notified by a 3scale webhook
+type WebhookEvent struct {
+	// Type of the changed object, for example "service" or "application"
+	Type string
+	// Action applied to the object, for example "updated"
+	Action string
+	// ServiceID of the service affected by the change, if any
+	ServiceID string
+	// Application is the user key or application ID of the affected application, if any
+	Application string
+}
+
+// webhookXML is the subset of the XML body of a 3scale webhook which describes the affected service and application
+type webhookXML struct {
+	XMLName xml.Name `xml:"event"`
+	Type    string   `xml:"type"`
+	Action  string   `xml:"action"`
+	Object  struct {
+		Service *struct {
+			ID string `xml:"id"`
+		} `xml:"service"`
+		ProxyConfig *struct {
+			ServiceID string `xml:"service_id"`
+		} `xml:"proxy_config"`
+		Application *struct {
+			ServiceID     string `xml:"service_id"`
+			UserKey       string `xml:"user_key"`
+			ApplicationID string `xml:"application_id"`
+		} `xml:"application"`
+	} `xml:"object"`
+}
+
+// ParseWebhook parses the XML body of a 3scale webhook
+func ParseWebhook(data []byte) (WebhookEvent, error) {
+	parsed := webhookXML{}
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		return WebhookEvent{}, fmt.Errorf("error parsing webhook - %v", err)
+	}
+
+	event := WebhookEvent{
+		Type:   parsed.Type,
+		Action: parsed.Action,
+	}
+
+	if service := parsed.Object.Service; service != nil {
+		event.ServiceID = service.ID
+	}
+
+	if conf := parsed.Object.ProxyConfig; conf != nil {
+		event.ServiceID = conf.ServiceID
+	}
+
+	if app := parsed.Object.Application; app != nil {
+		event.ServiceID = app.ServiceID
+		event.Application = app.UserKey
+		if event.Application == "" {
+			event.Application = app.ApplicationID
+		}
+	}
+	return event, nil
+}
+
+// ChangesProxyConfig returns true if the event changes the proxy configuration of its service
+func (e WebhookEvent) ChangesProxyConfig() bool {
+	return e.ServiceID != "" && (e.Type == WebhookTypeService || e.Type == WebhookTypeProxyConfig)
+}
+
+// ChangesApplication returns true if the event changes an application, whose state may be held by a backend cache
+func (e WebhookEvent) ChangesApplication() bool {
+	return e.ServiceID != "" && e.Application != "" && e.Type == WebhookTypeApplication
+}
@@ -0,0 +1,77 @@
+package threescale
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseWebhook(t *testing.T) {
+	inputs := []struct {
+		name                     string
+		file                     string
+		expect                   WebhookEvent
+		expectChangesProxyConfig bool
+		expectChangesApplication bool
+	}{
+		{
+			name:                     "Test service",
+			file:                     "service_updated.xml",
+			expect:                   WebhookEvent{Type: "service", Action: "updated", ServiceID: "123"},
+			expectChangesProxyConfig: true,
+		},
+		{
+			name:                     "Test proxy config",
+			file:                     "proxy_config_promoted.xml",
+			expect:                   WebhookEvent{Type: "proxy_config", Action: "promoted", ServiceID: "456"},
+			expectChangesProxyConfig: true,
+		},
+		{
+			name:                     "Test application with user key",
+			file:                     "application_updated.xml",
+			expect:                   WebhookEvent{Type: "application", Action: "updated", ServiceID: "123", Application: "1c3b5f0a9d7e4b2c8a6f3e1d0b9c8a7f"},
+			expectChangesApplication: true,
+		},
+		{
+			name:                     "Test application with application ID",
+			file:                     "application_key_deleted.xml",
+			expect:                   WebhookEvent{Type: "application", Action: "key_deleted", ServiceID: "456", Application: "b3c91f2e"},
+			expectChangesApplication: true,
+		},
+		{
+			name:   "Test event without service",
+			file:   "account_created.xml",
+			expect: WebhookEvent{Type: "account", Action: "created"},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join("../../testdata/webhooks", input.file))
+			if err != nil {
+				t.Fatalf("error reading testdata file - %v", err)
+			}
+
+			event, err := ParseWebhook(data)
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if event != input.expect {
+				t.Errorf("expected %+v but got %+v", input.expect, event)
+			}
+
+			if event.ChangesProxyConfig() != input.expectChangesProxyConfig {
+				t.Errorf("expected event to change proxy config to be %v", input.expectChangesProxyConfig)
+			}
+
+			if event.ChangesApplication() != input.expectChangesApplication {
+				t.Errorf("expected event to change application to be %v", input.expectChangesApplication)
+			}
+		})
+	}
+
+	if _, err := ParseWebhook([]byte("not xml")); err == nil {
+		t.Errorf("expected error parsing invalid webhook")
+	}
+}
@@ -0,0 +1,45 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+/*
+ Utility script to generate the default attribute manifests of the config validator from the attribute manifests
+ used by the integration tests, so that the vocabulary is only described once. Run with go generate in pkg/kubernetes.
+*/
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+const header = `// Code generated by scripts/attributes.go from testdata/attributes.yaml. DO NOT EDIT.
+
+package kubernetes
+
+// defaultAttributeManifests describes the attribute vocabulary produced by the Istio proxy and the Kubernetes
+// environment, which is available to 3scale adapter instances and rules.
+const defaultAttributeManifests = `
+
+func main() {
+	if len(os.Args) != 3 {
+		log.Fatal("usage: attributes <attribute manifests> <output file>")
+	}
+
+	manifests, err := ioutil.ReadFile(os.Args[1])
+	if err != nil {
+		log.Fatalf("error reading attribute manifests - %v", err)
+	}
+
+	if bytes.ContainsRune(manifests, '`') {
+		log.Fatal("attribute manifests cannot contain a backquote")
+	}
+
+	out := header + "`\n" + strings.TrimSpace(string(manifests)) + "\n`\n"
+	if err := ioutil.WriteFile(os.Args[2], []byte(out), 0644); err != nil {
+		log.Fatalf("error writing attribute manifests - %v", err)
+	}
+}